@@ -3,11 +3,49 @@ package api
 import (
 	"context"
 
+	"github.com/gernest/rbf"
+	"github.com/gernest/roaring"
 	"github.com/gernest/sql3/dax"
 )
 
+// Executor provides read access to the data stored for tables.
 type Executor interface {
+	// Shards returns the shards holding data for the table, in ascending
+	// order.
+	Shards(ctx context.Context, tid dax.TableID) ([]uint64, error)
+
+	// View calls fn with a read-only transaction over a single shard of the
+	// table.
+	View(ctx context.Context, tid dax.TableID, shard uint64, fn func(tx Tx) error) error
+
+	// TranslateTableIDs returns the keys for the record ids of a table with
+	// string keys.
+	TranslateTableIDs(ctx context.Context, tid dax.TableID, ids []uint64) (map[uint64]string, error)
+
+	// TranslateFieldIDs returns the keys for the row ids of a field with
+	// string keys.
+	TranslateFieldIDs(ctx context.Context, tid dax.TableID, fname dax.FieldName, ids []uint64) (map[uint64]string, error)
+
+	// FindTableKeys returns the record ids for the keys of a table with
+	// string keys. Keys that have no id are omitted.
+	FindTableKeys(ctx context.Context, tid dax.TableID, keys ...string) (map[string]uint64, error)
+
+	// FindFieldKeys returns the row ids for the keys of a field with string
+	// keys. Keys that have no id are omitted.
+	FindFieldKeys(ctx context.Context, tid dax.TableID, fname dax.FieldName, keys ...string) (map[string]uint64, error)
 }
+
+// Tx is a read-only view of the bitmaps of a single shard.
+type Tx interface {
+	RoaringBitmap(name string) (*roaring.Bitmap, error)
+
+	// BitmapNames returns the names of all the bitmaps in the shard.
+	BitmapNames() ([]string, error)
+}
+
+// Ensure rbf transactions can be used to read shards.
+var _ Tx = (*rbf.Tx)(nil)
+
 type ClusterNode interface{}
 
 type SystemAPI interface {
@@ -86,6 +86,7 @@ type RoaringUpdate struct {
 
 	// ClearRecords, when true, denotes that Clear should be
 	// interpreted as a single row which will be subtracted from every
-	// row in this view.
+	// row in this view. When View is ViewAll, the row is subtracted
+	// from every view of the field and Set is ignored.
 	ClearRecords bool
 }
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gernest/rbf"
+	"github.com/gernest/rbf/quantum"
+	"github.com/gernest/rbf/short_txkey"
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3/dax"
+)
+
+// The storage layout follows the one used by FeatureBase. Records of a table
+// are split into shards of ShardWidth columns. Within a shard, each view of a
+// field is stored as a single bitmap in which the bit at
+// (row * ShardWidth + column % ShardWidth) is set when the record at column
+// contains row.
+//
+// Int-like fields (int, decimal, timestamp) are stored bit-sliced in the
+// view returned by ViewBSI; row 0 holds the existence bits, row 1 the sign
+// bits, and rows 2 and up the bits of the absolute value.
+const (
+	ShardWidth = rbf.ShardWidth
+
+	ViewStandard       = "standard"
+	ViewBSIGroupPrefix = "bsig_"
+
+	// ViewAll is the view of a RoaringUpdate that clears records from every
+	// view of a field in the shard, including its time views.
+	ViewAll = ""
+
+	// ExistenceFieldName is the field tracking which records exist in a
+	// table. It has a single row (0) in the standard view.
+	ExistenceFieldName = "_exists"
+)
+
+// Rows used by bool fields.
+const (
+	FalseRowID = 0
+	TrueRowID  = 1
+)
+
+// Rows used by bit-sliced fields.
+const (
+	BSIExistsBit = 0
+	BSISignBit   = 1
+	BSIOffsetBit = 2
+)
+
+// ViewBSI returns the name of the bit-sliced view of the field.
+func ViewBSI(fname dax.FieldName) string {
+	return ViewBSIGroupPrefix + string(fname)
+}
+
+// ViewsByTime returns the time views that hold a value written at t for a
+// field with quantum q.
+func ViewsByTime(t time.Time, q dax.TimeQuantum) []string {
+	return quantum.ViewsByTime(ViewStandard, t, quantum.TimeQuantum(q))
+}
+
+// ViewsByTimeRange returns the minimal set of time views covering
+// [start, end) for a field with quantum q.
+func ViewsByTimeRange(start, end time.Time, q dax.TimeQuantum) []string {
+	return quantum.ViewsByTimeRange(ViewStandard, start, end, quantum.TimeQuantum(q))
+}
+
+// TimeViewsRange returns the earliest time held in the time views among views
+// and the time after the latest, for a field with quantum q. Both times are
+// zero if there are no time views.
+func TimeViewsRange(views []string, q dax.TimeQuantum) (start, end time.Time, err error) {
+	min, max := quantum.MinMaxViews(views, quantum.TimeQuantum(q))
+	if min == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	start, err = quantum.TimeOfView(min, false)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err = quantum.TimeOfView(max, true)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
+
+// BitmapName returns the name of the bitmap holding a view of a field within
+// a shard.
+func BitmapName(fname dax.FieldName, view string) string {
+	return string(short_txkey.Prefix("", string(fname), view, 0))
+}
+
+// FieldViews returns the views of a field that have a bitmap in the shard
+// read by tx.
+func FieldViews(tx Tx, fname dax.FieldName) ([]string, error) {
+	names, err := tx.BitmapNames()
+	if err != nil {
+		return nil, err
+	}
+	prefix := string(short_txkey.FieldPrefix("", string(fname)))
+	views := make([]string, 0)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		_, view := short_txkey.SplitPrefix([]byte(name))
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// IsBSIField returns true if the field is stored bit-sliced.
+func IsBSIField(fld *dax.Field) bool {
+	switch fld.Type {
+	case dax.BaseTypeInt, dax.BaseTypeDecimal, dax.BaseTypeTimestamp:
+		return true
+	}
+	return false
+}
+
+// FieldView returns the view that holds the current values of the field.
+func FieldView(fld *dax.Field) string {
+	if IsBSIField(fld) {
+		return ViewBSI(fld.Name)
+	}
+	return ViewStandard
+}
+
+// Pos returns the position of (row, column) within a shard bitmap.
+func Pos(row, column uint64) uint64 {
+	return row*ShardWidth + column%ShardWidth
+}
+
+// Row returns the columns set in row of a shard bitmap as a bitmap of
+// shard-relative positions.
+func Row(bm *roaring.Bitmap, row uint64) *roaring.Bitmap {
+	return bm.OffsetRange(0, row*ShardWidth, (row+1)*ShardWidth)
+}
+
+// Rows returns the rows that have a bit set in a shard bitmap, in order. Only
+// the containers of bm are visited, so the cost doesn't depend on how large
+// the row numbers are.
+func Rows(bm *roaring.Bitmap) []uint64 {
+	var rows []uint64
+	itr, _ := bm.Containers.Iterator(0)
+	defer itr.Close()
+	for itr.Next() {
+		key, c := itr.Value()
+		if c.N() == 0 {
+			continue
+		}
+		row := (key << 16) / ShardWidth
+		if len(rows) == 0 || rows[len(rows)-1] != row {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// ForEachBit calls fn for every bit set in a shard bitmap with the row and the
+// shard-relative column of the bit.
+func ForEachBit(bm *roaring.Bitmap, fn func(row, column uint64) error) error {
+	return bm.ForEach(func(pos uint64) error {
+		return fn(pos/ShardWidth, pos%ShardWidth)
+	})
+}
+
+// SetBSI sets the bits encoding v for column in a bit-sliced bitmap.
+func SetBSI(bm *roaring.Bitmap, column uint64, v int64) {
+	bm.DirectAdd(Pos(BSIExistsBit, column))
+	u := uint64(v)
+	if v < 0 {
+		bm.DirectAdd(Pos(BSISignBit, column))
+		u = uint64(-v)
+	}
+	for i := uint64(0); u != 0; i, u = i+1, u>>1 {
+		if u&1 == 1 {
+			bm.DirectAdd(Pos(BSIOffsetBit+i, column))
+		}
+	}
+}
+
+// BSIValues decodes a bit-sliced shard bitmap into a map of shard-relative
+// column to value.
+func BSIValues(bm *roaring.Bitmap) map[uint64]int64 {
+	result := make(map[uint64]int64)
+	_ = ForEachBit(bm, func(row, column uint64) error {
+		switch {
+		case row == BSIExistsBit:
+			if _, ok := result[column]; !ok {
+				result[column] = 0
+			}
+		case row >= BSIOffsetBit:
+			result[column] |= 1 << (row - BSIOffsetBit)
+		}
+		return nil
+	})
+	sign := Row(bm, BSISignBit)
+	_ = sign.ForEach(func(column uint64) error {
+		result[column] = -result[column]
+		return nil
+	})
+	return result
+}
+
+// BSIBitDepth returns the number of magnitude rows in a bit-sliced bitmap.
+func BSIBitDepth(bm *roaring.Bitmap) uint64 {
+	if !bm.Any() {
+		return 0
+	}
+	row := bm.Max() / ShardWidth
+	if row < BSIOffsetBit {
+		return 0
+	}
+	return row - BSIOffsetBit + 1
+}
+
+// TimestampToVal converts ts into the integer stored for a timestamp field,
+// which is the number of time units since the field's epoch.
+func TimestampToVal(fld *dax.Field, ts time.Time) int64 {
+	return timeToUnits(fld.Options.TimeUnit, ts) - timeToUnits(fld.Options.TimeUnit, fieldEpoch(fld))
+}
+
+// ValToTimestampField converts the integer stored for a timestamp field back
+// into a time.
+func ValToTimestampField(fld *dax.Field, val int64) (time.Time, error) {
+	unit := fld.Options.TimeUnit
+	if unit == "" {
+		unit = TimeUnitSeconds
+	}
+	return ValToTimestamp(unit, val+timeToUnits(unit, fieldEpoch(fld)))
+}
+
+func fieldEpoch(fld *dax.Field) time.Time {
+	if fld.Options.Epoch.IsZero() {
+		return time.Unix(0, 0).UTC()
+	}
+	return fld.Options.Epoch
+}
+
+func timeToUnits(unit string, t time.Time) int64 {
+	switch unit {
+	case TimeUnitMilliseconds:
+		return t.UnixMilli()
+	case TimeUnitMicroseconds, TimeUnitUSeconds:
+		return t.UnixMicro()
+	case TimeUnitNanoseconds:
+		return t.UnixNano()
+	default:
+		return t.Unix()
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3/dax"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBSIValues(t *testing.T) {
+	want := map[uint64]int64{
+		0:              0,
+		1:              1,
+		7:              -42,
+		ShardWidth - 1: 1 << 40,
+	}
+	bm := roaring.NewBitmap()
+	for col, v := range want {
+		SetBSI(bm, col, v)
+	}
+	assert.Equal(t, want, BSIValues(bm))
+	assert.Equal(t, uint64(41), BSIBitDepth(bm))
+}
+
+func TestRows(t *testing.T) {
+	bm := roaring.NewBitmap(Pos(0, 1), Pos(0, ShardWidth-1), Pos(3, 0), Pos(1<<30, 5))
+	assert.Equal(t, []uint64{0, 3, 1 << 30}, Rows(bm))
+	assert.Empty(t, Rows(roaring.NewBitmap()))
+}
+
+func TestTimestampToVal(t *testing.T) {
+	fld := &dax.Field{
+		Name: "ts",
+		Type: dax.BaseTypeTimestamp,
+		Options: dax.FieldOptions{
+			TimeUnit: TimeUnitMilliseconds,
+		},
+	}
+	ts := time.Date(2022, 11, 1, 22, 8, 41, 500*int(time.Millisecond), time.UTC)
+	v := TimestampToVal(fld, ts)
+	assert.Equal(t, ts.UnixMilli(), v)
+
+	got, err := ValToTimestampField(fld, v)
+	assert.NoError(t, err)
+	assert.Equal(t, ts, got)
+}
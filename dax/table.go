@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/gernest/sql3/decimal"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -476,6 +478,10 @@ func (t *Table) CreateSQL() string {
 
 	sql += fmt.Sprintf(") KEYPARTITIONS %d", t.PartitionN)
 
+	if t.Description != "" {
+		sql += fmt.Sprintf(" COMMENT '%s'", strings.ReplaceAll(t.Description, "'", "''"))
+	}
+
 	return sql
 }
 
@@ -747,7 +753,7 @@ func (f *Field) IsPrimaryKey() bool {
 // CreateSQL returns the SQL representation of the field to be used in a CREATE
 // TABLE statement.
 func (f *Field) CreateSQL() string {
-	sql := fmt.Sprintf("%s %s", f.Name, f.Type)
+	sql := fmt.Sprintf("%s %s", f.Name, f.FullType())
 
 	// Apply constraints to all non-primarykey fields.
 	if !f.IsPrimaryKey() {
@@ -764,6 +770,8 @@ func (f *Field) constraints() string {
 	switch f.Type {
 	case BaseTypeInt:
 		sql += fmt.Sprintf(" MIN %d MAX %d", f.Options.Min, f.Options.Max)
+	case BaseTypeDecimal:
+		sql += fmt.Sprintf(" MIN %s MAX %s", decimal.NewDecimal(f.Options.Min, f.Options.Scale), decimal.NewDecimal(f.Options.Max, f.Options.Scale))
 	case BaseTypeID, BaseTypeString:
 		if f.Options.CacheType != "" {
 			sql += fmt.Sprintf(" CACHETYPE %s SIZE %d", f.Options.CacheType, f.Options.CacheSize)
@@ -772,6 +780,7 @@ func (f *Field) constraints() string {
 		if f.Options.CacheType != "" {
 			sql += fmt.Sprintf(" CACHETYPE %s SIZE %d", f.Options.CacheType, f.Options.CacheSize)
 		}
+	case BaseTypeIDSetQ, BaseTypeStringSetQ:
 		if f.Options.TimeQuantum != "" {
 			sql += fmt.Sprintf(" TIMEQUANTUM '%s'", f.Options.TimeQuantum)
 			if f.Options.TTL > 0 {
@@ -67,6 +67,12 @@ var (
 	ErrInvalidUngroupedColumnReference         = errors.New("(ErrInvalidUngroupedColumnReference")
 	ErrInvalidUngroupedColumnReferenceInHaving = errors.New("(ErrInvalidUngroupedColumnReferenceInHaving")
 
+	ErrSetOperationColumnCountMismatch = errors.New("(ErrSetOperationColumnCountMismatch")
+
+	ErrCTEColumnCountMismatch    = errors.New("(ErrCTEColumnCountMismatch")
+	ErrInvalidRecursiveCTE       = errors.New("(ErrInvalidRecursiveCTE")
+	ErrRecursiveCTELimitExceeded = errors.New("(ErrRecursiveCTELimitExceeded")
+
 	ErrInvalidTimeUnit    = errors.New("(ErrInvalidTimeUnit")
 	ErrInvalidTimeEpoch   = errors.New("(ErrInvalidTimeEpoch")
 	ErrInvalidTimeQuantum = errors.New("(ErrInvalidTimeQuantum")
@@ -85,9 +91,11 @@ var (
 	ErrTableIDColumnType         = errors.New("(ErrTableIDColumnType")
 	ErrTableIDColumnConstraints  = errors.New("(ErrTableIDColumnConstraints")
 	ErrTableIDColumnAlter        = errors.New("(ErrTableIDColumnAlter")
+	ErrTableIDColumnDrop         = errors.New("(ErrTableIDColumnDrop")
 	ErrTableNotFound             = errors.New("(ErrTableNotFound")
 	ErrTableExists               = errors.New("(ErrTableExists")
 	ErrColumnNotFound            = errors.New("(ErrColumnNotFound")
+	ErrAmbiguousColumn           = errors.New("(ErrAmbiguousColumn")
 	ErrTableColumnNotFound       = errors.New("(ErrTableColumnNotFound")
 	ErrInvalidKeyPartitionsValue = errors.New("(ErrInvalidKeyPartitionsValue")
 
@@ -256,6 +264,34 @@ func NewErrInvalidUngroupedColumnReferenceInHaving(line, col int, column string)
 	)
 }
 
+func NewErrSetOperationColumnCountMismatch(line, col int, operation string) error {
+	return newError(
+		ErrSetOperationColumnCountMismatch,
+		fmt.Sprintf("[%d:%d] each query of a %s must return the same number of columns", line, col, operation),
+	)
+}
+
+func NewErrCTEColumnCountMismatch(line, col int, cteName string, columnCount, nameCount int) error {
+	return newError(
+		ErrCTEColumnCountMismatch,
+		fmt.Sprintf("[%d:%d] common table expression '%s' returns %d columns but %d column names are specified", line, col, cteName, columnCount, nameCount),
+	)
+}
+
+func NewErrInvalidRecursiveCTE(line, col int, cteName string) error {
+	return newError(
+		ErrInvalidRecursiveCTE,
+		fmt.Sprintf("[%d:%d] recursive common table expression '%s' must be a non-recursive SELECT followed by UNION or UNION ALL and SELECTs that refer to it, without ORDER BY or LIMIT", line, col, cteName),
+	)
+}
+
+func NewErrRecursiveCTELimitExceeded(line, col int, cteName, limit string) error {
+	return newError(
+		ErrRecursiveCTELimitExceeded,
+		fmt.Sprintf("[%d:%d] recursive common table expression '%s' exceeded the limit of %s", line, col, cteName, limit),
+	)
+}
+
 func NewErrInvalidCast(line, col int, from, to string) error {
 	return newError(
 		ErrInvalidCast,
@@ -596,6 +632,13 @@ func NewErrTableIDColumnAlter(line, col int) error {
 	)
 }
 
+func NewErrTableIDColumnDrop(line, col int) error {
+	return newError(
+		ErrTableIDColumnDrop,
+		fmt.Sprintf("[%d:%d] _id column cannot be dropped", line, col),
+	)
+}
+
 func NewErrDatabaseNotFound(line, col int, databaseName string) error {
 	return newError(
 		ErrDatabaseNotFound,
@@ -631,6 +674,13 @@ func NewErrColumnNotFound(line, col int, columnName string) error {
 	)
 }
 
+func NewErrAmbiguousColumn(line, col int, columnName string) error {
+	return newError(
+		ErrAmbiguousColumn,
+		fmt.Sprintf("[%d:%d] column '%s' is ambiguous", line, col, columnName),
+	)
+}
+
 func NewErrTableColumnNotFound(line, col int, tableName string, columnName string) error {
 	return newError(
 		ErrTableColumnNotFound,
@@ -0,0 +1,277 @@
+// Package memory implements an in-memory storage engine for the sql3 planner.
+//
+// Engine keeps schema information and table data in process memory, storing
+// data as roaring bitmaps using the layout described in the api package. It
+// is intended for tests and small embedded deployments; nothing is persisted.
+package memory
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+)
+
+// Ensure type implements interface.
+var (
+	_ api.SchemaAPI      = (*Engine)(nil)
+	_ api.Importer       = (*Engine)(nil)
+	_ api.SystemAPI      = (*Engine)(nil)
+	_ api.SystemLayerAPI = (*Engine)(nil)
+	_ api.Executor       = (*Engine)(nil)
+)
+
+// Version is the version reported by Engine.
+const Version = "v0.1.0"
+
+// Engine is an in-memory implementation of the api interfaces.
+type Engine struct {
+	mu sync.RWMutex
+
+	databases    map[dax.DatabaseID]*dax.Database
+	tables       map[dax.TableID]*table
+	transactions map[string]*api.Transaction
+	requests     *executionRequests
+}
+
+// New returns a new, empty Engine.
+func New() *Engine {
+	return &Engine{
+		databases:    make(map[dax.DatabaseID]*dax.Database),
+		tables:       make(map[dax.TableID]*table),
+		transactions: make(map[string]*api.Transaction),
+		requests:     newExecutionRequests(),
+	}
+}
+
+// table holds the schema and data for a table.
+type table struct {
+	tbl *dax.Table
+
+	// shards maps a shard to the bitmaps stored in it, keyed by bitmap name.
+	shards map[uint64]map[string]*roaring.Bitmap
+
+	keys      *translator
+	fieldKeys map[dax.FieldName]*translator
+
+	// bitmaps written by the current write, see publish
+	written []shardBitmap
+}
+
+// shardBitmap identifies a bitmap in a shard of a table.
+type shardBitmap struct {
+	shard uint64
+	name  string
+}
+
+func newTable(tbl *dax.Table) *table {
+	return &table{
+		tbl:       tbl,
+		shards:    make(map[uint64]map[string]*roaring.Bitmap),
+		keys:      newTranslator(),
+		fieldKeys: make(map[dax.FieldName]*translator),
+	}
+}
+
+// bitmap returns the bitmap with the given name in shard for writing, creating
+// it if it does not exist.
+//
+// The stored bitmaps are frozen and shared with views, so the stored bitmap is
+// replaced by a copy that shares its containers until they are changed, which
+// is returned. The copy is frozen in turn by publish, once the write is done.
+func (t *table) bitmap(shard uint64, name string) *roaring.Bitmap {
+	bitmaps, ok := t.shards[shard]
+	if !ok {
+		bitmaps = make(map[string]*roaring.Bitmap)
+		t.shards[shard] = bitmaps
+	}
+	bm, ok := bitmaps[name]
+	if !ok {
+		bm = roaring.NewSliceBitmap()
+	} else {
+		bm = bm.Freeze()
+	}
+	bitmaps[name] = bm
+	t.written = append(t.written, shardBitmap{shard: shard, name: name})
+	return bm
+}
+
+// publish freezes the bitmaps written since the last call, so that views can
+// share them.
+func (t *table) publish() {
+	for _, w := range t.written {
+		bitmaps := t.shards[w.shard]
+		if bm, ok := bitmaps[w.name]; ok {
+			bitmaps[w.name] = bm.Freeze()
+		}
+	}
+	t.written = t.written[:0]
+}
+
+// fieldTranslator returns the translator for the field, creating it if it
+// does not exist.
+func (t *table) fieldTranslator(fname dax.FieldName) *translator {
+	tr, ok := t.fieldKeys[fname]
+	if !ok {
+		tr = newTranslator()
+		t.fieldKeys[fname] = tr
+	}
+	return tr
+}
+
+func (e *Engine) tableByID(tid dax.TableID) (*table, error) {
+	t, ok := e.tables[tid]
+	if !ok {
+		return nil, dax.NewErrTableIDDoesNotExist(dax.NewQualifiedTableID(dax.NewQualifiedDatabaseID("", ""), tid))
+	}
+	return t, nil
+}
+
+func (e *Engine) tableByName(tname dax.TableName) (*table, error) {
+	for _, t := range e.tables {
+		if t.tbl.Name == tname {
+			return t, nil
+		}
+	}
+	return nil, dax.NewErrTableNameDoesNotExist(tname)
+}
+
+// Shards implements api.Executor.
+func (e *Engine) Shards(ctx context.Context, tid dax.TableID) ([]uint64, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	shards := make([]uint64, 0, len(t.shards))
+	for shard := range t.shards {
+		shards = append(shards, shard)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+	return shards, nil
+}
+
+// View implements api.Executor. Writes replace bitmaps rather than change
+// them, so fn sees the bitmaps of the shard as they were when it was called
+// without copying them. The bitmaps are frozen, so changing them makes copies
+// of the changed containers.
+func (e *Engine) View(ctx context.Context, tid dax.TableID, shard uint64, fn func(tx api.Tx) error) error {
+	e.mu.RLock()
+	t, err := e.tableByID(tid)
+	if err != nil {
+		e.mu.RUnlock()
+		return err
+	}
+	tx := &tx{bitmaps: make(map[string]*roaring.Bitmap, len(t.shards[shard]))}
+	for name, bm := range t.shards[shard] {
+		tx.bitmaps[name] = bm
+	}
+	e.mu.RUnlock()
+
+	return fn(tx)
+}
+
+// TranslateTableIDs implements api.Executor.
+func (e *Engine) TranslateTableIDs(ctx context.Context, tid dax.TableID, ids []uint64) (map[uint64]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	return t.keys.translateIDs(ids), nil
+}
+
+// TranslateFieldIDs implements api.Executor.
+func (e *Engine) TranslateFieldIDs(ctx context.Context, tid dax.TableID, fname dax.FieldName, ids []uint64) (map[uint64]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.tbl.Field(fname); !ok {
+		return nil, dax.NewErrFieldDoesNotExist(fname)
+	}
+	return t.fieldTranslator(fname).translateIDs(ids), nil
+}
+
+// FindTableKeys implements api.Executor.
+func (e *Engine) FindTableKeys(ctx context.Context, tid dax.TableID, keys ...string) (map[string]uint64, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	return t.keys.findKeys(keys...), nil
+}
+
+// FindFieldKeys implements api.Executor.
+func (e *Engine) FindFieldKeys(ctx context.Context, tid dax.TableID, fname dax.FieldName, keys ...string) (map[string]uint64, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.tbl.Field(fname); !ok {
+		return nil, dax.NewErrFieldDoesNotExist(fname)
+	}
+	tr, ok := t.fieldKeys[fname]
+	if !ok {
+		return map[string]uint64{}, nil
+	}
+	return tr.findKeys(keys...), nil
+}
+
+// tx is a read-only view over the bitmaps of a shard.
+type tx struct {
+	bitmaps map[string]*roaring.Bitmap
+}
+
+func (t *tx) RoaringBitmap(name string) (*roaring.Bitmap, error) {
+	bm, ok := t.bitmaps[name]
+	if !ok {
+		return roaring.NewSliceBitmap(), nil
+	}
+	return bm, nil
+}
+
+func (t *tx) BitmapNames() ([]string, error) {
+	names := make([]string, 0, len(t.bitmaps))
+	for name := range t.bitmaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SystemAPI
+
+func (e *Engine) ClusterName() string         { return "memory" }
+func (e *Engine) Version() string             { return Version }
+func (e *Engine) PlatformDescription() string { return runtime.GOOS + "/" + runtime.GOARCH }
+func (e *Engine) PlatformVersion() string     { return runtime.Version() }
+func (e *Engine) ClusterNodeCount() int       { return 1 }
+func (e *Engine) ClusterReplicaCount() int    { return 1 }
+func (e *Engine) ShardWidth() int             { return api.ShardWidth }
+func (e *Engine) ClusterState() string        { return "NORMAL" }
+func (e *Engine) DataDir() string             { return "" }
+func (e *Engine) NodeID() string              { return "memory0" }
+
+// SystemLayerAPI
+
+func (e *Engine) ExecutionRequests() api.ExecutionRequestsAPI {
+	return e.requests
+}
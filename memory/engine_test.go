@@ -0,0 +1,153 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTable(t *testing.T, e *memory.Engine) *dax.Table {
+	t.Helper()
+	tbl := dax.NewTable("t")
+	tbl.Fields = []*dax.Field{
+		{Name: dax.PrimaryKeyFieldName, Type: dax.BaseTypeString},
+		{Name: "m", Type: dax.BaseTypeString},
+		{Name: "i", Type: dax.BaseTypeInt},
+	}
+	require.NoError(t, e.CreateTable(context.Background(), tbl))
+	// the engine doesn't change the table it is given
+	assert.Empty(t, tbl.ID)
+	tbl, err := e.TableByName(context.Background(), "t")
+	require.NoError(t, err)
+	return tbl
+}
+
+func bitmapBytes(positions ...uint64) []byte {
+	return roaring.NewBitmap(positions...).Roaring()
+}
+
+func TestEngine_Schema(t *testing.T) {
+	ctx := context.Background()
+	e := memory.New()
+	tbl := newTestTable(t, e)
+
+	err := e.CreateTable(ctx, dax.NewTable("t"))
+	assert.ErrorIs(t, err, dax.ErrTableNameExists)
+
+	_, err = e.TableByName(ctx, "nope")
+	assert.ErrorIs(t, err, dax.ErrTableNameDoesNotExist)
+
+	got, err := e.TableByID(ctx, tbl.ID)
+	require.NoError(t, err)
+	assert.Equal(t, dax.TableName("t"), got.Name)
+
+	err = e.CreateField(ctx, "t", &dax.Field{Name: "m", Type: dax.BaseTypeID})
+	assert.ErrorIs(t, err, dax.ErrFieldExists)
+
+	require.NoError(t, e.DeleteField(ctx, "t", "i"))
+	err = e.DeleteField(ctx, "t", "i")
+	assert.ErrorIs(t, err, dax.ErrFieldDoesNotExist)
+
+	require.NoError(t, e.CreateDatabase(ctx, &dax.Database{Name: "db"}))
+	db, err := e.DatabaseByName(ctx, "db")
+	require.NoError(t, err)
+	require.NoError(t, e.SetDatabaseOption(ctx, db.ID, dax.DatabaseOptionWorkersMin, "2"))
+	db, err = e.DatabaseByID(ctx, db.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, db.Options.WorkersMin)
+	require.NoError(t, e.DropDatabase(ctx, db.ID))
+	_, err = e.DatabaseByName(ctx, "db")
+	assert.ErrorIs(t, err, dax.ErrDatabaseNameDoesNotExist)
+
+	require.NoError(t, e.DeleteTable(ctx, "t"))
+	tables, err := e.Tables(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tables)
+}
+
+func TestEngine_Import(t *testing.T) {
+	ctx := context.Background()
+	e := memory.New()
+	tbl := newTestTable(t, e)
+
+	ids, err := e.CreateTableKeys(ctx, tbl.ID, "a", "b")
+	require.NoError(t, err)
+	again, err := e.CreateTableKeys(ctx, tbl.ID, "b")
+	require.NoError(t, err)
+	assert.Equal(t, ids["b"], again["b"])
+
+	a, b := ids["a"], ids["b"]
+	bsi := roaring.NewBitmap()
+	api.SetBSI(bsi, a, 10)
+	api.SetBSI(bsi, b, -3)
+
+	err = e.ImportRoaringShard(ctx, tbl.ID, 0, &api.ImportRoaringShardRequest{
+		Views: []api.RoaringUpdate{
+			{Field: api.ExistenceFieldName, View: api.ViewStandard, Set: bitmapBytes(a, b)},
+			{Field: "m", View: api.ViewStandard, Set: bitmapBytes(api.Pos(1, a), api.Pos(2, b))},
+			{Field: "i", View: api.ViewBSI("i"), Set: bsi.Roaring()},
+		},
+	})
+	require.NoError(t, err)
+
+	// setting a mutex value replaces the existing one
+	err = e.ImportRoaringShard(ctx, tbl.ID, 0, &api.ImportRoaringShardRequest{
+		Views: []api.RoaringUpdate{
+			{Field: "m", View: api.ViewStandard, Set: bitmapBytes(api.Pos(3, a))},
+		},
+	})
+	require.NoError(t, err)
+
+	shards, err := e.Shards(ctx, tbl.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0}, shards)
+
+	err = e.View(ctx, tbl.ID, 0, func(tx api.Tx) error {
+		bm, err := tx.RoaringBitmap(api.BitmapName("m", api.ViewStandard))
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{api.Pos(2, b), api.Pos(3, a)}, bm.Slice())
+
+		bm, err = tx.RoaringBitmap(api.BitmapName("i", api.ViewBSI("i")))
+		require.NoError(t, err)
+		assert.Equal(t, map[uint64]int64{a: 10, b: -3}, api.BSIValues(bm))
+		return nil
+	})
+	require.NoError(t, err)
+
+	// clearing a record removes it from every row, without changing the
+	// bitmaps of a view that is already open
+	err = e.View(ctx, tbl.ID, 0, func(tx api.Tx) error {
+		bm, err := tx.RoaringBitmap(api.BitmapName("m", api.ViewStandard))
+		require.NoError(t, err)
+		err = e.ImportRoaringShard(ctx, tbl.ID, 0, &api.ImportRoaringShardRequest{
+			Views: []api.RoaringUpdate{
+				{Field: "m", View: api.ViewStandard, Clear: bitmapBytes(a), ClearRecords: true},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{api.Pos(2, b), api.Pos(3, a)}, bm.Slice())
+		return nil
+	})
+	require.NoError(t, err)
+	err = e.View(ctx, tbl.ID, 0, func(tx api.Tx) error {
+		bm, err := tx.RoaringBitmap(api.BitmapName("m", api.ViewStandard))
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{api.Pos(2, b)}, bm.Slice())
+		return nil
+	})
+	require.NoError(t, err)
+
+	keys, err := e.TranslateTableIDs(ctx, tbl.ID, []uint64{a, b})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64]string{a: "a", b: "b"}, keys)
+
+	found, err := e.FindTableKeys(ctx, tbl.ID, "a", "nope")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"a": a}, found)
+}
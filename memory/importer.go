@@ -0,0 +1,268 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+)
+
+// StartTransaction starts a transaction. Writes to the engine are applied
+// immediately, so transactions are only tracked, not isolated.
+func (e *Engine) StartTransaction(ctx context.Context, id string, timeout time.Duration, exclusive bool, requestTimeout time.Duration) (*api.Transaction, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.transactions[id]; ok {
+		return nil, fmt.Errorf("transaction '%s' already exists", id)
+	}
+	now := time.Now()
+	trns := &api.Transaction{
+		ID:        id,
+		Active:    true,
+		Exclusive: exclusive,
+		Timeout:   timeout,
+		CreatedAt: now,
+		Deadline:  now.Add(timeout),
+	}
+	e.transactions[id] = trns
+	cp := *trns
+	return &cp, nil
+}
+
+func (e *Engine) FinishTransaction(ctx context.Context, id string) (*api.Transaction, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	trns, ok := e.transactions[id]
+	if !ok {
+		return nil, fmt.Errorf("transaction '%s' does not exist", id)
+	}
+	delete(e.transactions, id)
+	trns.Active = false
+	return trns, nil
+}
+
+func (e *Engine) CreateTableKeys(ctx context.Context, tid dax.TableID, keys ...string) (map[string]uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	return t.keys.createKeys(keys...), nil
+}
+
+func (e *Engine) CreateFieldKeys(ctx context.Context, tid dax.TableID, fname dax.FieldName, keys ...string) (map[string]uint64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.tbl.Field(fname); !ok {
+		return nil, dax.NewErrFieldDoesNotExist(fname)
+	}
+	return t.fieldTranslator(fname).createKeys(keys...), nil
+}
+
+// ImportRoaringBitmap sets (or clears, if clear is true) the bits of each view
+// of the field.
+func (e *Engine) ImportRoaringBitmap(ctx context.Context, tid dax.TableID, fld *dax.Field, shard uint64, views map[string]*roaring.Bitmap, clear bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return err
+	}
+	defer t.publish()
+
+	for view, bm := range views {
+		target := t.bitmap(shard, api.BitmapName(fld.Name, view))
+		if clear {
+			target.DifferenceInPlace(bm)
+		} else {
+			target.UnionInPlace(bm)
+		}
+	}
+	return nil
+}
+
+// ImportRoaringShard applies each of the updates in request to the shard.
+func (e *Engine) ImportRoaringShard(ctx context.Context, tid dax.TableID, shard uint64, request *api.ImportRoaringShardRequest) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return err
+	}
+	defer t.publish()
+
+	for _, update := range request.Views {
+		clear, err := unmarshalBitmap(update.Clear)
+		if err != nil {
+			return err
+		}
+		if update.View == api.ViewAll {
+			if !update.ClearRecords {
+				continue
+			}
+			if err := t.clearViews(shard, dax.FieldName(update.Field), api.Row(clear, 0)); err != nil {
+				return err
+			}
+			continue
+		}
+		set, err := unmarshalBitmap(update.Set)
+		if err != nil {
+			return err
+		}
+
+		target := t.bitmap(shard, api.BitmapName(dax.FieldName(update.Field), update.View))
+		if update.ClearRecords {
+			clearColumns(target, api.Row(clear, 0))
+		} else {
+			target.DifferenceInPlace(clear)
+		}
+
+		if fld, ok := t.tbl.Field(dax.FieldName(update.Field)); ok && isSingleValued(fld) {
+			clearColumns(target, columns(set))
+		}
+		target.UnionInPlace(set)
+	}
+	return nil
+}
+
+// clearViews removes the shard-relative columns in cols from every view of a
+// field in shard.
+func (t *table) clearViews(shard uint64, fname dax.FieldName, cols *roaring.Bitmap) error {
+	views, err := api.FieldViews(&tx{bitmaps: t.shards[shard]}, fname)
+	if err != nil {
+		return err
+	}
+	for _, view := range views {
+		clearColumns(t.bitmap(shard, api.BitmapName(fname, view)), cols)
+	}
+	return nil
+}
+
+// EncodeImportValues encodes a bit-sliced import of vals into the records ids
+// of the field, to be applied by DoImport.
+func (e *Engine) EncodeImportValues(ctx context.Context, tid dax.TableID, fld *dax.Field, shard uint64, vals []int64, ids []uint64, clear bool) (path string, data []byte, err error) {
+	if len(vals) != len(ids) {
+		return "", nil, fmt.Errorf("mismatched values and ids: %d != %d", len(vals), len(ids))
+	}
+	bm := roaring.NewSliceBitmap()
+	for i, id := range ids {
+		if id/api.ShardWidth != shard {
+			return "", nil, fmt.Errorf("record %d is not in shard %d", id, shard)
+		}
+		api.SetBSI(bm, id, vals[i])
+	}
+	return importPath(tid, fld, shard, clear), bm.Roaring(), nil
+}
+
+// EncodeImport encodes an import setting row vals[i] for record ids[i] of the
+// field, to be applied by DoImport.
+func (e *Engine) EncodeImport(ctx context.Context, tid dax.TableID, fld *dax.Field, shard uint64, vals, ids []uint64, clear bool) (path string, data []byte, err error) {
+	if len(vals) != len(ids) {
+		return "", nil, fmt.Errorf("mismatched values and ids: %d != %d", len(vals), len(ids))
+	}
+	bm := roaring.NewSliceBitmap()
+	for i, id := range ids {
+		if id/api.ShardWidth != shard {
+			return "", nil, fmt.Errorf("record %d is not in shard %d", id, shard)
+		}
+		bm.DirectAdd(api.Pos(vals[i], id))
+	}
+	return importPath(tid, fld, shard, clear), bm.Roaring(), nil
+}
+
+// DoImport applies an import encoded by EncodeImport or EncodeImportValues.
+func (e *Engine) DoImport(ctx context.Context, tid dax.TableID, fld *dax.Field, shard uint64, path string, data []byte) error {
+	u, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("parsing import path: %w", err)
+	}
+	clear, _ := strconv.ParseBool(u.Query().Get("clear"))
+
+	bm, err := unmarshalBitmap(data)
+	if err != nil {
+		return err
+	}
+	update := api.RoaringUpdate{
+		Field: string(fld.Name),
+		View:  api.FieldView(fld),
+	}
+	if clear {
+		// clearing a bit-sliced value removes the whole value
+		if api.IsBSIField(fld) {
+			bm = api.Row(bm, api.BSIExistsBit)
+			update.ClearRecords = true
+		}
+		update.Clear = bm.Roaring()
+	} else {
+		update.Set = data
+	}
+	return e.ImportRoaringShard(ctx, tid, shard, &api.ImportRoaringShardRequest{
+		Views: []api.RoaringUpdate{update},
+	})
+}
+
+func importPath(tid dax.TableID, fld *dax.Field, shard uint64, clear bool) string {
+	return fmt.Sprintf("/index/%s/field/%s/import-roaring/%d?clear=%t", tid, fld.Name, shard, clear)
+}
+
+// isSingleValued returns true if a record can hold at most one value for
+// the field, in which case setting a value replaces the existing one.
+func isSingleValued(fld *dax.Field) bool {
+	switch fld.Type {
+	case dax.BaseTypeIDSet, dax.BaseTypeIDSetQ, dax.BaseTypeStringSet, dax.BaseTypeStringSetQ:
+		return false
+	}
+	return !fld.IsPrimaryKey()
+}
+
+func unmarshalBitmap(data []byte) (*roaring.Bitmap, error) {
+	bm := roaring.NewSliceBitmap()
+	if len(data) == 0 {
+		return bm, nil
+	}
+	if err := bm.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("unmarshalling bitmap: %w", err)
+	}
+	// detach the bitmap from data, which belongs to the caller
+	return bm.Clone(), nil
+}
+
+// columns returns the shard-relative columns that have a bit set in any row of
+// bm.
+func columns(bm *roaring.Bitmap) *roaring.Bitmap {
+	result := roaring.NewSliceBitmap()
+	for _, row := range api.Rows(bm) {
+		result.UnionInPlace(api.Row(bm, row))
+	}
+	return result
+}
+
+// clearColumns removes the shard-relative columns in cols from every row of
+// bm.
+func clearColumns(bm, cols *roaring.Bitmap) {
+	if !bm.Any() || !cols.Any() {
+		return
+	}
+	rows := api.Rows(bm)
+	masks := make([]*roaring.Bitmap, 0, len(rows))
+	for _, row := range rows {
+		masks = append(masks, cols.OffsetRange(row*api.ShardWidth, 0, api.ShardWidth))
+	}
+	bm.DifferenceInPlace(masks...)
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gernest/sql3/api"
+)
+
+// executionRequests is an in-memory implementation of
+// api.ExecutionRequestsAPI.
+type executionRequests struct {
+	mu       sync.RWMutex
+	requests map[string]*api.ExecutionRequest
+}
+
+func newExecutionRequests() *executionRequests {
+	return &executionRequests{
+		requests: make(map[string]*api.ExecutionRequest),
+	}
+}
+
+func (r *executionRequests) AddRequest(requestID string, userID string, startTime time.Time, sql string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[requestID] = &api.ExecutionRequest{
+		RequestID: requestID,
+		UserID:    userID,
+		StartTime: startTime,
+		Status:    "running",
+		SQL:       sql,
+	}
+	return nil
+}
+
+func (r *executionRequests) UpdateRequest(requestID string,
+	endTime time.Time,
+	status string,
+	waitType string,
+	waitTime time.Duration,
+	waitResource string,
+	cpuTime time.Duration,
+	reads int64,
+	writes int64,
+	logicalReads int64,
+	rowCount int64,
+	plan string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req, ok := r.requests[requestID]
+	if !ok {
+		return fmt.Errorf("request '%s' does not exist", requestID)
+	}
+	req.EndTime = endTime
+	req.Status = status
+	req.WaitType = waitType
+	req.WaitTime = waitTime
+	req.WaitResource = waitResource
+	req.CPUTime = cpuTime
+	req.Reads = reads
+	req.Writes = writes
+	req.LogicalReads = logicalReads
+	req.RowCount = rowCount
+	req.Plan = plan
+	return nil
+}
+
+func (r *executionRequests) ListRequests() ([]api.ExecutionRequest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]api.ExecutionRequest, 0, len(r.requests))
+	for _, req := range r.requests {
+		result = append(result, req.Copy())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result, nil
+}
+
+func (r *executionRequests) GetRequest(requestID string) (api.ExecutionRequest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	req, ok := r.requests[requestID]
+	if !ok {
+		return api.ExecutionRequest{}, fmt.Errorf("request '%s' does not exist", requestID)
+	}
+	return req.Copy(), nil
+}
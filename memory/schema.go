@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gernest/rbf/short_txkey"
+	"github.com/gernest/sql3/dax"
+)
+
+func (e *Engine) CreateDatabase(ctx context.Context, db *dax.Database) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, d := range e.databases {
+		if d.Name == db.Name {
+			return dax.NewErrDatabaseNameExists(db.Name)
+		}
+	}
+	if db.ID == "" {
+		rn := make([]byte, 8)
+		if _, err := rand.Read(rn); err != nil {
+			return fmt.Errorf("getting random data %w", err)
+		}
+		db.ID = dax.DatabaseID(fmt.Sprintf("%x", rn))
+	}
+	if _, ok := e.databases[db.ID]; ok {
+		return dax.NewErrDatabaseIDExists(dax.NewQualifiedDatabaseID("", db.ID))
+	}
+	if db.CreatedAt == 0 {
+		db.CreatedAt = time.Now().Unix()
+	}
+	cp := *db
+	e.databases[db.ID] = &cp
+	return nil
+}
+
+func (e *Engine) DropDatabase(ctx context.Context, dbid dax.DatabaseID) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.databases[dbid]; !ok {
+		return dax.NewErrDatabaseIDDoesNotExist(dax.NewQualifiedDatabaseID("", dbid))
+	}
+	delete(e.databases, dbid)
+	return nil
+}
+
+func (e *Engine) DatabaseByName(ctx context.Context, dbname dax.DatabaseName) (*dax.Database, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, db := range e.databases {
+		if db.Name == dbname {
+			cp := *db
+			return &cp, nil
+		}
+	}
+	return nil, dax.NewErrDatabaseNameDoesNotExist(dbname)
+}
+
+func (e *Engine) DatabaseByID(ctx context.Context, dbid dax.DatabaseID) (*dax.Database, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	db, ok := e.databases[dbid]
+	if !ok {
+		return nil, dax.NewErrDatabaseIDDoesNotExist(dax.NewQualifiedDatabaseID("", dbid))
+	}
+	cp := *db
+	return &cp, nil
+}
+
+func (e *Engine) SetDatabaseOption(ctx context.Context, dbid dax.DatabaseID, option string, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	db, ok := e.databases[dbid]
+	if !ok {
+		return dax.NewErrDatabaseIDDoesNotExist(dax.NewQualifiedDatabaseID("", dbid))
+	}
+	if err := db.Options.Set(option, value); err != nil {
+		return err
+	}
+	db.UpdatedAt = time.Now().Unix()
+	return nil
+}
+
+// Databases returns the databases with the given ids, or all databases if no
+// ids are given, ordered by name.
+func (e *Engine) Databases(ctx context.Context, dbids ...dax.DatabaseID) ([]*dax.Database, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]*dax.Database, 0, len(e.databases))
+	if len(dbids) == 0 {
+		for _, db := range e.databases {
+			cp := *db
+			result = append(result, &cp)
+		}
+	} else {
+		for _, dbid := range dbids {
+			db, ok := e.databases[dbid]
+			if !ok {
+				return nil, dax.NewErrDatabaseIDDoesNotExist(dax.NewQualifiedDatabaseID("", dbid))
+			}
+			cp := *db
+			result = append(result, &cp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (e *Engine) TableByName(ctx context.Context, tname dax.TableName) (*dax.Table, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, err := e.tableByName(tname)
+	if err != nil {
+		return nil, err
+	}
+	return copyTable(t.tbl), nil
+}
+
+func (e *Engine) TableByID(ctx context.Context, tid dax.TableID) (*dax.Table, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, err := e.tableByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	return copyTable(t.tbl), nil
+}
+
+// Tables returns all tables ordered by name.
+func (e *Engine) Tables(ctx context.Context) ([]*dax.Table, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make(dax.Tables, 0, len(e.tables))
+	for _, t := range e.tables {
+		result = append(result, copyTable(t.tbl))
+	}
+	sort.Sort(result)
+	return result, nil
+}
+
+// CreateTable creates a table, assigning it an ID if it does not have one.
+// The engine keeps a copy of tbl, which is left unchanged.
+func (e *Engine) CreateTable(ctx context.Context, tbl *dax.Table) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, err := e.tableByName(tbl.Name); err == nil {
+		return dax.NewErrTableNameExists(tbl.Name)
+	}
+	tbl = copyTable(tbl)
+	if tbl.ID == "" {
+		if _, err := tbl.CreateID(); err != nil {
+			return err
+		}
+	}
+	if _, ok := e.tables[tbl.ID]; ok {
+		return dax.NewErrTableIDExists(dax.NewQualifiedTableID(dax.NewQualifiedDatabaseID("", ""), tbl.ID))
+	}
+	now := time.Now().Unix()
+	for _, fld := range tbl.Fields {
+		if fld.CreatedAt == 0 {
+			fld.CreatedAt = now
+		}
+	}
+	e.tables[tbl.ID] = newTable(tbl)
+	return nil
+}
+
+func (e *Engine) CreateField(ctx context.Context, tname dax.TableName, fld *dax.Field) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, err := e.tableByName(tname)
+	if err != nil {
+		return err
+	}
+	if _, ok := t.tbl.Field(fld.Name); ok {
+		return dax.NewErrFieldExists(fld.Name)
+	}
+	cp := *fld
+	if cp.CreatedAt == 0 {
+		cp.CreatedAt = time.Now().Unix()
+	}
+	t.tbl.Fields = append(t.tbl.Fields, &cp)
+	return nil
+}
+
+func (e *Engine) DeleteTable(ctx context.Context, tname dax.TableName) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, err := e.tableByName(tname)
+	if err != nil {
+		return err
+	}
+	delete(e.tables, t.tbl.ID)
+	return nil
+}
+
+// DeleteField removes a field from a table along with all of its data.
+func (e *Engine) DeleteField(ctx context.Context, tname dax.TableName, fname dax.FieldName) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t, err := e.tableByName(tname)
+	if err != nil {
+		return err
+	}
+	if !t.tbl.RemoveField(fname) {
+		return dax.NewErrFieldDoesNotExist(fname)
+	}
+	prefix := string(short_txkey.FieldPrefix("", string(fname)))
+	for _, bitmaps := range t.shards {
+		for name := range bitmaps {
+			if strings.HasPrefix(name, prefix) {
+				delete(bitmaps, name)
+			}
+		}
+	}
+	delete(t.fieldKeys, fname)
+	return nil
+}
+
+// copyTable returns a copy of tbl which shares nothing with it.
+func copyTable(tbl *dax.Table) *dax.Table {
+	cp := *tbl
+	cp.Fields = make([]*dax.Field, len(tbl.Fields))
+	for i, fld := range tbl.Fields {
+		f := *fld
+		cp.Fields[i] = &f
+	}
+	return &cp
+}
@@ -0,0 +1,56 @@
+package memory
+
+// translator maps string keys to ids. Ids are allocated sequentially starting
+// at 1.
+type translator struct {
+	ids  map[string]uint64
+	keys map[uint64]string
+	next uint64
+}
+
+func newTranslator() *translator {
+	return &translator{
+		ids:  make(map[string]uint64),
+		keys: make(map[uint64]string),
+		next: 1,
+	}
+}
+
+// createKeys returns the ids for keys, allocating ids for keys seen for the
+// first time.
+func (t *translator) createKeys(keys ...string) map[string]uint64 {
+	result := make(map[string]uint64, len(keys))
+	for _, key := range keys {
+		id, ok := t.ids[key]
+		if !ok {
+			id = t.next
+			t.next++
+			t.ids[key] = id
+			t.keys[id] = key
+		}
+		result[key] = id
+	}
+	return result
+}
+
+// translateIDs returns the keys for ids. Ids without a key are omitted.
+func (t *translator) translateIDs(ids []uint64) map[uint64]string {
+	result := make(map[uint64]string, len(ids))
+	for _, id := range ids {
+		if key, ok := t.keys[id]; ok {
+			result[id] = key
+		}
+	}
+	return result
+}
+
+// findKeys returns the ids for keys. Keys without an id are omitted.
+func (t *translator) findKeys(keys ...string) map[string]uint64 {
+	result := make(map[string]uint64, len(keys))
+	for _, key := range keys {
+		if id, ok := t.ids[key]; ok {
+			result[key] = id
+		}
+	}
+	return result
+}
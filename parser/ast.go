@@ -497,6 +497,7 @@ type ExplainStatement struct {
 	Explain   Pos       // position of EXPLAIN
 	Query     Pos       // position of QUERY (optional)
 	QueryPlan Pos       // position of PLAN after QUERY (optional)
+	Analyze   Pos       // position of ANALYZE (optional)
 	Stmt      Statement // target statement
 }
 
@@ -516,6 +517,8 @@ func (s *ExplainStatement) String() string {
 	buf.WriteString("EXPLAIN")
 	if s.QueryPlan.IsValid() {
 		buf.WriteString(" QUERY PLAN")
+	} else if s.Analyze.IsValid() {
+		buf.WriteString(" ANALYZE")
 	}
 	fmt.Fprintf(&buf, " %s", s.Stmt.String())
 	return buf.String()
@@ -2054,6 +2057,8 @@ func (expr *UnaryExpr) String() string {
 		return "-" + expr.X.String()
 	case BITNOT:
 		return "!" + expr.X.String()
+	case NOT:
+		return "NOT " + expr.X.String()
 	default:
 		panic(fmt.Sprintf("sql.UnaryExpr.String(): invalid op %s", expr.Op))
 	}
@@ -4188,6 +4193,7 @@ type QualifiedTableName struct {
 	QueryOptions  []*TableQueryOption
 	RParen        Pos
 	OutputColumns []*SourceOutputColumn // output columns - populated during analysis
+	CTE           *CTE                  // common table expression the name refers to - populated during analysis
 }
 
 // TableName returns the name used to identify n.
@@ -4415,6 +4421,17 @@ type JoinClause struct {
 	Operator   *JoinOperator  // join operator
 	Y          Source         // rhs source
 	Constraint JoinConstraint // join constraint
+
+	// columns merged by a USING constraint or a NATURAL join, populated
+	// during analysis
+	UsingColumns []*JoinUsingColumn
+}
+
+// JoinUsingColumn is a column merged by a USING constraint or a NATURAL join.
+type JoinUsingColumn struct {
+	X      *SourceOutputColumn // column of the lhs source
+	Y      *SourceOutputColumn // column of the rhs source, offset into the join
+	Output *SourceOutputColumn // merged column, which follows the columns of both sources
 }
 
 // Clone returns a deep copy of c.
@@ -4440,15 +4457,28 @@ func (c *JoinClause) String() string {
 	return buf.String()
 }
 
+// PossibleOutputColumns returns the merged columns of the join first, so that
+// they are found before the columns they were merged from.
 func (c *JoinClause) PossibleOutputColumns() []*SourceOutputColumn {
 	poc := make([]*SourceOutputColumn, 0)
+	for _, uc := range c.UsingColumns {
+		poc = append(poc, uc.Output)
+	}
 	poc = append(poc, c.X.PossibleOutputColumns()...)
-	poc = append(poc, c.Y.PossibleOutputColumns()...)
+	for _, oc := range c.Y.PossibleOutputColumns() {
+		poc = append(poc, c.offsetOutputColumn(oc))
+	}
 	return poc
 
 }
 
 func (c *JoinClause) OutputColumnNamed(name string) (*SourceOutputColumn, error) {
+	for _, uc := range c.UsingColumns {
+		if strings.EqualFold(uc.Output.ColumnName, name) {
+			return uc.Output, nil
+		}
+	}
+
 	if col, err := c.X.OutputColumnNamed(name); err != nil {
 		return nil, err
 	} else if col != nil {
@@ -4458,7 +4488,7 @@ func (c *JoinClause) OutputColumnNamed(name string) (*SourceOutputColumn, error)
 	if col, err := c.Y.OutputColumnNamed(name); err != nil {
 		return nil, err
 	} else if col != nil {
-		return col, nil
+		return c.offsetOutputColumn(col), nil
 	}
 
 	return nil, nil
@@ -4474,12 +4504,21 @@ func (c *JoinClause) OutputColumnQualifierNamed(qualifier string, name string) (
 	if col, err := c.Y.OutputColumnQualifierNamed(qualifier, name); err != nil {
 		return nil, err
 	} else if col != nil {
-		return col, nil
+		return c.offsetOutputColumn(col), nil
 	}
 
 	return nil, nil
 }
 
+// offsetOutputColumn returns a copy of an output column of the right hand
+// source with its index in the rows of the join, which are the columns of the
+// left hand source followed by the columns of the right hand source
+func (c *JoinClause) offsetOutputColumn(oc *SourceOutputColumn) *SourceOutputColumn {
+	result := *oc
+	result.ColumnIndex += len(c.X.PossibleOutputColumns())
+	return &result
+}
+
 func (c *JoinClause) SourceFromAlias(alias string) Source {
 	if src := c.X.SourceFromAlias(alias); src != nil {
 		return src
@@ -4491,14 +4530,15 @@ func (c *JoinClause) SourceFromAlias(alias string) Source {
 }
 
 type JoinOperator struct {
-	Comma Pos // position of comma
-	Left  Pos // position of LEFT keyword
-	Right Pos // position of RIGHT keyword
-	Full  Pos // position of FULL keyword
-	Outer Pos // position of OUTER keyword
-	Inner Pos // position of INNER keyword
-	//	Cross   Pos // position of CROSS keyword // TODO(pok) - add cross back when we do it
-	Join Pos // position of JOIN keyword
+	Comma   Pos // position of comma
+	Left    Pos // position of LEFT keyword
+	Right   Pos // position of RIGHT keyword
+	Full    Pos // position of FULL keyword
+	Outer   Pos // position of OUTER keyword
+	Inner   Pos // position of INNER keyword
+	Cross   Pos // position of CROSS keyword
+	Natural Pos // position of NATURAL keyword
+	Join    Pos // position of JOIN keyword
 }
 
 // Clone returns a deep copy of op.
@@ -4517,15 +4557,24 @@ func (op *JoinOperator) String() string {
 	}
 
 	var buf bytes.Buffer
-	if op.Left.IsValid() {
-		buf.WriteString(" LEFT")
+	if op.Natural.IsValid() {
+		buf.WriteString(" NATURAL")
+	}
+	if op.Left.IsValid() || op.Right.IsValid() || op.Full.IsValid() {
+		if op.Left.IsValid() {
+			buf.WriteString(" LEFT")
+		} else if op.Right.IsValid() {
+			buf.WriteString(" RIGHT")
+		} else {
+			buf.WriteString(" FULL")
+		}
 		if op.Outer.IsValid() {
 			buf.WriteString(" OUTER")
 		}
 	} else if op.Inner.IsValid() {
 		buf.WriteString(" INNER")
-		// } else if op.Cross.IsValid() {
-		// 	buf.WriteString(" CROSS")
+	} else if op.Cross.IsValid() {
+		buf.WriteString(" CROSS")
 	}
 	buf.WriteString(" JOIN ")
 
@@ -4627,6 +4676,8 @@ type CTE struct {
 	SelectLparen  Pos              // position of select left paren
 	Select        *SelectStatement // select statement
 	SelectRparen  Pos              // position of select right paren
+
+	SelfReference bool // whether the select statement refers to the CTE - populated during analysis
 }
 
 // Clone returns a deep copy of cte.
@@ -64,7 +64,7 @@ func (p *Parser) ParseStatement() (stmt Statement, err error) {
 	return stmt, nil
 }
 
-// parseExplain parses EXPLAIN [QUERY PLAN] STMT.
+// parseExplain parses EXPLAIN [QUERY PLAN | ANALYZE] STMT.
 func (p *Parser) parseExplainStatement() (_ *ExplainStatement, err error) {
 	var tok Token
 
@@ -81,6 +81,9 @@ func (p *Parser) parseExplainStatement() (_ *ExplainStatement, err error) {
 			return &stmt, p.errorExpected(p.pos, p.tok, "PLAN")
 		}
 		stmt.QueryPlan, _, _ = p.scan()
+	} else if p.peek() == ANALYZE {
+		// Parse optional "ANALYZE" token.
+		stmt.Analyze, _, _ = p.scan()
 	}
 
 	// Parse statement to be explained.
@@ -115,8 +118,8 @@ func (p *Parser) parseNonExplainStatement() (Statement, error) {
 		return p.parseUpdateStatement(nil)
 	case DELETE:
 		return p.parseDeleteStatement()
-		//	case WITH:
-		//		return p.parseWithStatement()
+	case WITH:
+		return p.parseWithStatement()
 	case SHOW:
 		return p.parseShowStatement()
 	default:
@@ -126,25 +129,19 @@ func (p *Parser) parseNonExplainStatement() (Statement, error) {
 
 // parseWithStatement is called only from parseNonExplainStatement as we don't
 // know what kind of statement we'll have after the CTEs (e.g. SELECT, INSERT, etc).
-/*func (p *Parser) parseWithStatement() (Statement, error) {
+func (p *Parser) parseWithStatement() (Statement, error) {
 	withClause, err := p.parseWithClause()
 	if err != nil {
 		return nil, err
 	}
 
 	switch p.peek() {
-	case SELECT, VALUES:
+	case SELECT:
 		return p.parseSelectStatement(false, withClause)
-	case INSERT, REPLACE:
-		return p.parseInsertStatement(withClause)
-	case UPDATE:
-		return p.parseUpdateStatement(withClause)
-	case DELETE:
-		return p.parseDeleteStatement(withClause)
 	default:
-		return nil, p.errorExpected(p.pos, p.tok, "SELECT, VALUES, INSERT, REPLACE, UPDATE, or DELETE")
+		return nil, p.errorExpected(p.pos, p.tok, "SELECT")
 	}
-}*/
+}
 
 func (p *Parser) parseShowStatement() (Statement, error) {
 	assert(p.peek() == SHOW)
@@ -2351,16 +2348,16 @@ func (p *Parser) parseAssignment() (_ *Assignment, err error) {
 // If compounded is true, some parts of the SELECT syntax are skipped.
 func (p *Parser) parseSelectStatement(compounded bool, withClause *WithClause) (_ *SelectStatement, err error) {
 	var stmt SelectStatement
-	//stmt.WithClause = withClause
+	stmt.WithClause = withClause
 
 	// Parse optional "WITH [RECURSIVE} cte, cte..."
 	// This is only called here if this method is called directly. Generic
 	// statement parsing will parse the WITH clause and pass it in instead.
-	//if !compounded && stmt.WithClause == nil && p.peek() == WITH {
-	//	if stmt.WithClause, err = p.parseWithClause(); err != nil {
-	//		return &stmt, err
-	//	}
-	//}
+	if !compounded && stmt.WithClause == nil && p.peek() == WITH {
+		if stmt.WithClause, err = p.parseWithClause(); err != nil {
+			return &stmt, err
+		}
+	}
 
 	if p.peek() != SELECT {
 		return &stmt, p.errorExpected(p.pos, p.tok, "SELECT")
@@ -2487,24 +2484,24 @@ func (p *Parser) parseSelectStatement(compounded bool, withClause *WithClause) (
 	// 	}
 	// }
 
-	// Optionally compound additional SELECT/VALUES.
-	// switch tok := p.peek(); tok {
-	// case UNION, INTERSECT, EXCEPT:
-	// 	if tok == UNION {
-	// 		stmt.Union, _, _ = p.scan()
-	// 		if p.peek() == ALL {
-	// 			stmt.UnionAll, _, _ = p.scan()
-	// 		}
-	// 	} else if tok == INTERSECT {
-	// 		stmt.Intersect, _, _ = p.scan()
-	// 	} else {
-	// 		stmt.Except, _, _ = p.scan()
-	// 	}
+	// Optionally compound additional SELECT.
+	switch tok := p.peek(); tok {
+	case UNION, INTERSECT, EXCEPT:
+		if tok == UNION {
+			stmt.Union, _, _ = p.scan()
+			if p.peek() == ALL {
+				stmt.UnionAll, _, _ = p.scan()
+			}
+		} else if tok == INTERSECT {
+			stmt.Intersect, _, _ = p.scan()
+		} else {
+			stmt.Except, _, _ = p.scan()
+		}
 
-	// 	if stmt.Compound, err = p.parseSelectStatement(true, nil); err != nil {
-	// 		return &stmt, err
-	// 	}
-	// }
+		if stmt.Compound, err = p.parseSelectStatement(true, nil); err != nil {
+			return &stmt, err
+		}
+	}
 
 	// Parse ORDER BY clause.
 	if !compounded && p.peek() == ORDER {
@@ -2582,7 +2579,7 @@ func (p *Parser) parseSource() (source Source, err error) {
 	for {
 		// Exit immediately if not part of a join operator.
 		switch p.peek() {
-		case COMMA, LEFT, RIGHT, FULL, INNER /*CROSS, */, JOIN:
+		case COMMA, LEFT, RIGHT, FULL, INNER, CROSS, NATURAL, JOIN:
 		default:
 			return source, nil
 		}
@@ -2596,9 +2593,12 @@ func (p *Parser) parseSource() (source Source, err error) {
 		if err != nil {
 			return source, err
 		}
-		constraint, err := p.parseJoinConstraint()
-		if err != nil {
-			return source, err
+		// natural and cross joins have no constraint
+		var constraint JoinConstraint
+		if !operator.Natural.IsValid() && !operator.Cross.IsValid() {
+			if constraint, err = p.parseJoinConstraint(); err != nil {
+				return source, err
+			}
 		}
 
 		source = &JoinClause{X: source, Operator: operator, Y: y, Constraint: constraint}
@@ -2633,7 +2633,11 @@ func (p *Parser) parseJoinOperator() (*JoinOperator, error) {
 		return &op, nil
 	}
 
-	// Parse  "INNER", "LEFT [OUTER]", "RIGHT [OUTER]", "FULL [OUTER]", or "CROSS"
+	// Parse "[NATURAL] INNER", "[NATURAL] LEFT [OUTER]", "[NATURAL] RIGHT [OUTER]",
+	// "[NATURAL] FULL [OUTER]", "NATURAL" or "CROSS"
+	if p.peek() == NATURAL {
+		op.Natural, _, _ = p.scan()
+	}
 	switch p.peek() {
 	case LEFT:
 		op.Left, _, _ = p.scan()
@@ -2652,9 +2656,10 @@ func (p *Parser) parseJoinOperator() (*JoinOperator, error) {
 		}
 	case INNER:
 		op.Inner, _, _ = p.scan()
-
-		// case CROSS:
-		// 	op.Cross, _, _ = p.scan()
+	case CROSS:
+		if !op.Natural.IsValid() {
+			op.Cross, _, _ = p.scan()
+		}
 	}
 
 	// Parse final JOIN.
@@ -2867,7 +2872,7 @@ func (p *Parser) parseTableValuedFunction(ident *Ident) (_ *TableValuedFunction,
 	return &tbl, nil
 }
 
-/*func (p *Parser) parseWithClause() (*WithClause, error) {
+func (p *Parser) parseWithClause() (*WithClause, error) {
 	assert(p.peek() == WITH)
 
 	var clause WithClause
@@ -2890,9 +2895,9 @@ func (p *Parser) parseTableValuedFunction(ident *Ident) (_ *TableValuedFunction,
 		p.scan()
 	}
 	return &clause, nil
-}*/
+}
 
-/*func (p *Parser) parseCTE() (_ *CTE, err error) {
+func (p *Parser) parseCTE() (_ *CTE, err error) {
 	var cte CTE
 	if cte.TableName, err = p.parseIdent("table name"); err != nil {
 		return &cte, err
@@ -2940,7 +2945,7 @@ func (p *Parser) parseTableValuedFunction(ident *Ident) (_ *TableValuedFunction,
 	cte.SelectRparen, _, _ = p.scan()
 
 	return &cte, nil
-}*/
+}
 
 func (p *Parser) parsePredictStatement() (_ *PredictStatement, err error) {
 	assert(p.peek() == PREDICT)
@@ -3041,7 +3046,19 @@ func (p *Parser) parseOperand() (expr Expr, err error) {
 	case CASE:
 		p.unscan()
 		return p.parseCaseExpr()
-	case NOT, EXISTS:
+	case NOT:
+		if p.peek() == EXISTS {
+			exists, err := p.parseExists()
+			exists.Not = pos
+			return exists, err
+		}
+		// NOT binds less tightly than comparisons, so NOT x = y is NOT (x = y)
+		expr, err = p.parseBinaryExpr(NOT.Precedence() + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{OpPos: pos, Op: tok, X: expr}, nil
+	case EXISTS:
 		p.unscan()
 		return p.parseExists()
 	case SELECT:
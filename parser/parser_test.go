@@ -723,6 +723,16 @@ func TestParser_ParseStatement(t *testing.T) {
 				},
 			})
 		})*/
+		t.Run("Analyze", func(t *testing.T) {
+			AssertParseStatement(t, `EXPLAIN ANALYZE SHOW TABLES`, &parser.ExplainStatement{
+				Explain: pos(0),
+				Analyze: pos(8),
+				Stmt: &parser.ShowTablesStatement{
+					Show:   pos(16),
+					Tables: pos(21),
+				},
+			})
+		})
 		/*t.Run("ErrNoPlan", func(t *testing.T) {
 			AssertParseStatementError(t, `EXPLAIN QUERY`, `1:13: expected PLAN, found 'EOF'`)
 		})*/
@@ -2110,31 +2120,47 @@ func TestParser_ParseStatement(t *testing.T) {
 				},
 			},
 		})
-		// AssertParseStatement(t, `SELECT * FROM foo CROSS JOIN bar`, &parser.SelectStatement{
-		// 	Select: pos(0),
-		// 	Columns: []*parser.ResultColumn{
-		// 		{Star: pos(7)},
-		// 	},
-		// 	From: pos(9),
-		// 	Source: &parser.JoinClause{
-		// 		X: &parser.QualifiedTableName{
-		// 			Name: &parser.Ident{NamePos: pos(14), Name: "foo"},
-		// 		},
-		// 		Operator: &parser.JoinOperator{Cross: pos(18), Join: pos(24)},
-		// 		Y: &parser.QualifiedTableName{
-		// 			Name: &parser.Ident{NamePos: pos(29), Name: "bar"},
-		// 		},
-		// 	},
-		// })
+		AssertParseStatement(t, `SELECT * FROM foo CROSS JOIN bar`, &parser.SelectStatement{
+			Select: pos(0),
+			Columns: []*parser.ResultColumn{
+				{Star: pos(7)},
+			},
+			From: pos(9),
+			Source: &parser.JoinClause{
+				X: &parser.QualifiedTableName{
+					Name: &parser.Ident{NamePos: pos(14), Name: "foo"},
+				},
+				Operator: &parser.JoinOperator{Cross: pos(18), Join: pos(24)},
+				Y: &parser.QualifiedTableName{
+					Name: &parser.Ident{NamePos: pos(29), Name: "bar"},
+				},
+			},
+		})
+		AssertParseStatement(t, `SELECT * FROM foo NATURAL FULL JOIN bar`, &parser.SelectStatement{
+			Select: pos(0),
+			Columns: []*parser.ResultColumn{
+				{Star: pos(7)},
+			},
+			From: pos(9),
+			Source: &parser.JoinClause{
+				X: &parser.QualifiedTableName{
+					Name: &parser.Ident{NamePos: pos(14), Name: "foo"},
+				},
+				Operator: &parser.JoinOperator{Natural: pos(18), Full: pos(26), Join: pos(31)},
+				Y: &parser.QualifiedTableName{
+					Name: &parser.Ident{NamePos: pos(36), Name: "bar"},
+				},
+			},
+		})
 
-		/*AssertParseStatement(t, `WITH cte (foo, bar) AS (SELECT baz), xxx AS (SELECT yyy) SELECT bat`, &parser.SelectStatement{
+		AssertParseStatement(t, `WITH cte (foo, bar) AS (SELECT baz), xxx AS (SELECT yyy) SELECT bat`, &parser.SelectStatement{
 			WithClause: &parser.WithClause{
 				With: pos(0),
-				CTEs: []*sql.CTE{
+				CTEs: []*parser.CTE{
 					{
 						TableName:     &parser.Ident{NamePos: pos(5), Name: "cte"},
 						ColumnsLparen: pos(9),
-						Columns: []*sql.Ident{
+						Columns: []*parser.Ident{
 							{NamePos: pos(10), Name: "foo"},
 							{NamePos: pos(15), Name: "bar"},
 						},
@@ -2143,7 +2169,7 @@ func TestParser_ParseStatement(t *testing.T) {
 						SelectLparen:  pos(23),
 						Select: &parser.SelectStatement{
 							Select: pos(24),
-							Columns: []*sql.ResultColumn{
+							Columns: []*parser.ResultColumn{
 								{Expr: &parser.Ident{NamePos: pos(31), Name: "baz"}},
 							},
 						},
@@ -2155,7 +2181,7 @@ func TestParser_ParseStatement(t *testing.T) {
 						SelectLparen: pos(44),
 						Select: &parser.SelectStatement{
 							Select: pos(45),
-							Columns: []*sql.ResultColumn{
+							Columns: []*parser.ResultColumn{
 								{Expr: &parser.Ident{NamePos: pos(52), Name: "yyy"}},
 							},
 						},
@@ -2164,22 +2190,22 @@ func TestParser_ParseStatement(t *testing.T) {
 				},
 			},
 			Select: pos(57),
-			Columns: []*sql.ResultColumn{
+			Columns: []*parser.ResultColumn{
 				{Expr: &parser.Ident{NamePos: pos(64), Name: "bat"}},
 			},
-		})*/
-		/*AssertParseStatement(t, `WITH RECURSIVE cte AS (SELECT foo) SELECT bar`, &parser.SelectStatement{
+		})
+		AssertParseStatement(t, `WITH RECURSIVE cte AS (SELECT foo) SELECT bar`, &parser.SelectStatement{
 			WithClause: &parser.WithClause{
 				With:      pos(0),
 				Recursive: pos(5),
-				CTEs: []*sql.CTE{
+				CTEs: []*parser.CTE{
 					{
 						TableName:    &parser.Ident{NamePos: pos(15), Name: "cte"},
 						As:           pos(19),
 						SelectLparen: pos(22),
 						Select: &parser.SelectStatement{
 							Select: pos(23),
-							Columns: []*sql.ResultColumn{
+							Columns: []*parser.ResultColumn{
 								{Expr: &parser.Ident{NamePos: pos(30), Name: "foo"}},
 							},
 						},
@@ -2188,10 +2214,10 @@ func TestParser_ParseStatement(t *testing.T) {
 				},
 			},
 			Select: pos(35),
-			Columns: []*sql.ResultColumn{
+			Columns: []*parser.ResultColumn{
 				{Expr: &parser.Ident{NamePos: pos(42), Name: "bar"}},
 			},
-		})*/
+		})
 
 		AssertParseStatement(t, `SELECT * WHERE true`, &parser.SelectStatement{
 			Select:    pos(0),
@@ -2258,64 +2284,64 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		})
 
-		// AssertParseStatement(t, `SELECT * UNION SELECT * ORDER BY foo`, &parser.SelectStatement{
-		// 	Select: pos(0),
-		// 	Columns: []*parser.ResultColumn{
-		// 		{Star: pos(7)},
-		// 	},
-		// 	Union: pos(9),
-		// 	Compound: &parser.SelectStatement{
-		// 		Select: pos(15),
-		// 		Columns: []*parser.ResultColumn{
-		// 			{Star: pos(22)},
-		// 		},
-		// 	},
-		// 	Order:   pos(24),
-		// 	OrderBy: pos(30),
-		// 	OrderingTerms: []*parser.OrderingTerm{
-		// 		{X: &parser.Ident{NamePos: pos(33), Name: "foo"}},
-		// 	},
-		// })
-		// AssertParseStatement(t, `SELECT * UNION ALL SELECT *`, &parser.SelectStatement{
-		// 	Select: pos(0),
-		// 	Columns: []*parser.ResultColumn{
-		// 		{Star: pos(7)},
-		// 	},
-		// 	Union:    pos(9),
-		// 	UnionAll: pos(15),
-		// 	Compound: &parser.SelectStatement{
-		// 		Select: pos(19),
-		// 		Columns: []*parser.ResultColumn{
-		// 			{Star: pos(26)},
-		// 		},
-		// 	},
-		// })
-		// AssertParseStatement(t, `SELECT * INTERSECT SELECT *`, &parser.SelectStatement{
-		// 	Select: pos(0),
-		// 	Columns: []*parser.ResultColumn{
-		// 		{Star: pos(7)},
-		// 	},
-		// 	Intersect: pos(9),
-		// 	Compound: &parser.SelectStatement{
-		// 		Select: pos(19),
-		// 		Columns: []*parser.ResultColumn{
-		// 			{Star: pos(26)},
-		// 		},
-		// 	},
-		// })
-		// AssertParseStatement(t, `SELECT * EXCEPT SELECT *`, &parser.SelectStatement{
-		// 	Select: pos(0),
-		// 	Columns: []*parser.ResultColumn{
-		// 		{Star: pos(7)},
-		// 	},
-		// 	Except: pos(9),
-		// 	Compound: &parser.SelectStatement{
-		// 		Select: pos(16),
-		// 		Columns: []*parser.ResultColumn{
-		// 			{Star: pos(23)},
-		// 		},
-		// 	},
-		// })
+		AssertParseStatement(t, `SELECT * UNION SELECT * ORDER BY foo`, &parser.SelectStatement{
+			Select: pos(0),
+			Columns: []*parser.ResultColumn{
+				{Star: pos(7)},
+			},
+			Union: pos(9),
+			Compound: &parser.SelectStatement{
+				Select: pos(15),
+				Columns: []*parser.ResultColumn{
+					{Star: pos(22)},
+				},
+			},
+			Order:   pos(24),
+			OrderBy: pos(30),
+			OrderingTerms: []*parser.OrderingTerm{
+				{X: &parser.Ident{NamePos: pos(33), Name: "foo"}},
+			},
+		})
+		AssertParseStatement(t, `SELECT * UNION ALL SELECT *`, &parser.SelectStatement{
+			Select: pos(0),
+			Columns: []*parser.ResultColumn{
+				{Star: pos(7)},
+			},
+			Union:    pos(9),
+			UnionAll: pos(15),
+			Compound: &parser.SelectStatement{
+				Select: pos(19),
+				Columns: []*parser.ResultColumn{
+					{Star: pos(26)},
+				},
+			},
+		})
+		AssertParseStatement(t, `SELECT * INTERSECT SELECT *`, &parser.SelectStatement{
+			Select: pos(0),
+			Columns: []*parser.ResultColumn{
+				{Star: pos(7)},
+			},
+			Intersect: pos(9),
+			Compound: &parser.SelectStatement{
+				Select: pos(19),
+				Columns: []*parser.ResultColumn{
+					{Star: pos(26)},
+				},
+			},
+		})
+		AssertParseStatement(t, `SELECT * EXCEPT SELECT *`, &parser.SelectStatement{
+			Select: pos(0),
+			Columns: []*parser.ResultColumn{
+				{Star: pos(7)},
+			},
+			Except: pos(9),
+			Compound: &parser.SelectStatement{
+				Select: pos(16),
+				Columns: []*parser.ResultColumn{
+					{Star: pos(23)},
+				},
+			},
+		})
 
 		/*AssertParseStatement(t, `VALUES (1, 2), (3, 4)`, &parser.SelectStatement{
 			Values: pos(0),
@@ -3481,6 +3507,7 @@ func TestParser_ParseStatement(t *testing.T) {
 		AssertParseStatementError(t, `SELECT * FROM foo RIGHT OUTER`, `1:29: expected JOIN, found 'EOF'`)
 		AssertParseStatementError(t, `SELECT * FROM foo FULL`, `1:22: expected JOIN, found 'EOF'`)
 		AssertParseStatementError(t, `SELECT * FROM foo FULL OUTER`, `1:28: expected JOIN, found 'EOF'`)
+		AssertParseStatementError(t, `SELECT * FROM foo NATURAL CROSS JOIN bar`, `1:27: expected JOIN, found 'CROSS'`)
 		AssertParseStatementError(t, `SELECT * FROM foo,`, `1:18: expected table name or left paren, found 'EOF'`)
 		AssertParseStatementError(t, `SELECT * FROM foo JOIN bar ON`, `1:29: expected expression, found 'EOF'`)
 		AssertParseStatementError(t, `SELECT * FROM foo JOIN bar USING`, `1:32: expected left paren, found 'EOF'`)
@@ -3513,7 +3540,8 @@ func TestParser_ParseStatement(t *testing.T) {
 		AssertParseStatementError(t, `VALUES (`, `1:8: expected expression, found 'EOF'`)
 		AssertParseStatementError(t, `VALUES (1`, `1:9: expected comma or right paren, found 'EOF'`)
 		AssertParseStatementError(t, `VALUES (1,`, `1:10: expected expression, found 'EOF'`)*/
-		//AssertParseStatementError(t, `SELECT * UNION`, `1:14: expected SELECT or VALUES, found 'EOF'`)
+		AssertParseStatementError(t, `SELECT * UNION`, `1:14: expected SELECT, found 'EOF'`)
+		AssertParseStatementError(t, `WITH cte AS (SELECT foo) DELETE FROM foo`, `1:26: expected SELECT, found 'DELETE'`)
 	})
 
 	t.Run("Insert", func(t *testing.T) {
@@ -4215,6 +4243,11 @@ func TestParser_ParseExpr(t *testing.T) {
 	t.Run("UnaryExpr", func(t *testing.T) {
 		AssertParseExpr(t, `-123`, &parser.UnaryExpr{OpPos: pos(0), Op: parser.MINUS, X: &parser.IntegerLit{ValuePos: pos(1), Value: `123`}})
 		AssertParseExprError(t, `-`, `1:1: expected expression, found 'EOF'`)
+		AssertParseExpr(t, `NOT x = 1`, &parser.UnaryExpr{OpPos: pos(0), Op: parser.NOT, X: &parser.BinaryExpr{
+			X:     &parser.Ident{NamePos: pos(4), Name: "x"},
+			OpPos: pos(6), Op: parser.EQ,
+			Y: &parser.IntegerLit{ValuePos: pos(8), Value: "1"},
+		}})
 	})
 	t.Run("QualifiedRef", func(t *testing.T) {
 		AssertParseExpr(t, `tbl.col`, &parser.QualifiedRef{
@@ -4253,7 +4286,7 @@ func TestParser_ParseExpr(t *testing.T) {
 			},
 			Rparen: pos(20),
 		})
-		AssertParseExprError(t, `NOT`, `1:3: expected EXISTS, found 'EOF'`)
+		AssertParseExprError(t, `NOT`, `1:3: expected expression, found 'EOF'`)
 		AssertParseExprError(t, `EXISTS`, `1:6: expected left paren, found 'EOF'`)
 		//AssertParseExprError(t, `EXISTS (`, `1:8: expected SELECT or VALUES, found 'EOF'`)
 		//AssertParseExprError(t, `EXISTS (SELECT`, `1:14: expected expression, found 'EOF'`)
@@ -172,6 +172,7 @@ const (
 	MAX
 	MIN
 	MODEL
+	NATURAL
 	NO
 	NOT
 	NOTBETWEEN
@@ -399,6 +400,7 @@ var tokens = [...]string{
 	MAX:               "MAX",
 	MIN:               "MIN",
 	MODEL:             "MODEL",
+	NATURAL:           "NATURAL",
 	NO:                "NO",
 	NOT:               "NOT",
 	NOTBETWEEN:        "NOTBETWEEN",
@@ -0,0 +1,667 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// bitmapPredicate returns the shard-relative columns of the records in a shard
+// for which a condition is true. records holds the columns of all the records
+// in the shard.
+type bitmapPredicate func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error)
+
+// bitmapFilterBuilder translates conditions on the columns of a table scan
+// into bitmapPredicates, so they can be evaluated with bitmap operations rather
+// than row by row. A condition can be translated when it compares a column
+// with literals using =, <>, <, <=, >, >=, IN, BETWEEN, IS [NOT] NULL or one
+// of the SETCONTAINS functions, or when it combines such conditions with AND
+// or OR.
+type bitmapFilterBuilder struct {
+	planner *ExecutionPlanner
+	table   *dax.Table
+	fields  []*dax.Field
+}
+
+func newBitmapFilterBuilder(p *ExecutionPlanner, table *dax.Table, fields []*dax.Field) *bitmapFilterBuilder {
+	return &bitmapFilterBuilder{
+		planner: p,
+		table:   table,
+		fields:  fields,
+	}
+}
+
+// split splits the terms of a filter into those that can be evaluated with
+// bitmap operations, which are returned as predicates, and the residual
+// condition that has to be evaluated row by row, which is nil if there is none
+func (b *bitmapFilterBuilder) split(ctx context.Context, filter types.PlanExpression) ([]bitmapPredicate, types.PlanExpression, error) {
+	predicates := make([]bitmapPredicate, 0)
+	residual := make([]types.PlanExpression, 0)
+	for _, term := range splitConjunction(filter) {
+		pred, ok, err := b.build(ctx, term)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			predicates = append(predicates, pred)
+		} else {
+			residual = append(residual, term)
+		}
+	}
+	return predicates, joinConjunction(residual), nil
+}
+
+// splitConjunction returns the terms of expr joined by AND
+func splitConjunction(expr types.PlanExpression) []types.PlanExpression {
+	if expr == nil {
+		return nil
+	}
+	if e, ok := expr.(*binOpPlanExpression); ok && e.op == parser.AND {
+		return append(splitConjunction(e.lhs), splitConjunction(e.rhs)...)
+	}
+	return []types.PlanExpression{expr}
+}
+
+// joinConjunction returns terms joined by AND, or nil if there are no terms
+func joinConjunction(terms []types.PlanExpression) types.PlanExpression {
+	var result types.PlanExpression
+	for _, term := range terms {
+		if result == nil {
+			result = term
+			continue
+		}
+		result = newBinOpPlanExpression(result, parser.AND, term, parser.NewDataTypeBool())
+	}
+	return result
+}
+
+// build returns a bitmapPredicate for expr, or false if expr can't be
+// evaluated with bitmap operations
+func (b *bitmapFilterBuilder) build(ctx context.Context, expr types.PlanExpression) (bitmapPredicate, bool, error) {
+	switch e := expr.(type) {
+	case *binOpPlanExpression:
+		switch e.op {
+		case parser.AND, parser.OR:
+			lhs, ok, err := b.build(ctx, e.lhs)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			rhs, ok, err := b.build(ctx, e.rhs)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			if e.op == parser.AND {
+				return intersectPredicates(lhs, rhs), true, nil
+			}
+			return unionPredicates(lhs, rhs), true, nil
+
+		case parser.IS, parser.ISNOT:
+			fld, ok := b.column(e.lhs)
+			if !ok {
+				return nil, false, nil
+			}
+			if _, ok := e.rhs.(*nullLiteralPlanExpression); !ok {
+				return nil, false, nil
+			}
+			notNull := notNullPredicate(fld)
+			if e.op == parser.ISNOT {
+				return notNull, true, nil
+			}
+			return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+				cols, err := notNull(tx, shard, records)
+				if err != nil {
+					return nil, err
+				}
+				return records.Difference(cols), nil
+			}, true, nil
+
+		case parser.EQ, parser.NE, parser.LT, parser.LE, parser.GT, parser.GE:
+			if fld, ok := b.column(e.lhs); ok {
+				return b.compare(ctx, fld, e.op, e.rhs)
+			}
+			if fld, ok := b.column(e.rhs); ok {
+				return b.compare(ctx, fld, flipComparison(e.op), e.lhs)
+			}
+		}
+
+	case *inOpPlanExpression:
+		fld, ok := b.column(e.lhs)
+		if !ok {
+			return nil, false, nil
+		}
+		list, ok := e.rhs.(*exprListPlanExpression)
+		if !ok || len(list.exprs) == 0 {
+			return nil, false, nil
+		}
+		preds := make([]bitmapPredicate, len(list.exprs))
+		for i, member := range list.exprs {
+			pred, ok, err := b.compare(ctx, fld, parser.EQ, member)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			preds[i] = pred
+		}
+		in := unionPredicates(preds...)
+		if e.op == parser.NOTIN {
+			return differencePredicates(notNullPredicate(fld), in), true, nil
+		}
+		return in, true, nil
+
+	case *betweenOpPlanExpression:
+		fld, ok := b.column(e.lhs)
+		if !ok {
+			return nil, false, nil
+		}
+		rng, ok := e.rhs.(*rangePlanExpression)
+		if !ok {
+			return nil, false, nil
+		}
+		lower, ok, err := b.compare(ctx, fld, parser.GE, rng.lhs)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		upper, ok, err := b.compare(ctx, fld, parser.LE, rng.rhs)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		between := intersectPredicates(lower, upper)
+		if e.op == parser.NOTBETWEEN {
+			return differencePredicates(notNullPredicate(fld), between), true, nil
+		}
+		return between, true, nil
+
+	case *callPlanExpression:
+		name := strings.ToUpper(e.name)
+		switch name {
+		case "SETCONTAINS", "SETCONTAINSANY", "SETCONTAINSALL":
+			if len(e.args) != 2 {
+				return nil, false, nil
+			}
+			fld, ok := b.column(e.args[0])
+			if !ok || !isSetField(fld) {
+				return nil, false, nil
+			}
+			values := []types.PlanExpression{e.args[1]}
+			if name != "SETCONTAINS" {
+				set, ok := e.args[1].(*exprSetLiteralPlanExpression)
+				if !ok || len(set.members) == 0 {
+					return nil, false, nil
+				}
+				values = set.members
+			}
+			preds := make([]bitmapPredicate, len(values))
+			for i, v := range values {
+				pred, ok, err := b.rowPredicate(ctx, fld, v)
+				if err != nil || !ok {
+					return nil, false, err
+				}
+				preds[i] = pred
+			}
+			if name == "SETCONTAINSALL" {
+				return intersectPredicates(preds...), true, nil
+			}
+			return unionPredicates(preds...), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// column returns the field for expr if it is a reference to a column of the
+// scan that is stored in a way bitmap operations can be used on
+func (b *bitmapFilterBuilder) column(expr types.PlanExpression) (*dax.Field, bool) {
+	fld, ok := b.field(expr)
+	if !ok {
+		return nil, false
+	}
+	if !fld.IsPrimaryKey() && !api.IsBSIField(fld) && fld.Options.NoStandardView {
+		return nil, false
+	}
+	return fld, true
+}
+
+// field returns the field for expr if it is a reference to a column of the
+// scan
+func (b *bitmapFilterBuilder) field(expr types.PlanExpression) (*dax.Field, bool) {
+	ref, ok := expr.(*qualifiedRefPlanExpression)
+	if !ok || ref.columnIndex < 0 || ref.columnIndex >= len(b.fields) {
+		return nil, false
+	}
+	fld := b.fields[ref.columnIndex]
+	if !strings.EqualFold(string(fld.Name), ref.columnName) {
+		return nil, false
+	}
+	return fld, true
+}
+
+// timeQuantumFilter returns a predicate for a call to RANGEQ on a time quantum
+// column of the scan. The predicate is true for the records that have a value
+// in any of the time views covering the range given by the from and to
+// arguments; a null bound leaves that end of the range open.
+func (b *bitmapFilterBuilder) timeQuantumFilter(expr types.PlanExpression) (bitmapPredicate, error) {
+	call, ok := expr.(*callPlanExpression)
+	if !ok || !strings.EqualFold(call.name, "RANGEQ") || len(call.args) != 3 {
+		return nil, sql3.NewErrQRangeInvalidUse(0, 0)
+	}
+	fld, ok := b.field(call.args[0])
+	if !ok || fld.Options.TimeQuantum == "" {
+		return nil, sql3.NewErrQRangeInvalidUse(0, 0)
+	}
+	from, err := rangeQBound(call.args[1])
+	if err != nil {
+		return nil, err
+	}
+	to, err := rangeQBound(call.args[2])
+	if err != nil {
+		return nil, err
+	}
+	q := fld.Options.TimeQuantum
+
+	return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+		views, err := api.FieldViews(tx, fld.Name)
+		if err != nil {
+			return nil, err
+		}
+		start, end := from, to
+		if start.IsZero() || end.IsZero() {
+			// clamp open ends of the range to the time views there are, rather
+			// than walk the views for all time
+			first, last, err := api.TimeViewsRange(views, q)
+			if err != nil {
+				return nil, err
+			}
+			if start.IsZero() {
+				start = first
+			}
+			if end.IsZero() {
+				end = last
+			}
+		}
+		exists := make(map[string]struct{}, len(views))
+		for _, v := range views {
+			exists[v] = struct{}{}
+		}
+		result := roaring.NewBitmap()
+		for _, v := range api.ViewsByTimeRange(start, end, q) {
+			if _, ok := exists[v]; !ok {
+				continue
+			}
+			bm, err := tx.RoaringBitmap(api.BitmapName(fld.Name, v))
+			if err != nil {
+				return nil, err
+			}
+			_ = api.ForEachBit(bm, func(row, col uint64) error {
+				result.DirectAdd(col)
+				return nil
+			})
+		}
+		return result.Intersect(records), nil
+	}, nil
+}
+
+// rangeQBound returns the value of a from or to argument of RANGEQ, which is a
+// zero time if the argument is null
+func rangeQBound(expr types.PlanExpression) (time.Time, error) {
+	v, err := expr.Evaluate(nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if v == nil {
+		return time.Time{}, nil
+	}
+	v, err = coerceValue(expr.Type(), parser.NewDataTypeTimestamp(), v, parser.Pos{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	tm, ok := v.(time.Time)
+	if !ok {
+		return time.Time{}, sql3.NewErrInternalf("unexpected value type '%T'", v)
+	}
+	return tm, nil
+}
+
+// compare returns a predicate for the comparison of the values of fld with a
+// literal
+func (b *bitmapFilterBuilder) compare(ctx context.Context, fld *dax.Field, op parser.Token, lit types.PlanExpression) (bitmapPredicate, bool, error) {
+	switch {
+	case fld.IsPrimaryKey():
+		if op != parser.EQ && op != parser.NE {
+			return nil, false, nil
+		}
+		id, found, ok, err := b.recordID(ctx, lit)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		eq := func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+			result := roaring.NewBitmap()
+			if found && id/api.ShardWidth == shard && records.Contains(id%api.ShardWidth) {
+				result.DirectAdd(id % api.ShardWidth)
+			}
+			return result, nil
+		}
+		if op == parser.NE {
+			return differencePredicates(notNullPredicate(fld), eq), true, nil
+		}
+		return eq, true, nil
+
+	case api.IsBSIField(fld):
+		v, ok := bsiValue(fld, lit)
+		if !ok {
+			return nil, false, nil
+		}
+		return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+			bm, err := tx.RoaringBitmap(api.BitmapName(fld.Name, api.FieldView(fld)))
+			if err != nil {
+				return nil, err
+			}
+			return bsiCompare(bm, op, v).Intersect(records), nil
+		}, true, nil
+
+	case isSetField(fld):
+		return nil, false, nil
+
+	default:
+		if op != parser.EQ && op != parser.NE {
+			return nil, false, nil
+		}
+		eq, ok, err := b.rowPredicate(ctx, fld, lit)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if op == parser.NE {
+			return differencePredicates(notNullPredicate(fld), eq), true, nil
+		}
+		return eq, true, nil
+	}
+}
+
+// rowPredicate returns a predicate for the records that have the literal value
+// lit in the row of fld holding that value
+func (b *bitmapFilterBuilder) rowPredicate(ctx context.Context, fld *dax.Field, lit types.PlanExpression) (bitmapPredicate, bool, error) {
+	row, found, ok, err := b.rowID(ctx, fld, lit)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+		if !found {
+			return roaring.NewBitmap(), nil
+		}
+		bm, err := tx.RoaringBitmap(api.BitmapName(fld.Name, api.FieldView(fld)))
+		if err != nil {
+			return nil, err
+		}
+		return api.Row(bm, row).Intersect(records), nil
+	}, true, nil
+}
+
+// notNullPredicate returns a predicate for the records that have a value for fld
+func notNullPredicate(fld *dax.Field) bitmapPredicate {
+	return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+		if fld.IsPrimaryKey() {
+			return records, nil
+		}
+		bm, err := tx.RoaringBitmap(api.BitmapName(fld.Name, api.FieldView(fld)))
+		if err != nil {
+			return nil, err
+		}
+		if api.IsBSIField(fld) {
+			return api.Row(bm, api.BSIExistsBit).Intersect(records), nil
+		}
+		result := roaring.NewBitmap()
+		_ = api.ForEachBit(bm, func(row, col uint64) error {
+			result.DirectAdd(col)
+			return nil
+		})
+		return result.Intersect(records), nil
+	}
+}
+
+// rowID returns the row of fld holding the literal value lit. found is false
+// if no record can have the value, and ok is false if lit is not a literal of a
+// type stored in rows of fld.
+func (b *bitmapFilterBuilder) rowID(ctx context.Context, fld *dax.Field, lit types.PlanExpression) (row uint64, found bool, ok bool, err error) {
+	switch fld.Type {
+	case dax.BaseTypeBool:
+		v, ok := lit.(*boolLiteralPlanExpression)
+		if !ok {
+			return 0, false, false, nil
+		}
+		if v.value {
+			return api.TrueRowID, true, true, nil
+		}
+		return api.FalseRowID, true, true, nil
+
+	case dax.BaseTypeID, dax.BaseTypeIDSet, dax.BaseTypeIDSetQ:
+		v, ok := lit.(*intLiteralPlanExpression)
+		if !ok {
+			return 0, false, false, nil
+		}
+		return uint64(v.value), v.value >= 0, true, nil
+
+	case dax.BaseTypeString, dax.BaseTypeStringSet, dax.BaseTypeStringSetQ:
+		v, ok := lit.(*stringLiteralPlanExpression)
+		if !ok {
+			return 0, false, false, nil
+		}
+		ids, err := b.planner.executor.FindFieldKeys(ctx, b.table.ID, fld.Name, v.value)
+		if err != nil {
+			return 0, false, false, err
+		}
+		id, found := ids[v.value]
+		return id, found, true, nil
+	}
+	return 0, false, false, nil
+}
+
+// recordID returns the id of the record with the literal key lit
+func (b *bitmapFilterBuilder) recordID(ctx context.Context, lit types.PlanExpression) (id uint64, found bool, ok bool, err error) {
+	if b.table.StringKeys() {
+		v, ok := lit.(*stringLiteralPlanExpression)
+		if !ok {
+			return 0, false, false, nil
+		}
+		ids, err := b.planner.executor.FindTableKeys(ctx, b.table.ID, v.value)
+		if err != nil {
+			return 0, false, false, err
+		}
+		id, found := ids[v.value]
+		return id, found, true, nil
+	}
+	v, ok := lit.(*intLiteralPlanExpression)
+	if !ok {
+		return 0, false, false, nil
+	}
+	return uint64(v.value), v.value >= 0, true, nil
+}
+
+// bsiValue returns the integer stored in the bit-sliced view of fld for the
+// literal value lit, or false if lit is not a literal that can be stored
+// exactly in fld
+func bsiValue(fld *dax.Field, lit types.PlanExpression) (int64, bool) {
+	switch fld.Type {
+	case dax.BaseTypeInt:
+		v, ok := lit.(*intLiteralPlanExpression)
+		if !ok {
+			return 0, false
+		}
+		return v.value, true
+
+	case dax.BaseTypeDecimal:
+		var d decimal.Decimal
+		switch v := lit.(type) {
+		case *intLiteralPlanExpression:
+			d = decimal.NewDecimal(v.value, 0)
+		case *floatLiteralPlanExpression:
+			var err error
+			d, err = decimal.ParseDecimal(v.value)
+			if err != nil {
+				return 0, false
+			}
+		default:
+			return 0, false
+		}
+		scale := fld.Options.Scale
+		if !d.SupportedByScale(scale) {
+			return 0, false
+		}
+		i := d.ToInt64(scale)
+		if !decimal.NewDecimal(i, scale).EqualTo(d) {
+			return 0, false
+		}
+		return i, true
+
+	case dax.BaseTypeTimestamp:
+		v, ok := lit.(*timestampLiteralPlanExpression)
+		if !ok {
+			return 0, false
+		}
+		i := api.TimestampToVal(fld, v.value)
+		ts, err := api.ValToTimestampField(fld, i)
+		if err != nil || !ts.Equal(v.value) {
+			return 0, false
+		}
+		return i, true
+	}
+	return 0, false
+}
+
+// flipComparison returns the comparison operator op with its operands swapped
+func flipComparison(op parser.Token) parser.Token {
+	switch op {
+	case parser.LT:
+		return parser.GT
+	case parser.LE:
+		return parser.GE
+	case parser.GT:
+		return parser.LT
+	case parser.GE:
+		return parser.LE
+	default:
+		return op
+	}
+}
+
+func intersectPredicates(preds ...bitmapPredicate) bitmapPredicate {
+	return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+		result := records
+		for _, pred := range preds {
+			cols, err := pred(tx, shard, records)
+			if err != nil {
+				return nil, err
+			}
+			result = result.Intersect(cols)
+		}
+		return result, nil
+	}
+}
+
+func unionPredicates(preds ...bitmapPredicate) bitmapPredicate {
+	return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+		result := roaring.NewBitmap()
+		for _, pred := range preds {
+			cols, err := pred(tx, shard, records)
+			if err != nil {
+				return nil, err
+			}
+			result = result.Union(cols)
+		}
+		return result, nil
+	}
+}
+
+func differencePredicates(pred, other bitmapPredicate) bitmapPredicate {
+	return func(tx api.Tx, shard uint64, records *roaring.Bitmap) (*roaring.Bitmap, error) {
+		cols, err := pred(tx, shard, records)
+		if err != nil {
+			return nil, err
+		}
+		otherCols, err := other(tx, shard, records)
+		if err != nil {
+			return nil, err
+		}
+		return cols.Difference(otherCols), nil
+	}
+}
+
+// bsiCompare returns the shard-relative columns of a bit-sliced bitmap whose
+// values compare with v using the comparison operator op
+func bsiCompare(bm *roaring.Bitmap, op parser.Token, v int64) *roaring.Bitmap {
+	exists := api.Row(bm, api.BSIExistsBit)
+	sign := api.Row(bm, api.BSISignBit)
+	positive := exists.Difference(sign)
+	negative := exists.Intersect(sign)
+
+	slices := bsiSlices(bm)
+
+	var lt, eq, gt *roaring.Bitmap
+	if v >= 0 {
+		plt, peq, pgt := bsiCompareMagnitude(positive, slices, uint64(v))
+		lt, eq, gt = negative.Union(plt), peq, pgt
+	} else {
+		// the magnitude of math.MinInt64 is also correct as a uint64. For
+		// negative values, a larger magnitude is a smaller value.
+		nlt, neq, ngt := bsiCompareMagnitude(negative, slices, uint64(-v))
+		lt, eq, gt = ngt, neq, positive.Union(nlt)
+	}
+
+	switch op {
+	case parser.EQ:
+		return eq
+	case parser.NE:
+		return lt.Union(gt)
+	case parser.LT:
+		return lt
+	case parser.LE:
+		return lt.Union(eq)
+	case parser.GT:
+		return gt
+	case parser.GE:
+		return gt.Union(eq)
+	default:
+		return roaring.NewBitmap()
+	}
+}
+
+// bsiCompareMagnitude splits the columns in set into those whose magnitude,
+// held in slices, is less than, equal to and greater than m
+func bsiCompareMagnitude(set *roaring.Bitmap, slices []*roaring.Bitmap, m uint64) (lt, eq, gt *roaring.Bitmap) {
+	gt = roaring.NewBitmap()
+	eq = set
+	depth := len(slices)
+	if n := bits.Len64(m); n > depth {
+		depth = n
+	}
+	for i := depth - 1; i >= 0; i-- {
+		slice := roaring.NewBitmap()
+		if i < len(slices) {
+			slice = slices[i]
+		}
+		if m>>uint(i)&1 == 1 {
+			eq = eq.Intersect(slice)
+		} else {
+			gt = gt.Union(eq.Intersect(slice))
+			eq = eq.Difference(slice)
+		}
+	}
+	return set.Difference(eq, gt), eq, gt
+}
+
+// bsiSlices returns the magnitude rows of a bit-sliced bitmap, least
+// significant first
+func bsiSlices(bm *roaring.Bitmap) []*roaring.Bitmap {
+	slices := make([]*roaring.Bitmap, api.BSIBitDepth(bm))
+	for i := range slices {
+		slices[i] = api.Row(bm, api.BSIOffsetBit+uint64(i))
+	}
+	return slices
+}
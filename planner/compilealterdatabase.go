@@ -1,3 +1,53 @@
 // Copyright 2021 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileAlterDatabaseStatement compiles a parser.AlterDatabaseStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileAlterDatabaseStatement(stmt *parser.AlterDatabaseStatement) (types.PlanOperator, error) {
+	databaseName := strings.ToLower(parser.IdentName(stmt.Name))
+
+	switch o := stmt.Option.(type) {
+	case *parser.UnitsOption:
+		units, err := databaseUnits(o)
+		if err != nil {
+			return nil, err
+		}
+		op := NewPlanOpAlterDatabase(p, databaseName, dax.DatabaseOptionWorkersMin, strconv.Itoa(units))
+		return NewPlanOpQuery(p, op, p.sql), nil
+
+	default:
+		return nil, sql3.NewErrInternalf("unexpected database option type '%T'", stmt.Option)
+	}
+}
+
+// analyzeAlterDatabaseStatement analyzes a parser.AlterDatabaseStatement,
+// checking the database exists and the option being set
+func (p *ExecutionPlanner) analyzeAlterDatabaseStatement(ctx context.Context, stmt *parser.AlterDatabaseStatement) error {
+	databaseName := strings.ToLower(parser.IdentName(stmt.Name))
+	if _, err := p.schemaAPI.DatabaseByName(ctx, dax.DatabaseName(databaseName)); err != nil {
+		if isDatabaseNotFoundError(err) {
+			return sql3.NewErrDatabaseNotFound(stmt.Name.NamePos.Line, stmt.Name.NamePos.Column, databaseName)
+		}
+		return err
+	}
+
+	switch o := stmt.Option.(type) {
+	case *parser.UnitsOption:
+		_, err := databaseUnits(o)
+		return err
+
+	default:
+		return sql3.NewErrInvalidDatabaseOption(stmt.With.Line, stmt.With.Column, stmt.Option.String())
+	}
+}
@@ -1,3 +1,83 @@
 // Copyright 2021 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileAlterTableStatement compiles a parser.AlterTableStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileAlterTableStatement(stmt *parser.AlterTableStatement) (types.PlanOperator, error) {
+	tableName := strings.ToLower(parser.IdentName(stmt.Name))
+
+	var op *PlanOpAlterTable
+	switch {
+	case stmt.Add.IsValid():
+		fld, err := columnDefinitionField(stmt.ColumnDef)
+		if err != nil {
+			return nil, err
+		}
+		op = NewPlanOpAlterTable(p, tableName, alterOpAdd, fld, "")
+
+	case stmt.Drop.IsValid():
+		columnName := strings.ToLower(parser.IdentName(stmt.DropColumnName))
+		op = NewPlanOpAlterTable(p, tableName, alterOpDrop, nil, columnName)
+
+	default:
+		return nil, sql3.NewErrInternalf("unexpected alter table operation")
+	}
+	return NewPlanOpQuery(p, op, p.sql), nil
+}
+
+// analyzeAlterTableStatement analyzes a parser.AlterTableStatement, checking
+// the column being added or dropped against the table
+func (p *ExecutionPlanner) analyzeAlterTableStatement(ctx context.Context, stmt *parser.AlterTableStatement) error {
+	if stmt.Rename.IsValid() {
+		return sql3.NewErrUnsupported(stmt.Rename.Line, stmt.Rename.Column, false, "ALTER TABLE RENAME")
+	}
+
+	tableName := strings.ToLower(parser.IdentName(stmt.Name))
+	tbl, err := p.schemaAPI.TableByName(ctx, dax.TableName(tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return sql3.NewErrTableNotFound(stmt.Name.NamePos.Line, stmt.Name.NamePos.Column, tableName)
+		}
+		return err
+	}
+
+	switch {
+	case stmt.Add.IsValid():
+		col := stmt.ColumnDef
+		columnName := strings.ToLower(parser.IdentName(col.Name))
+		if columnName == string(dax.PrimaryKeyFieldName) {
+			return sql3.NewErrTableIDColumnAlter(col.Name.NamePos.Line, col.Name.NamePos.Column)
+		}
+		if _, ok := tbl.Field(dax.FieldName(columnName)); ok {
+			return sql3.NewErrDuplicateColumn(col.Name.NamePos.Line, col.Name.NamePos.Column, columnName)
+		}
+		if _, err := columnDefinitionField(col); err != nil {
+			return err
+		}
+
+	case stmt.Drop.IsValid():
+		col := stmt.DropColumnName
+		columnName := strings.ToLower(parser.IdentName(col))
+		fld, ok := tbl.Field(dax.FieldName(columnName))
+		if !ok {
+			return sql3.NewErrColumnNotFound(col.NamePos.Line, col.NamePos.Column, columnName)
+		}
+		if fld.IsPrimaryKey() {
+			return sql3.NewErrTableIDColumnDrop(col.NamePos.Line, col.NamePos.Column)
+		}
+
+	default:
+		return sql3.NewErrInternalf("unexpected alter table operation")
+	}
+	return nil
+}
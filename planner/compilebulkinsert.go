@@ -1,3 +1,237 @@
 // Copyright 2022 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// defaultBulkInsertBatchSize is the batch size used when BATCHSIZE is not
+// specified
+const defaultBulkInsertBatchSize = 1000
+
+// bulkInsertMappedColumn specifies how a value is read from a record of the
+// data source
+type bulkInsertMappedColumn struct {
+	// position of the value in a CSV record
+	position int
+	// path selecting the value from an NDJSON line
+	path *jsonPath
+	// type the value is converted to
+	dataType parser.ExprDataType
+}
+
+// bulkInsertOptions holds the options of a BULK INSERT statement
+type bulkInsertOptions struct {
+	sourceData         string
+	format             string
+	input              string
+	batchSize          int
+	rowsLimit          int
+	hasHeaderRow       bool
+	allowMissingValues bool
+
+	mapExpressions       []*bulkInsertMappedColumn
+	transformExpressions []types.PlanExpression
+}
+
+// compileBulkInsertStatement compiles a parser.BulkInsertStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileBulkInsertStatement(ctx context.Context, stmt *parser.BulkInsertStatement) (types.PlanOperator, error) {
+	tableName := strings.ToLower(parser.IdentName(stmt.Table))
+	tbl, err := p.insertTable(ctx, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &bulkInsertOptions{
+		sourceData: stmt.DataSource.(*parser.StringLit).Value,
+		format:     strings.ToUpper(stmt.Format.(*parser.StringLit).Value),
+		input:      strings.ToUpper(stmt.Input.(*parser.StringLit).Value),
+		batchSize:  defaultBulkInsertBatchSize,
+
+		hasHeaderRow:       stmt.HeaderRow != nil,
+		allowMissingValues: stmt.AllowMissingValues != nil,
+	}
+	if stmt.BatchSize != nil {
+		options.batchSize, _ = strconv.Atoi(stmt.BatchSize.(*parser.IntegerLit).Value)
+	}
+	if stmt.RowsLimit != nil {
+		options.rowsLimit, _ = strconv.Atoi(stmt.RowsLimit.(*parser.IntegerLit).Value)
+	}
+
+	for _, m := range stmt.MapList {
+		dataType, err := dataTypeFromParserType(m.Type)
+		if err != nil {
+			return nil, err
+		}
+		mc := &bulkInsertMappedColumn{
+			dataType: dataType,
+		}
+		switch options.format {
+		case "CSV":
+			mc.position, _ = strconv.Atoi(m.MapExpr.(*parser.IntegerLit).Value)
+		case "NDJSON":
+			mc.path, err = parseJSONPath(m.MapExpr.(*parser.StringLit).Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		options.mapExpressions = append(options.mapExpressions, mc)
+	}
+
+	for _, t := range stmt.TransformList {
+		expr, err := p.compileExpr(t)
+		if err != nil {
+			return nil, err
+		}
+		options.transformExpressions = append(options.transformExpressions, expr)
+	}
+
+	targetColumns := insertTargetColumns(tableName, tbl, stmt.Columns)
+
+	return NewPlanOpQuery(p, NewPlanOpBulkInsert(p, tableName, targetColumns, options, stmt.Replace.IsValid()), p.sql), nil
+}
+
+// analyzeBulkInsertStatement analyzes a parser.BulkInsertStatement, checking
+// the options, maps and transforms against the table
+func (p *ExecutionPlanner) analyzeBulkInsertStatement(ctx context.Context, stmt *parser.BulkInsertStatement) error {
+	tbl, err := p.insertTable(ctx, stmt.Table)
+	if err != nil {
+		return err
+	}
+
+	columns, targetTypes, err := analyzeInsertColumns(tbl, stmt.Table, stmt.Columns)
+	if err != nil {
+		return err
+	}
+	stmt.Columns = columns
+
+	// data source has to be a string literal
+	if _, ok := stmt.DataSource.(*parser.StringLit); !ok {
+		return sql3.NewErrStringLiteral(stmt.DataSource.Pos().Line, stmt.DataSource.Pos().Column)
+	}
+
+	// format
+	if stmt.Format == nil {
+		return sql3.NewErrFormatSpecifierExpected(stmt.With.Line, stmt.With.Column)
+	}
+	format, ok := stmt.Format.(*parser.StringLit)
+	if !ok {
+		return sql3.NewErrStringLiteral(stmt.Format.Pos().Line, stmt.Format.Pos().Column)
+	}
+	formatName := strings.ToUpper(format.Value)
+	switch formatName {
+	case "CSV", "NDJSON":
+	default:
+		return sql3.NewErrInvalidFormatSpecifier(format.ValuePos.Line, format.ValuePos.Column, format.Value)
+	}
+
+	// input
+	if stmt.Input == nil {
+		return sql3.NewErrInputSpecifierExpected(stmt.With.Line, stmt.With.Column)
+	}
+	input, ok := stmt.Input.(*parser.StringLit)
+	if !ok {
+		return sql3.NewErrStringLiteral(stmt.Input.Pos().Line, stmt.Input.Pos().Column)
+	}
+	switch strings.ToUpper(input.Value) {
+	case "FILE", "STREAM":
+	default:
+		return sql3.NewErrInvalidInputSpecifier(input.ValuePos.Line, input.ValuePos.Column, input.Value)
+	}
+
+	// a header row only makes sense for CSV
+	if stmt.HeaderRow != nil && formatName != "CSV" {
+		return sql3.NewErrUnsupported(stmt.HeaderRow.Pos().Line, stmt.HeaderRow.Pos().Column, true, "HEADER_ROW for format '"+format.Value+"'")
+	}
+
+	// batch size and rows limit
+	if stmt.BatchSize != nil {
+		lit, ok := stmt.BatchSize.(*parser.IntegerLit)
+		if !ok {
+			return sql3.NewErrIntegerLiteral(stmt.BatchSize.Pos().Line, stmt.BatchSize.Pos().Column)
+		}
+		batchSize, err := strconv.Atoi(lit.Value)
+		if err != nil {
+			return sql3.NewErrIntegerLiteral(lit.ValuePos.Line, lit.ValuePos.Column)
+		}
+		if batchSize <= 0 {
+			return sql3.NewErrInvalidBatchSize(lit.ValuePos.Line, lit.ValuePos.Column, batchSize)
+		}
+	}
+	if stmt.RowsLimit != nil {
+		lit, ok := stmt.RowsLimit.(*parser.IntegerLit)
+		if !ok {
+			return sql3.NewErrIntegerLiteral(stmt.RowsLimit.Pos().Line, stmt.RowsLimit.Pos().Column)
+		}
+		if _, err := strconv.Atoi(lit.Value); err != nil {
+			return sql3.NewErrIntegerLiteral(lit.ValuePos.Line, lit.ValuePos.Column)
+		}
+	}
+
+	// check the maps
+	mapTypes := make([]parser.ExprDataType, len(stmt.MapList))
+	for i, m := range stmt.MapList {
+		dataType, err := dataTypeFromParserType(m.Type)
+		if err != nil {
+			return err
+		}
+		mapTypes[i] = dataType
+
+		switch formatName {
+		case "CSV":
+			// the map expression is the position of the value in the record
+			lit, ok := m.MapExpr.(*parser.IntegerLit)
+			if !ok {
+				return sql3.NewErrIntegerLiteral(m.MapExpr.Pos().Line, m.MapExpr.Pos().Column)
+			}
+			if pos, err := strconv.Atoi(lit.Value); err != nil || pos < 0 {
+				return sql3.NewErrIntegerLiteral(lit.ValuePos.Line, lit.ValuePos.Column)
+			}
+
+		case "NDJSON":
+			// the map expression is a JSONPath expression selecting the value
+			lit, ok := m.MapExpr.(*parser.StringLit)
+			if !ok {
+				return sql3.NewErrStringLiteral(m.MapExpr.Pos().Line, m.MapExpr.Pos().Column)
+			}
+			if _, err := parseJSONPath(lit.Value); err != nil {
+				return sql3.NewErrEvaluatingJSONPathExpr(lit.ValuePos.Line, lit.ValuePos.Column, lit.Value, "", err.Error())
+			}
+		}
+	}
+
+	// without a transform, the maps are written to the columns directly
+	if len(stmt.TransformList) == 0 {
+		if len(stmt.MapList) != len(stmt.Columns) {
+			return sql3.NewErrInsertExprTargetCountMismatch(stmt.MapLparen.Line, stmt.MapLparen.Column)
+		}
+		for i, m := range stmt.MapList {
+			if !typesAreAssignmentCompatible(targetTypes[i], mapTypes[i]) {
+				return sql3.NewErrTypeAssignmentIncompatible(m.MapExpr.Pos().Line, m.MapExpr.Pos().Column, mapTypes[i].TypeDescription(), targetTypes[i].TypeDescription())
+			}
+		}
+		return nil
+	}
+
+	if len(stmt.TransformList) != len(stmt.Columns) {
+		return sql3.NewErrInsertExprTargetCountMismatch(stmt.TransformLparen.Line, stmt.TransformLparen.Column)
+	}
+	for i, t := range stmt.TransformList {
+		expr, err := p.analyzeExpression(ctx, t, stmt)
+		if err != nil {
+			return err
+		}
+		if !typesAreAssignmentCompatible(targetTypes[i], expr.DataType()) {
+			return sql3.NewErrTypeAssignmentIncompatible(expr.Pos().Line, expr.Pos().Column, expr.DataType().TypeDescription(), targetTypes[i].TypeDescription())
+		}
+		stmt.TransformList[i] = expr
+	}
+	return nil
+}
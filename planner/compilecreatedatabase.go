@@ -1,3 +1,76 @@
 // Copyright 2021 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileCreateDatabaseStatement compiles a parser.CreateDatabaseStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileCreateDatabaseStatement(stmt *parser.CreateDatabaseStatement) (types.PlanOperator, error) {
+	db, err := createDatabaseDefinition(stmt)
+	if err != nil {
+		return nil, err
+	}
+	failIfExists := !stmt.IfNotExists.IsValid()
+	return NewPlanOpQuery(p, NewPlanOpCreateDatabase(p, db, failIfExists), p.sql), nil
+}
+
+// analyzeCreateDatabaseStatement analyzes a parser.CreateDatabaseStatement,
+// checking the database options
+func (p *ExecutionPlanner) analyzeCreateDatabaseStatement(stmt *parser.CreateDatabaseStatement) error {
+	_, err := createDatabaseDefinition(stmt)
+	return err
+}
+
+// createDatabaseDefinition returns the database defined by a CREATE DATABASE
+// statement
+func createDatabaseDefinition(stmt *parser.CreateDatabaseStatement) (*dax.Database, error) {
+	db := &dax.Database{
+		Name: dax.DatabaseName(strings.ToLower(parser.IdentName(stmt.Name))),
+	}
+	for _, option := range stmt.Options {
+		switch o := option.(type) {
+		case *parser.UnitsOption:
+			units, err := databaseUnits(o)
+			if err != nil {
+				return nil, err
+			}
+			db.Options.WorkersMin = units
+			db.Options.WorkersMax = units
+
+		case *parser.CommentOption:
+			lit, ok := o.Expr.(*parser.StringLit)
+			if !ok {
+				return nil, sql3.NewErrStringLiteral(o.Expr.Pos().Line, o.Expr.Pos().Column)
+			}
+			db.Description = lit.Value
+
+		default:
+			return nil, sql3.NewErrInternalf("unexpected database option type '%T'", option)
+		}
+	}
+	return db, nil
+}
+
+// databaseUnits returns the value of a UNITS option
+func databaseUnits(opt *parser.UnitsOption) (int, error) {
+	lit, ok := opt.Expr.(*parser.IntegerLit)
+	if !ok {
+		return 0, sql3.NewErrIntegerLiteral(opt.Expr.Pos().Line, opt.Expr.Pos().Column)
+	}
+	units, err := strconv.ParseInt(lit.Value, 10, 64)
+	if err != nil {
+		return 0, sql3.NewErrIntegerLiteral(lit.ValuePos.Line, lit.ValuePos.Column)
+	}
+	if units < 0 || units > 10000 {
+		return 0, sql3.NewErrInvalidUnitsValue(lit.ValuePos.Line, lit.ValuePos.Column, units)
+	}
+	return int(units), nil
+}
@@ -1,3 +1,315 @@
 // Copyright 2021 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// defaultCacheSize is the cache size used when a CACHETYPE constraint does not
+// specify a SIZE
+const defaultCacheSize = 50000
+
+// compileCreateTableStatement compiles a parser.CreateTableStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileCreateTableStatement(stmt *parser.CreateTableStatement) (types.PlanOperator, error) {
+	tbl, err := createTableDefinition(stmt)
+	if err != nil {
+		return nil, err
+	}
+	failIfExists := !stmt.IfNotExists.IsValid()
+	return NewPlanOpQuery(p, NewPlanOpCreateTable(p, tbl, failIfExists), p.sql), nil
+}
+
+// analyzeCreateTableStatement analyzes a parser.CreateTableStatement, checking
+// the column definitions, their constraints and the table options
+func (p *ExecutionPlanner) analyzeCreateTableStatement(stmt *parser.CreateTableStatement) error {
+	if stmt.Select != nil {
+		return sql3.NewErrUnsupported(stmt.As.Line, stmt.As.Column, false, "CREATE TABLE AS")
+	}
+	_, err := createTableDefinition(stmt)
+	return err
+}
+
+// createTableDefinition returns the table defined by a CREATE TABLE statement
+func createTableDefinition(stmt *parser.CreateTableStatement) (*dax.Table, error) {
+	tbl := dax.NewTable(dax.TableName(strings.ToLower(parser.IdentName(stmt.Name))))
+	tbl.PartitionN = dax.DefaultPartitionN
+
+	// table options
+	for _, option := range stmt.Options {
+		switch o := option.(type) {
+		case *parser.KeyPartitionsOption:
+			lit, ok := o.Expr.(*parser.IntegerLit)
+			if !ok {
+				return nil, sql3.NewErrIntegerLiteral(o.Expr.Pos().Line, o.Expr.Pos().Column)
+			}
+			i, err := strconv.ParseInt(lit.Value, 10, 64)
+			if err != nil {
+				return nil, sql3.NewErrIntegerLiteral(lit.ValuePos.Line, lit.ValuePos.Column)
+			}
+			if i < 1 || i > 10000 {
+				return nil, sql3.NewErrInvalidKeyPartitionsValue(lit.ValuePos.Line, lit.ValuePos.Column, i)
+			}
+			tbl.PartitionN = int(i)
+
+		case *parser.CommentOption:
+			lit, ok := o.Expr.(*parser.StringLit)
+			if !ok {
+				return nil, sql3.NewErrStringLiteral(o.Expr.Pos().Line, o.Expr.Pos().Column)
+			}
+			tbl.Description = lit.Value
+
+		default:
+			return nil, sql3.NewErrInternalf("unexpected table option type '%T'", option)
+		}
+	}
+
+	// columns
+	idFound := false
+	seen := make(map[string]struct{})
+	for _, col := range stmt.Columns {
+		columnName := strings.ToLower(parser.IdentName(col.Name))
+		if _, ok := seen[columnName]; ok {
+			return nil, sql3.NewErrDuplicateColumn(col.Name.NamePos.Line, col.Name.NamePos.Column, columnName)
+		}
+		seen[columnName] = struct{}{}
+
+		fld, err := columnDefinitionField(col)
+		if err != nil {
+			return nil, err
+		}
+		if fld.IsPrimaryKey() {
+			idFound = true
+		}
+		tbl.Fields = append(tbl.Fields, fld)
+	}
+	if !idFound {
+		return nil, sql3.NewErrTableMustHaveIDColumn(stmt.Create.Line, stmt.Create.Column)
+	}
+	if !tbl.HasValidPrimaryKey() {
+		return nil, sql3.NewErrTableIDColumnType(stmt.Create.Line, stmt.Create.Column)
+	}
+	return tbl, nil
+}
+
+// columnDefinitionField returns the field defined by a column definition,
+// applying the column constraints to the field options
+func columnDefinitionField(col *parser.ColumnDefinition) (*dax.Field, error) {
+	columnName := strings.ToLower(parser.IdentName(col.Name))
+	typeName := strings.ToLower(parser.IdentName(col.Type.Name))
+
+	dataType, err := dataTypeFromParserType(col.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	fld := &dax.Field{
+		Name: dax.FieldName(columnName),
+		Type: dax.BaseType(typeName),
+		Options: dax.FieldOptions{
+			TrackExistence: true,
+		},
+	}
+
+	// the primary key has to be an id or a string, and can't be constrained
+	if fld.IsPrimaryKey() {
+		switch dataType.(type) {
+		case *parser.DataTypeID, *parser.DataTypeString:
+		default:
+			return nil, sql3.NewErrTableIDColumnType(col.Type.Name.NamePos.Line, col.Type.Name.NamePos.Column)
+		}
+		if len(col.Constraints) > 0 {
+			return nil, sql3.NewErrTableIDColumnConstraints(col.Name.NamePos.Line, col.Name.NamePos.Column)
+		}
+		return fld, nil
+	}
+
+	// defaults
+	switch t := dataType.(type) {
+	case *parser.DataTypeInt:
+		fld.Options.Min = math.MinInt64
+		fld.Options.Max = math.MaxInt64
+
+	case *parser.DataTypeDecimal:
+		fld.Options.Scale = t.Scale
+		fld.Options.Min = math.MinInt64
+		fld.Options.Max = math.MaxInt64
+
+	case *parser.DataTypeTimestamp:
+		fld.Options.TimeUnit = api.TimeUnitSeconds
+	}
+
+	// constraints
+	seen := make(map[parser.Token]struct{})
+	for _, constraint := range col.Constraints {
+		var tok parser.Token
+		var pos parser.Pos
+		switch c := constraint.(type) {
+		case *parser.MinConstraint:
+			tok, pos = parser.MIN, c.Min
+		case *parser.MaxConstraint:
+			tok, pos = parser.MAX, c.Max
+		case *parser.CacheTypeConstraint:
+			tok, pos = parser.CACHETYPE, c.CacheType
+		case *parser.TimeUnitConstraint:
+			tok, pos = parser.TIMEUNIT, c.TimeUnit
+		case *parser.TimeQuantumConstraint:
+			tok, pos = parser.TIMEQUANTUM, c.TimeQuantum
+		default:
+			return nil, sql3.NewErrInternalf("unexpected constraint type '%T'", constraint)
+		}
+		if _, ok := seen[tok]; ok {
+			return nil, sql3.NewErrConflictingColumnConstraint(pos.Line, pos.Column, tok, tok)
+		}
+		seen[tok] = struct{}{}
+
+		switch c := constraint.(type) {
+		case *parser.MinConstraint:
+			v, err := constraintBound(c.Expr, dataType)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, sql3.NewErrBadColumnConstraint(pos.Line, pos.Column, "MIN", typeName)
+			}
+			fld.Options.Min = *v
+
+		case *parser.MaxConstraint:
+			v, err := constraintBound(c.Expr, dataType)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, sql3.NewErrBadColumnConstraint(pos.Line, pos.Column, "MAX", typeName)
+			}
+			fld.Options.Max = *v
+
+		case *parser.CacheTypeConstraint:
+			switch dataType.(type) {
+			case *parser.DataTypeID, *parser.DataTypeIDSet, *parser.DataTypeString, *parser.DataTypeStringSet:
+			default:
+				return nil, sql3.NewErrBadColumnConstraint(pos.Line, pos.Column, "CACHETYPE", typeName)
+			}
+			fld.Options.CacheType = c.CacheTypeValue
+			fld.Options.CacheSize = defaultCacheSize
+			if c.SizeExpr != nil {
+				lit, ok := c.SizeExpr.(*parser.IntegerLit)
+				if !ok {
+					return nil, sql3.NewErrIntegerLiteral(c.SizeExpr.Pos().Line, c.SizeExpr.Pos().Column)
+				}
+				size, err := strconv.ParseUint(lit.Value, 10, 32)
+				if err != nil {
+					return nil, sql3.NewErrIntegerLiteral(lit.ValuePos.Line, lit.ValuePos.Column)
+				}
+				fld.Options.CacheSize = uint32(size)
+			}
+
+		case *parser.TimeUnitConstraint:
+			if _, ok := dataType.(*parser.DataTypeTimestamp); !ok {
+				return nil, sql3.NewErrBadColumnConstraint(pos.Line, pos.Column, "TIMEUNIT", typeName)
+			}
+			lit, ok := c.Expr.(*parser.StringLit)
+			if !ok {
+				return nil, sql3.NewErrStringLiteral(c.Expr.Pos().Line, c.Expr.Pos().Column)
+			}
+			if !api.IsValidTimeUnit(lit.Value) {
+				return nil, sql3.NewErrInvalidTimeUnit(lit.ValuePos.Line, lit.ValuePos.Column, lit.Value)
+			}
+			fld.Options.TimeUnit = lit.Value
+
+		case *parser.TimeQuantumConstraint:
+			switch dataType.(type) {
+			case *parser.DataTypeIDSetQuantum, *parser.DataTypeStringSetQuantum:
+			default:
+				return nil, sql3.NewErrBadColumnConstraint(pos.Line, pos.Column, "TIMEQUANTUM", typeName)
+			}
+			lit, ok := c.Expr.(*parser.StringLit)
+			if !ok {
+				return nil, sql3.NewErrStringLiteral(c.Expr.Pos().Line, c.Expr.Pos().Column)
+			}
+			quantum := dax.TimeQuantum(strings.ToUpper(lit.Value))
+			if !quantum.Valid() {
+				return nil, sql3.NewErrInvalidTimeQuantum(lit.ValuePos.Line, lit.ValuePos.Column, lit.Value)
+			}
+			fld.Options.TimeQuantum = quantum
+
+			if c.TtlExpr != nil {
+				lit, ok := c.TtlExpr.(*parser.StringLit)
+				if !ok {
+					return nil, sql3.NewErrStringLiteral(c.TtlExpr.Pos().Line, c.TtlExpr.Pos().Column)
+				}
+				ttl, err := time.ParseDuration(lit.Value)
+				if err != nil {
+					return nil, sql3.NewErrInvalidDuration(lit.ValuePos.Line, lit.ValuePos.Column, lit.Value)
+				}
+				fld.Options.TTL = ttl
+			}
+		}
+	}
+	if fld.Options.Min > fld.Options.Max {
+		return nil, sql3.NewErrConflictingColumnConstraint(col.Name.NamePos.Line, col.Name.NamePos.Column, parser.MIN, parser.MAX)
+	}
+	return fld, nil
+}
+
+// constraintBound returns the value of a MIN or MAX constraint expression for
+// a column of type dataType, or nil if the column type can't be bounded. The
+// value is a literal, optionally negated; for decimal columns it is returned
+// scaled to the scale of the column.
+func constraintBound(expr parser.Expr, dataType parser.ExprDataType) (*int64, error) {
+	negate := false
+	if unary, ok := expr.(*parser.UnaryExpr); ok && unary.Op == parser.MINUS {
+		negate = true
+		expr = unary.X
+	}
+
+	switch t := dataType.(type) {
+	case *parser.DataTypeInt:
+		lit, ok := expr.(*parser.IntegerLit)
+		if !ok {
+			return nil, sql3.NewErrIntegerLiteral(expr.Pos().Line, expr.Pos().Column)
+		}
+		value := lit.Value
+		if negate {
+			value = "-" + value
+		}
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, sql3.NewErrValueOutOfRange(lit.ValuePos.Line, lit.ValuePos.Column, value)
+		}
+		return &i, nil
+
+	case *parser.DataTypeDecimal:
+		var value string
+		switch lit := expr.(type) {
+		case *parser.IntegerLit:
+			value = lit.Value
+		case *parser.FloatLit:
+			value = lit.Value
+		default:
+			return nil, sql3.NewErrLiteralExpected(expr.Pos().Line, expr.Pos().Column)
+		}
+		if negate {
+			value = "-" + value
+		}
+		d, err := decimal.ParseDecimal(value)
+		if err != nil || !d.SupportedByScale(t.Scale) {
+			return nil, sql3.NewErrValueOutOfRange(expr.Pos().Line, expr.Pos().Column, value)
+		}
+		i := d.ToInt64(t.Scale)
+		return &i, nil
+
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// cteScope is a common table expression that can be referred to by name
+type cteScope struct {
+	cte  *parser.CTE
+	name string
+
+	// the output columns of the expression, nil until they are known
+	columns []*parser.SourceOutputColumn
+
+	// set while the select statement of the expression is analyzed
+	analyzing bool
+}
+
+// lookupCTE returns the innermost common table expression in scope with a
+// name, or nil if there isn't one
+func (p *ExecutionPlanner) lookupCTE(name string) *cteScope {
+	for i := len(p.ctes) - 1; i >= 0; i-- {
+		if strings.EqualFold(p.ctes[i].name, name) {
+			return p.ctes[i]
+		}
+	}
+	return nil
+}
+
+// analyzeWithClause analyzes the common table expressions of a WITH clause
+// and puts them in scope. Each expression can refer to the ones before it and,
+// in a WITH RECURSIVE clause, to itself.
+func (p *ExecutionPlanner) analyzeWithClause(ctx context.Context, clause *parser.WithClause) error {
+	for _, cte := range clause.CTEs {
+		scope := &cteScope{
+			cte:  cte,
+			name: strings.ToLower(parser.IdentName(cte.TableName)),
+		}
+		stmt := cte.Select
+
+		if !clause.Recursive.IsValid() {
+			if _, err := p.analyzeSelectStatement(ctx, stmt); err != nil {
+				return err
+			}
+			if err := p.analyzeCTEColumns(scope); err != nil {
+				return err
+			}
+			p.ctes = append(p.ctes, scope)
+			continue
+		}
+
+		// the columns of a recursive expression are those of its first
+		// select statement, which is analyzed before the statements
+		// compounded with it
+		p.ctes = append(p.ctes, scope)
+		scope.analyzing = true
+		compound := stmt.Compound
+		stmt.Compound = nil
+		_, err := p.analyzeSelectStatement(ctx, stmt)
+		stmt.Compound = compound
+		if err != nil {
+			return err
+		}
+		if err := p.analyzeCTEColumns(scope); err != nil {
+			return err
+		}
+		if compound != nil {
+			if err := p.analyzeCompoundSelectStatement(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		scope.analyzing = false
+
+		if cte.SelfReference {
+			if compound == nil || !stmt.Union.IsValid() || len(stmt.OrderingTerms) > 0 || stmt.Limit.IsValid() {
+				return sql3.NewErrInvalidRecursiveCTE(cte.TableName.NamePos.Line, cte.TableName.NamePos.Column, scope.name)
+			}
+		}
+	}
+	return nil
+}
+
+// analyzeCTEColumns sets the output columns of a common table expression from
+// the columns of its select statement and its optional list of column names
+func (p *ExecutionPlanner) analyzeCTEColumns(scope *cteScope) error {
+	cte := scope.cte
+	columns := cte.Select.PossibleOutputColumns()
+	if len(cte.Columns) > 0 && len(cte.Columns) != len(columns) {
+		return sql3.NewErrCTEColumnCountMismatch(cte.TableName.NamePos.Line, cte.TableName.NamePos.Column, scope.name, len(columns), len(cte.Columns))
+	}
+	scope.columns = make([]*parser.SourceOutputColumn, len(columns))
+	for i, oc := range columns {
+		name := oc.ColumnName
+		if len(cte.Columns) > 0 {
+			name = parser.IdentName(cte.Columns[i])
+		}
+		scope.columns[i] = &parser.SourceOutputColumn{
+			TableName:   scope.name,
+			ColumnName:  name,
+			ColumnIndex: i,
+			Datatype:    oc.Datatype,
+		}
+	}
+	return nil
+}
+
+// compileWithSelectStatement compiles a select statement with a WITH clause.
+// Each common table expression is compiled once, and the query reads its rows
+// through PlanOpCTEScan operators.
+func (p *ExecutionPlanner) compileWithSelectStatement(stmt *parser.SelectStatement, isSubquery bool) (types.PlanOperator, error) {
+	bindings := make([]*cteBinding, 0, len(stmt.WithClause.CTEs))
+	ctes := make([]types.PlanOperator, 0, len(stmt.WithClause.CTEs))
+	for _, cte := range stmt.WithClause.CTEs {
+		binding, op, err := p.compileCTE(cte)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, binding)
+		ctes = append(ctes, op)
+	}
+
+	body := *stmt
+	body.WithClause = nil
+	op, err := p.compileSelectStatement(&body, isSubquery)
+	if err != nil {
+		return nil, err
+	}
+	if isSubquery {
+		return NewPlanOpWith(bindings, ctes, op), nil
+	}
+	query, ok := op.(*PlanOpQuery)
+	if !ok {
+		return nil, sql3.NewErrInternalf("unexpected op type '%T'", op)
+	}
+	return query.WithChildren(NewPlanOpWith(bindings, ctes, query.ChildOp))
+}
+
+// compileCTE returns the binding of a common table expression and the
+// operator that produces its rows
+func (p *ExecutionPlanner) compileCTE(cte *parser.CTE) (*cteBinding, types.PlanOperator, error) {
+	binding := &cteBinding{
+		name: strings.ToLower(parser.IdentName(cte.TableName)),
+	}
+	if p.cteBindings == nil {
+		p.cteBindings = make(map[*parser.CTE]*cteBinding)
+	}
+	p.cteBindings[cte] = binding
+
+	setSchema := func(op types.PlanOperator) {
+		binding.schema = make(types.Schema, 0)
+		for i, col := range op.Schema() {
+			c := *col
+			c.RelationName = binding.name
+			c.AliasName = ""
+			if len(cte.Columns) > 0 {
+				c.ColumnName = parser.IdentName(cte.Columns[i])
+			}
+			binding.schema = append(binding.schema, &c)
+		}
+	}
+
+	if !cte.SelfReference {
+		op, err := p.compileSelectStatement(cte.Select, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		setSchema(op)
+		return binding, op, nil
+	}
+
+	// the anchor is the first select statement, and the statements
+	// compounded with it are the recursive part
+	anchor := *cte.Select
+	anchor.Compound = nil
+	anchorOp, err := p.compileSelectStatement(&anchor, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	setSchema(anchorOp)
+
+	binding.recursing = true
+	recursiveOp, err := p.compileSelectStatement(cte.Select.Compound, true)
+	binding.recursing = false
+	if err != nil {
+		return nil, nil, err
+	}
+	pos := cte.Select.Compound.Select
+	for i, col := range recursiveOp.Schema() {
+		if !typesAreAssignmentCompatible(binding.schema[i].Type, col.Type) {
+			return nil, nil, sql3.NewErrTypeMismatch(pos.Line, pos.Column, binding.schema[i].Type.TypeDescription(), col.Type.TypeDescription())
+		}
+	}
+	namePos := cte.TableName.NamePos
+	return binding, NewPlanOpRecursiveCTE(binding, anchorOp, recursiveOp, cte.Select.UnionAll.IsValid(), namePos.Line, namePos.Column), nil
+}
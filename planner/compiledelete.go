@@ -1,3 +1,58 @@
 // Copyright 2022 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileDeleteStatement compiles a parser.DeleteStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileDeleteStatement(stmt *parser.DeleteStatement) (types.PlanOperator, error) {
+	query := NewPlanOpQuery(p, NewPlanOpNullTable(), p.sql)
+
+	tableName := strings.ToLower(parser.IdentName(stmt.TableName.Name))
+
+	where, err := p.compileExpr(stmt.WhereExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the records to delete are those the source returns
+	source, err := p.compileSource(query, stmt.Source)
+	if err != nil {
+		return nil, err
+	}
+	if where != nil {
+		source = NewPlanOpFilter(p, where, source)
+	}
+
+	query.ChildOp = NewPlanOpDelete(p, tableName, source)
+	return query, nil
+}
+
+// analyzeDeleteStatement analyzes a parser.DeleteStatement
+func (p *ExecutionPlanner) analyzeDeleteStatement(ctx context.Context, stmt *parser.DeleteStatement) error {
+	// analyze source first - needed for name resolution
+	source, err := p.analyzeSource(ctx, stmt.Source, stmt)
+	if err != nil {
+		return err
+	}
+	stmt.Source = source
+
+	expr, err := p.analyzeExpression(ctx, stmt.WhereExpr, stmt)
+	if err != nil {
+		return err
+	}
+	if expr != nil {
+		if !typeIsBool(expr.DataType()) {
+			return sql3.NewErrBooleanExpressionExpected(expr.Pos().Line, expr.Pos().Column)
+		}
+		stmt.WhereExpr = expr
+	}
+	return nil
+}
@@ -1,3 +1,35 @@
 // Copyright 2021 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileDropDatabaseStatement compiles a parser.DropDatabaseStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileDropDatabaseStatement(stmt *parser.DropDatabaseStatement) (types.PlanOperator, error) {
+	databaseName := strings.ToLower(parser.IdentName(stmt.Name))
+	return NewPlanOpQuery(p, NewPlanOpDropDatabase(p, databaseName, stmt.IfExists.IsValid()), p.sql), nil
+}
+
+// analyzeDropDatabaseStatement analyzes a parser.DropDatabaseStatement,
+// checking the database exists unless IF EXISTS was specified
+func (p *ExecutionPlanner) analyzeDropDatabaseStatement(ctx context.Context, stmt *parser.DropDatabaseStatement) error {
+	if stmt.IfExists.IsValid() {
+		return nil
+	}
+	databaseName := strings.ToLower(parser.IdentName(stmt.Name))
+	if _, err := p.schemaAPI.DatabaseByName(ctx, dax.DatabaseName(databaseName)); err != nil {
+		if isDatabaseNotFoundError(err) {
+			return sql3.NewErrDatabaseNotFound(stmt.Name.NamePos.Line, stmt.Name.NamePos.Column, databaseName)
+		}
+		return err
+	}
+	return nil
+}
@@ -1,3 +1,35 @@
 // Copyright 2021 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileDropTableStatement compiles a parser.DropTableStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileDropTableStatement(stmt *parser.DropTableStatement) (types.PlanOperator, error) {
+	tableName := strings.ToLower(parser.IdentName(stmt.Name))
+	return NewPlanOpQuery(p, NewPlanOpDropTable(p, tableName, stmt.IfExists.IsValid()), p.sql), nil
+}
+
+// analyzeDropTableStatement analyzes a parser.DropTableStatement, checking the
+// table exists unless IF EXISTS was specified
+func (p *ExecutionPlanner) analyzeDropTableStatement(ctx context.Context, stmt *parser.DropTableStatement) error {
+	if stmt.IfExists.IsValid() {
+		return nil
+	}
+	tableName := strings.ToLower(parser.IdentName(stmt.Name))
+	if _, err := p.schemaAPI.TableByName(ctx, dax.TableName(tableName)); err != nil {
+		if isTableNotFoundError(err) {
+			return sql3.NewErrTableNotFound(stmt.Name.NamePos.Line, stmt.Name.NamePos.Column, tableName)
+		}
+		return err
+	}
+	return nil
+}
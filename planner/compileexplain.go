@@ -0,0 +1,35 @@
+// Copyright 2021 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileExplainStatement compiles a parser.ExplainStatement AST into a
+// PlanOperator. The statement being explained is compiled as it would be on
+// its own and optimized, and becomes the child of the explain operator, which
+// also records the effect of each optimizer rule. For EXPLAIN ANALYZE the child
+// is executed when the explain operator is.
+func (p *ExecutionPlanner) compileExplainStatement(ctx context.Context, stmt *parser.ExplainStatement) (types.PlanOperator, error) {
+	child, err := p.compileStatement(ctx, stmt.Stmt)
+	if err != nil {
+		return nil, err
+	}
+	child, steps, err := p.optimizePlan(ctx, child, true)
+	if err != nil {
+		return nil, err
+	}
+	op := NewPlanOpExplain(p, child, stmt.Analyze.IsValid())
+	op.steps = steps
+	return NewPlanOpQuery(p, op, p.sql), nil
+}
+
+// analyzeExplainStatement analyzes a parser.ExplainStatement by analyzing the
+// statement being explained
+func (p *ExecutionPlanner) analyzeExplainStatement(ctx context.Context, stmt *parser.ExplainStatement) error {
+	return p.analyzePlan(ctx, stmt.Stmt)
+}
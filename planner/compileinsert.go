@@ -1,3 +1,145 @@
 // Copyright 2022 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileInsertStatement compiles a parser.InsertStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileInsertStatement(ctx context.Context, stmt *parser.InsertStatement) (types.PlanOperator, error) {
+	tableName := strings.ToLower(parser.IdentName(stmt.Table))
+	tbl, err := p.insertTable(ctx, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	targetColumns := insertTargetColumns(tableName, tbl, stmt.Columns)
+
+	insertValues := make([][]types.PlanExpression, len(stmt.TupleList))
+	for i, tuple := range stmt.TupleList {
+		row := make([]types.PlanExpression, len(tuple.Exprs))
+		for j, expr := range tuple.Exprs {
+			planExpr, err := p.compileExpr(expr)
+			if err != nil {
+				return nil, err
+			}
+			row[j] = planExpr
+		}
+		insertValues[i] = row
+	}
+
+	replace := stmt.Replace.IsValid() || stmt.InsertOrReplace.IsValid()
+	return NewPlanOpQuery(p, NewPlanOpInsert(p, tableName, targetColumns, insertValues, replace), p.sql), nil
+}
+
+// analyzeInsertStatement analyzes a parser.InsertStatement, checking the target
+// columns against the table and the values against the target columns
+func (p *ExecutionPlanner) analyzeInsertStatement(ctx context.Context, stmt *parser.InsertStatement) error {
+	tbl, err := p.insertTable(ctx, stmt.Table)
+	if err != nil {
+		return err
+	}
+
+	columns, targetTypes, err := analyzeInsertColumns(tbl, stmt.Table, stmt.Columns)
+	if err != nil {
+		return err
+	}
+	stmt.Columns = columns
+
+	// check the values
+	for _, tuple := range stmt.TupleList {
+		if len(tuple.Exprs) != len(stmt.Columns) {
+			return sql3.NewErrInsertExprTargetCountMismatch(tuple.Lparen.Line, tuple.Lparen.Column)
+		}
+		for i, expr := range tuple.Exprs {
+			e, err := p.analyzeExpression(ctx, expr, stmt)
+			if err != nil {
+				return err
+			}
+			if !typesAreAssignmentCompatible(targetTypes[i], e.DataType()) {
+				return sql3.NewErrTypeAssignmentIncompatible(e.Pos().Line, e.Pos().Column, e.DataType().TypeDescription(), targetTypes[i].TypeDescription())
+			}
+			// _id can never be null
+			if _, ok := e.DataType().(*parser.DataTypeVoid); ok && strings.EqualFold(parser.IdentName(stmt.Columns[i]), string(dax.PrimaryKeyFieldName)) {
+				return sql3.NewErrLiteralNullNotAllowed(e.Pos().Line, e.Pos().Column)
+			}
+			tuple.Exprs[i] = e
+		}
+	}
+	return nil
+}
+
+// insertTable returns the table that is the target of an insert
+func (p *ExecutionPlanner) insertTable(ctx context.Context, table *parser.Ident) (*dax.Table, error) {
+	tableName := strings.ToLower(parser.IdentName(table))
+	tbl, err := p.schemaAPI.TableByName(ctx, dax.TableName(tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return nil, sql3.NewErrTableNotFound(table.NamePos.Line, table.NamePos.Column, tableName)
+		}
+		return nil, err
+	}
+	return tbl, nil
+}
+
+// analyzeInsertColumns checks the target columns of an insert against the table,
+// returning the columns (all the columns of the table if none are specified)
+// and their types
+func analyzeInsertColumns(tbl *dax.Table, table *parser.Ident, columns []*parser.Ident) ([]*parser.Ident, []parser.ExprDataType, error) {
+	// if no columns are specified, values are for all the columns of the table
+	if len(columns) == 0 {
+		for _, fld := range tbl.Fields {
+			columns = append(columns, &parser.Ident{
+				Name:    string(fld.Name),
+				NamePos: table.NamePos,
+			})
+		}
+	}
+
+	// check the columns exist and that we have an _id
+	idFound := false
+	targetTypes := make([]parser.ExprDataType, len(columns))
+	seen := make(map[string]struct{})
+	for i, col := range columns {
+		columnName := strings.ToLower(parser.IdentName(col))
+		if _, ok := seen[columnName]; ok {
+			return nil, nil, sql3.NewErrDuplicateColumn(col.NamePos.Line, col.NamePos.Column, columnName)
+		}
+		seen[columnName] = struct{}{}
+
+		fld, ok := tbl.Field(dax.FieldName(columnName))
+		if !ok {
+			return nil, nil, sql3.NewErrColumnNotFound(col.NamePos.Line, col.NamePos.Column, columnName)
+		}
+		if fld.IsPrimaryKey() {
+			idFound = true
+		}
+		targetTypes[i] = fieldSQLDataType(api.FieldToFieldInfo(fld))
+	}
+	if !idFound {
+		return nil, nil, sql3.NewErrInsertMustHaveIDColumn(table.NamePos.Line, table.NamePos.Column)
+	}
+	if len(columns) < 2 {
+		return nil, nil, sql3.NewErrInsertMustAtLeastOneNonIDColumn(table.NamePos.Line, table.NamePos.Column)
+	}
+	return columns, targetTypes, nil
+}
+
+// insertTargetColumns returns references to the target columns of an insert
+func insertTargetColumns(tableName string, tbl *dax.Table, columns []*parser.Ident) []*qualifiedRefPlanExpression {
+	targetColumns := make([]*qualifiedRefPlanExpression, len(columns))
+	for i, col := range columns {
+		columnName := strings.ToLower(parser.IdentName(col))
+		fld, _ := tbl.Field(dax.FieldName(columnName))
+		targetColumns[i] = newQualifiedRefPlanExpression(tableName, columnName, i, fieldSQLDataType(api.FieldToFieldInfo(fld)))
+	}
+	return targetColumns
+}
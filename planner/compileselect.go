@@ -4,6 +4,7 @@ package planner
 
 import (
 	"context"
+	"strconv"
 	"strings"
 
 	"github.com/gernest/sql3"
@@ -15,6 +16,13 @@ import (
 
 // compileSelectStatment compiles a parser.SelectStatment AST into a PlanOperator
 func (p *ExecutionPlanner) compileSelectStatement(stmt *parser.SelectStatement, isSubquery bool) (types.PlanOperator, error) {
+	if stmt.WithClause != nil {
+		return p.compileWithSelectStatement(stmt, isSubquery)
+	}
+	if stmt.Compound != nil {
+		return p.compileCompoundSelectStatement(stmt, isSubquery)
+	}
+
 	query := NewPlanOpQuery(p, NewPlanOpNullTable(), p.sql)
 
 	aggregates := make([]types.PlanExpression, 0)
@@ -40,7 +48,11 @@ func (p *ExecutionPlanner) compileSelectStatement(stmt *parser.SelectStatement,
 		case *parser.QualifiedRef:
 			groupByExprs = append(groupByExprs, newQualifiedRefPlanExpression(expr.Table.Name, expr.Column.Name, expr.ColumnIndex, expr.DataType()))
 		default:
-			return nil, sql3.NewErrInternalf("unsupported expression type in GROUP BY clause: %T", expr)
+			planExpr, err := p.compileExpr(expr)
+			if err != nil {
+				return nil, err
+			}
+			groupByExprs = append(groupByExprs, planExpr)
 		}
 	}
 
@@ -98,6 +110,9 @@ func (p *ExecutionPlanner) compileSelectStatement(stmt *parser.SelectStatement,
 		// part of an aggregate
 		havingReferences := make([]*qualifiedRefPlanExpression, 0)
 		InspectExpression(having, func(expr types.PlanExpression) bool {
+			if groupByExprIndex(expr, groupByExprs) >= 0 {
+				return false
+			}
 			switch ex := expr.(type) {
 			case types.Aggregable:
 				return false
@@ -175,6 +190,10 @@ func (p *ExecutionPlanner) compileSelectStatement(stmt *parser.SelectStatement,
 		nonAggregateReferences := make([]*qualifiedRefPlanExpression, 0)
 		for _, expr := range projections {
 			InspectExpression(expr, func(expr types.PlanExpression) bool {
+				// anything below a group by expression is grouped
+				if groupByExprIndex(expr, groupByExprs) >= 0 {
+					return false
+				}
 				switch ex := expr.(type) {
 				case types.Aggregable:
 					//return false for these, because thats as far down we want to inspect
@@ -187,23 +206,24 @@ func (p *ExecutionPlanner) compileSelectStatement(stmt *parser.SelectStatement,
 			})
 		}
 
-		for _, nae := range nonAggregateReferences {
-			found := false
-			for _, pe := range groupByExprs {
-				gbe, ok := pe.(*qualifiedRefPlanExpression)
-				if !ok {
-					continue
-				}
-				if strings.EqualFold(nae.columnName, gbe.columnName) &&
-					strings.EqualFold(nae.tableName, gbe.tableName) {
-					found = true
-					break
-				}
+		if len(nonAggregateReferences) > 0 {
+			return nil, sql3.NewErrInvalidUngroupedColumnReference(0, 0, nonAggregateReferences[0].columnName)
+		}
+		// the having and the projections are evaluated against the rows of
+		// the group by, so rewrite them to read its output
+		for i, expr := range projections {
+			projections[i], err = groupByOutputRefs(expr, aggregates, groupByExprs)
+			if err != nil {
+				return nil, err
 			}
-			if !found {
-				return nil, sql3.NewErrInvalidUngroupedColumnReference(0, 0, nae.columnName)
+		}
+		if having != nil {
+			having, err = groupByOutputRefs(having, aggregates, groupByExprs)
+			if err != nil {
+				return nil, err
 			}
 		}
+
 		var groupByOp types.PlanOperator
 		groupByOp = NewPlanOpGroupBy(aggregates, groupByExprs, source)
 		if having != nil {
@@ -337,6 +357,133 @@ func (p *ExecutionPlanner) compileSelectStatement(stmt *parser.SelectStatement,
 	return query.WithChildren(children...)
 }
 
+// compileCompoundSelectStatement compiles a chain of SELECT statements joined by
+// set operations. INTERSECT binds more tightly than UNION and EXCEPT, which are
+// evaluated left to right. The ORDER BY and LIMIT clauses of the first
+// statement apply to the result of the set operations.
+func (p *ExecutionPlanner) compileCompoundSelectStatement(stmt *parser.SelectStatement, isSubquery bool) (types.PlanOperator, error) {
+	query := NewPlanOpQuery(p, NewPlanOpNullTable(), p.sql)
+
+	// compile each statement on its own, keeping its warnings
+	compileOperand := func(s *parser.SelectStatement) (types.PlanOperator, error) {
+		operand := *s
+		operand.Compound = nil
+		operand.OrderingTerms = nil
+		operand.Limit = parser.Pos{}
+		operand.LimitExpr = nil
+		op, err := p.compileSelectStatement(&operand, false)
+		if err != nil {
+			return nil, err
+		}
+		operandQuery, ok := op.(*PlanOpQuery)
+		if !ok {
+			return nil, sql3.NewErrInternalf("unexpected operand type '%T'", op)
+		}
+		for _, w := range operandQuery.warnings {
+			query.AddWarning(w)
+		}
+		return operandQuery.ChildOp, nil
+	}
+
+	first, err := compileOperand(stmt)
+	if err != nil {
+		return nil, err
+	}
+	type operation struct {
+		operation setOperation
+		all       bool
+		pos       parser.Pos
+	}
+	operands := []types.PlanOperator{first}
+	operations := make([]operation, 0)
+	for s := stmt; s.Compound != nil; s = s.Compound {
+		op, err := compileOperand(s.Compound)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case s.Intersect.IsValid():
+			last := len(operands) - 1
+			operands[last], err = NewPlanOpSetOperation(operands[last], op, setOperationIntersect, false, s.Compound.Select)
+			if err != nil {
+				return nil, err
+			}
+		case s.Except.IsValid():
+			operands = append(operands, op)
+			operations = append(operations, operation{setOperationExcept, false, s.Compound.Select})
+		default:
+			operands = append(operands, op)
+			operations = append(operations, operation{setOperationUnion, s.UnionAll.IsValid(), s.Compound.Select})
+		}
+	}
+	var compiledOp types.PlanOperator = operands[0]
+	for i, o := range operations {
+		compiledOp, err = NewPlanOpSetOperation(compiledOp, operands[i+1], o.operation, o.all, o.pos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the ordering terms refer to the columns of the result by name or position
+	if len(stmt.OrderingTerms) > 0 {
+		schema := compiledOp.Schema()
+		orderByExprs := make([]*OrderByExpression, 0)
+		for _, ot := range stmt.OrderingTerms {
+			index := -1
+			switch x := ot.X.(type) {
+			case *parser.Ident:
+				for i, col := range stmt.Columns {
+					if strings.EqualFold(col.Name(), x.Name) {
+						index = i
+						break
+					}
+				}
+				if index < 0 {
+					return nil, sql3.NewErrColumnNotFound(x.NamePos.Line, x.NamePos.Column, x.Name)
+				}
+			case *parser.IntegerLit:
+				val, err := strconv.ParseInt(x.Value, 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				// ordering terms are 1 based
+				index = int(val - 1)
+				if index < 0 || index >= len(schema) {
+					return nil, sql3.NewErrExpectedSortExpressionReference(x.ValuePos.Line, x.ValuePos.Column)
+				}
+			default:
+				return nil, sql3.NewErrInternalf("unexpected ordering term type '%T'", ot.X)
+			}
+			col := schema[index]
+			if !typeCanBeSortedOn(col.Type) {
+				return nil, sql3.NewErrExpectedSortableExpression(0, 0, col.Type.TypeDescription())
+			}
+			f := &OrderByExpression{
+				Expr:  newQualifiedRefPlanExpression("", col.ColumnName, index, col.Type),
+				Order: orderByAsc,
+			}
+			if ot.Desc.IsValid() {
+				f.Order = orderByDesc
+			}
+			orderByExprs = append(orderByExprs, f)
+		}
+		compiledOp = NewPlanOpOrderBy(orderByExprs, compiledOp)
+	}
+
+	if stmt.Limit.IsValid() {
+		limitExpr, err := p.compileExpr(stmt.LimitExpr)
+		if err != nil {
+			return nil, err
+		}
+		compiledOp = NewPlanOpTop(limitExpr, compiledOp)
+	}
+
+	if isSubquery {
+		return compiledOp, nil
+	}
+	return query.WithChildren(compiledOp)
+}
+
 func (p *ExecutionPlanner) gatherExprAggregates(expr types.PlanExpression, aggregates []types.PlanExpression) []types.PlanExpression {
 	result := aggregates
 	InspectExpression(expr, func(expr types.PlanExpression) bool {
@@ -361,6 +508,55 @@ func (p *ExecutionPlanner) gatherExprAggregates(expr types.PlanExpression, aggre
 	return result
 }
 
+// groupByOutputRefs rewrites expr so that the aggregates and the grouped
+// columns it references are read from the columns of a row produced by a
+// PlanOpGroupBy, which are the group by expressions followed by the aggregates
+func groupByOutputRefs(expr types.PlanExpression, aggregates []types.PlanExpression, groupByExprs []types.PlanExpression) (types.PlanExpression, error) {
+	result, _, err := TransformExpr(expr, func(expr types.PlanExpression) (types.PlanExpression, bool, error) {
+		switch ex := expr.(type) {
+		case types.Aggregable:
+			for i, agg := range aggregates {
+				if strings.EqualFold(agg.String(), ex.String()) {
+					return newQualifiedRefPlanExpression("", agg.String(), len(groupByExprs)+i, agg.Type()), false, nil
+				}
+			}
+			return nil, true, sql3.NewErrInternalf("unexpected aggregate '%s'", ex.String())
+
+		case *qualifiedRefPlanExpression:
+			if i := groupByExprIndex(ex, groupByExprs); i >= 0 {
+				return newQualifiedRefPlanExpression(ex.tableName, ex.columnName, i, ex.dataType), false, nil
+			}
+		default:
+			if i := groupByExprIndex(ex, groupByExprs); i >= 0 {
+				return newQualifiedRefPlanExpression("", ex.String(), i, ex.Type()), false, nil
+			}
+		}
+		return expr, true, nil
+	}, func(parentExpr, childExpr types.PlanExpression) bool {
+		// the arguments of an aggregate and the operands of a group by
+		// expression are evaluated by the group by
+		if _, ok := parentExpr.(types.Aggregable); ok {
+			return false
+		}
+		return groupByExprIndex(parentExpr, groupByExprs) < 0
+	})
+	return result, err
+}
+
+// groupByExprIndex returns the position of expr in the group by expressions,
+// or -1 if it is not one of them
+func groupByExprIndex(expr types.PlanExpression, groupByExprs []types.PlanExpression) int {
+	if expr == nil {
+		return -1
+	}
+	for i, gbe := range groupByExprs {
+		if strings.EqualFold(gbe.String(), expr.String()) {
+			return i
+		}
+	}
+	return -1
+}
+
 func (p *ExecutionPlanner) compileSource(scope *PlanOpQuery, source parser.Source) (types.PlanOperator, error) {
 	if source == nil {
 		return NewPlanOpNullTable(), nil
@@ -375,15 +571,33 @@ func (p *ExecutionPlanner) compileSource(scope *PlanOpQuery, source parser.Sourc
 		if sourceExpr.Operator.Left.IsValid() {
 			jType = joinTypeLeft
 		} else if sourceExpr.Operator.Right.IsValid() {
-			return nil, sql3.NewErrUnsupported(sourceExpr.Operator.Right.Line, sourceExpr.Operator.Right.Column, false, "RIGHT join types")
+			jType = joinTypeRight
 		} else if sourceExpr.Operator.Full.IsValid() {
-			return nil, sql3.NewErrUnsupported(sourceExpr.Operator.Full.Line, sourceExpr.Operator.Full.Column, false, "FULL join types")
+			jType = joinTypeFull
 		}
 
 		// handle the join condition
 		var joinCondition types.PlanExpression
-		if sourceExpr.Constraint == nil {
-			scope.AddWarning("⚠️  cartesian products are never a good idea - are you missing a join constraint?")
+		if len(sourceExpr.UsingColumns) > 0 {
+			// the merged columns are equal
+			for _, uc := range sourceExpr.UsingColumns {
+				eq := newBinOpPlanExpression(
+					newQualifiedRefPlanExpression(uc.X.TableName, uc.X.ColumnName, uc.X.ColumnIndex, uc.X.Datatype),
+					parser.EQ,
+					newQualifiedRefPlanExpression(uc.Y.TableName, uc.Y.ColumnName, uc.Y.ColumnIndex, uc.Y.Datatype),
+					parser.NewDataTypeBool(),
+				)
+				if joinCondition == nil {
+					joinCondition = eq
+				} else {
+					joinCondition = newBinOpPlanExpression(joinCondition, parser.AND, eq, parser.NewDataTypeBool())
+				}
+			}
+		} else if sourceExpr.Constraint == nil {
+			// cross joins and natural joins are cartesian products on purpose
+			if !sourceExpr.Operator.Cross.IsValid() && !sourceExpr.Operator.Natural.IsValid() {
+				scope.AddWarning("⚠️  cartesian products are never a good idea - are you missing a join constraint?")
+			}
 			joinCondition = nil
 		} else {
 			switch join := sourceExpr.Constraint.(type) {
@@ -407,11 +621,61 @@ func (p *ExecutionPlanner) compileSource(scope *PlanOpQuery, source parser.Sourc
 		if err != nil {
 			return nil, err
 		}
-		return NewPlanOpNestedLoops(topOp, bottomOp, jType, joinCondition), nil
+		joinOp := NewPlanOpNestedLoops(topOp, bottomOp, jType, joinCondition)
+		if len(sourceExpr.UsingColumns) == 0 {
+			return joinOp, nil
+		}
+
+		// add the merged columns after the columns of the join; the merged
+		// column takes its value from the side of the join that is kept, or
+		// from whichever side is not null for a full join
+		projections := make([]types.PlanExpression, 0)
+		for i, col := range joinOp.Schema() {
+			projections = append(projections, newQualifiedRefPlanExpression(col.RelationName, col.ColumnName, i, col.Type))
+		}
+		for _, uc := range sourceExpr.UsingColumns {
+			xRef := newQualifiedRefPlanExpression(uc.X.TableName, uc.X.ColumnName, uc.X.ColumnIndex, uc.X.Datatype)
+			yRef := newQualifiedRefPlanExpression(uc.Y.TableName, uc.Y.ColumnName, uc.Y.ColumnIndex, uc.Y.Datatype)
+			var merged types.PlanExpression
+			switch jType {
+			case joinTypeRight:
+				merged = yRef
+			case joinTypeFull:
+				merged = newCasePlanExpression(
+					nil,
+					[]types.PlanExpression{
+						newCaseBlockPlanExpression(
+							newBinOpPlanExpression(xRef, parser.IS, newNullLiteralPlanExpression(), parser.NewDataTypeBool()),
+							yRef,
+						),
+					},
+					xRef,
+					uc.Output.Datatype,
+				)
+			default:
+				merged = xRef
+			}
+			projections = append(projections, newAliasPlanExpression(uc.Output.ColumnName, merged))
+		}
+		return NewPlanOpProjection(projections, joinOp), nil
 
 	case *parser.QualifiedTableName:
+		if sourceExpr.CTE != nil {
+			binding, ok := p.cteBindings[sourceExpr.CTE]
+			if !ok {
+				return nil, sql3.NewErrInternalf("common table expression '%s' not compiled", parser.IdentName(sourceExpr.Name))
+			}
+			var op types.PlanOperator = NewPlanOpCTEScan(binding, binding.recursing)
+			if sourceExpr.Alias != nil {
+				op = NewPlanOpRelAlias(parser.IdentName(sourceExpr.Alias), op)
+			}
+			return op, nil
+		}
 
-		tableName := strings.ToLower(parser.IdentName(sourceExpr.Name))
+		tbl, ok := p.tables[sourceExpr]
+		if !ok {
+			return nil, sql3.NewErrInternalf("table '%s' not analyzed", parser.IdentName(sourceExpr.Name))
+		}
 
 		// get all the columns for this table - we will eliminate unused ones
 		// later on in the optimizer
@@ -421,9 +685,12 @@ func (p *ExecutionPlanner) compileSource(scope *PlanOpQuery, source parser.Sourc
 			extractColumns = append(extractColumns, oc.ColumnName)
 		}
 
-		_ = tableName
+		if sourceExpr.Alias != nil {
+			aliasName := parser.IdentName(sourceExpr.Alias)
+			return NewPlanOpRelAlias(aliasName, NewPlanOpTableScan(p, tbl, extractColumns)), nil
+		}
 
-		panic("TODO: bring back table scan")
+		return NewPlanOpTableScan(p, tbl, extractColumns), nil
 	case *parser.TableValuedFunction:
 		callExpr, err := p.compileCallExpr(sourceExpr.Call)
 		if err != nil {
@@ -483,9 +750,41 @@ func (p *ExecutionPlanner) analyzeSource(ctx context.Context, source parser.Sour
 					return nil, err
 				}
 				join.X = ex
+			case *parser.UsingConstraint:
+				source.UsingColumns, err = p.analyzeJoinUsingColumns(x, y, join.Columns)
+				if err != nil {
+					return nil, err
+				}
 			default:
 				return nil, sql3.NewErrInternalf("unexpected constraint type '%T'", join)
 			}
+		} else if source.Operator.Natural.IsValid() {
+			// a natural join merges the columns with the same name in both
+			// sources, and is a cross join if there are none
+			columns := make([]*parser.Ident, 0)
+			yCols := unqualifiedOutputColumns(y)
+			for _, xc := range unqualifiedOutputColumns(x) {
+				common := false
+				for _, yc := range yCols {
+					if strings.EqualFold(xc.ColumnName, yc.ColumnName) {
+						common = true
+						break
+					}
+				}
+				for _, col := range columns {
+					if strings.EqualFold(xc.ColumnName, col.Name) {
+						common = false
+						break
+					}
+				}
+				if common {
+					columns = append(columns, &parser.Ident{NamePos: source.Operator.Natural, Name: xc.ColumnName})
+				}
+			}
+			source.UsingColumns, err = p.analyzeJoinUsingColumns(x, y, columns)
+			if err != nil {
+				return nil, err
+			}
 		}
 		source.X = x
 		source.Y = y
@@ -503,6 +802,24 @@ func (p *ExecutionPlanner) analyzeSource(ctx context.Context, source parser.Sour
 
 		objectName := strings.ToLower(parser.IdentName(source.Name))
 
+		// common table expressions hide tables with the same name
+		if scope := p.lookupCTE(objectName); scope != nil {
+			if scope.columns == nil {
+				// the anchor of a recursive CTE can't refer to it
+				return nil, sql3.NewErrInvalidRecursiveCTE(source.Name.NamePos.Line, source.Name.NamePos.Column, objectName)
+			}
+			if scope.analyzing {
+				scope.cte.SelfReference = true
+			}
+			source.CTE = scope.cte
+			source.OutputColumns = make([]*parser.SourceOutputColumn, len(scope.columns))
+			for i, oc := range scope.columns {
+				col := *oc
+				source.OutputColumns[i] = &col
+			}
+			return source, nil
+		}
+
 		// if we got to here, not a view, so do table stuff
 
 		// check table exists
@@ -515,6 +832,11 @@ func (p *ExecutionPlanner) analyzeSource(ctx context.Context, source parser.Sour
 			return nil, err
 		}
 
+		if p.tables == nil {
+			p.tables = make(map[*parser.QualifiedTableName]*dax.Table)
+		}
+		p.tables[source] = tbl
+
 		// populate the output columns from the source
 		for i, fld := range tbl.Fields {
 			soc := &parser.SourceOutputColumn{
@@ -577,6 +899,16 @@ func (p *ExecutionPlanner) analyzeSource(ctx context.Context, source parser.Sour
 }
 
 func (p *ExecutionPlanner) analyzeSelectStatement(ctx context.Context, stmt *parser.SelectStatement) (parser.Expr, error) {
+	// the common table expressions are in scope until the end of the statement
+	defer func(n int) {
+		p.ctes = p.ctes[:n]
+	}(len(p.ctes))
+	if stmt.WithClause != nil {
+		if err := p.analyzeWithClause(ctx, stmt.WithClause); err != nil {
+			return nil, err
+		}
+	}
+
 	// analyze source first - needed for name resolution
 	source, err := p.analyzeSource(ctx, stmt.Source, stmt)
 	if err != nil {
@@ -654,14 +986,141 @@ func (p *ExecutionPlanner) analyzeSelectStatement(ctx context.Context, stmt *par
 		stmt.HavingExpr = expr
 	}
 
-	for _, term := range stmt.OrderingTerms {
-		err := p.analyzeOrderingTermExpression(term.X, stmt)
+	// the ordering terms of a compound statement are checked when it is
+	// compiled, since they refer to the result of the set operations
+	if stmt.Compound == nil {
+		for _, term := range stmt.OrderingTerms {
+			err := p.analyzeOrderingTermExpression(term.X, stmt)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if err := p.analyzeCompoundSelectStatement(ctx, stmt); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// analyzeCompoundSelectStatement analyzes the statement compounded with stmt,
+// which must have the same number of columns
+func (p *ExecutionPlanner) analyzeCompoundSelectStatement(ctx context.Context, stmt *parser.SelectStatement) error {
+	if _, err := p.analyzeSelectStatement(ctx, stmt.Compound); err != nil {
+		return err
+	}
+	if len(stmt.Compound.Columns) != len(stmt.Columns) {
+		operation := setOperationUnion
+		if stmt.Intersect.IsValid() {
+			operation = setOperationIntersect
+		} else if stmt.Except.IsValid() {
+			operation = setOperationExcept
+		}
+		return sql3.NewErrSetOperationColumnCountMismatch(stmt.Compound.Select.Line, stmt.Compound.Select.Column, operation.String())
+	}
+	return nil
+}
+
+// analyzeJoinUsingColumns returns the merged columns of a join of x and y on
+// the named columns, each of which must be an unambiguous column of both
+// sources.
+func (p *ExecutionPlanner) analyzeJoinUsingColumns(x, y parser.Source, columns []*parser.Ident) ([]*parser.JoinUsingColumn, error) {
+	xCols := unqualifiedOutputColumns(x)
+	yCols := unqualifiedOutputColumns(y)
+	width := len(x.PossibleOutputColumns()) + len(y.PossibleOutputColumns())
+
+	findColumn := func(cols []*parser.SourceOutputColumn, ident *parser.Ident) (*parser.SourceOutputColumn, error) {
+		var result *parser.SourceOutputColumn
+		for _, oc := range cols {
+			if !strings.EqualFold(oc.ColumnName, ident.Name) {
+				continue
+			}
+			if result != nil {
+				return nil, sql3.NewErrAmbiguousColumn(ident.NamePos.Line, ident.NamePos.Column, ident.Name)
+			}
+			result = oc
+		}
+		if result == nil {
+			return nil, sql3.NewErrColumnNotFound(ident.NamePos.Line, ident.NamePos.Column, ident.Name)
+		}
+		return result, nil
+	}
+
+	result := make([]*parser.JoinUsingColumn, 0, len(columns))
+	for i, ident := range columns {
+		for _, uc := range result {
+			if strings.EqualFold(uc.Output.ColumnName, ident.Name) {
+				return nil, sql3.NewErrAmbiguousColumn(ident.NamePos.Line, ident.NamePos.Column, ident.Name)
+			}
+		}
+		xc, err := findColumn(xCols, ident)
+		if err != nil {
+			return nil, err
+		}
+		yc, err := findColumn(yCols, ident)
 		if err != nil {
 			return nil, err
 		}
+		if !typeIsCompatibleWithEqualityOperator(xc.Datatype) || !typesAreComparable(xc.Datatype, yc.Datatype) {
+			return nil, sql3.NewErrTypesAreNotEquatable(ident.NamePos.Line, ident.NamePos.Column, xc.Datatype.TypeDescription(), yc.Datatype.TypeDescription())
+		}
+
+		// the columns of y follow the columns of x in the rows of the join
+		offsetY := *yc
+		offsetY.ColumnIndex += len(x.PossibleOutputColumns())
+
+		result = append(result, &parser.JoinUsingColumn{
+			X: xc,
+			Y: &offsetY,
+			Output: &parser.SourceOutputColumn{
+				ColumnName:  xc.ColumnName,
+				ColumnIndex: width + i,
+				Datatype:    xc.Datatype,
+			},
+		})
 	}
+	return result, nil
+}
 
-	return stmt, nil
+// unqualifiedOutputColumns returns the output columns of a source that can be
+// referenced without a qualifier, which for a join are its merged columns
+// followed by the columns of its sources that were not merged.
+func unqualifiedOutputColumns(source parser.Source) []*parser.SourceOutputColumn {
+	switch src := source.(type) {
+	case *parser.JoinClause:
+		result := make([]*parser.SourceOutputColumn, 0)
+		merged := make(map[int]struct{})
+		for _, uc := range src.UsingColumns {
+			result = append(result, uc.Output)
+			merged[uc.X.ColumnIndex] = struct{}{}
+			merged[uc.Y.ColumnIndex] = struct{}{}
+		}
+		offset := len(src.X.PossibleOutputColumns())
+		for _, oc := range unqualifiedOutputColumns(src.X) {
+			if _, ok := merged[oc.ColumnIndex]; !ok {
+				result = append(result, oc)
+			}
+		}
+		for _, oc := range unqualifiedOutputColumns(src.Y) {
+			offsetOC := *oc
+			offsetOC.ColumnIndex += offset
+			if _, ok := merged[offsetOC.ColumnIndex]; !ok {
+				result = append(result, &offsetOC)
+			}
+		}
+		return result
+
+	case *parser.ParenSource:
+		result := make([]*parser.SourceOutputColumn, 0)
+		for _, oc := range unqualifiedOutputColumns(src.X) {
+			aliasOC := *oc
+			aliasOC.TableName = parser.IdentName(src.Alias)
+			result = append(result, &aliasOC)
+		}
+		return result
+
+	default:
+		return source.PossibleOutputColumns()
+	}
 }
 
 func (p *ExecutionPlanner) analyzeSelectStatementWildcards(stmt *parser.SelectStatement) error {
@@ -715,7 +1174,9 @@ func (p *ExecutionPlanner) columnsFromSource(source parser.Source) ([]*parser.Re
 
 	switch src := source.(type) {
 	case *parser.JoinClause:
-		for _, oc := range src.PossibleOutputColumns() {
+		// the columns merged by the join replace the columns they were merged
+		// from
+		for _, oc := range unqualifiedOutputColumns(src) {
 			result = append(result, &parser.ResultColumn{
 				Expr: &parser.QualifiedRef{
 					Table:       &parser.Ident{Name: oc.TableName},
@@ -727,7 +1188,7 @@ func (p *ExecutionPlanner) columnsFromSource(source parser.Source) ([]*parser.Re
 		return result, nil
 
 	case *parser.ParenSource:
-		for _, oc := range src.PossibleOutputColumns() {
+		for _, oc := range unqualifiedOutputColumns(src) {
 			result = append(result, &parser.ResultColumn{
 				Expr: &parser.QualifiedRef{
 					Table:       &parser.Ident{Name: oc.TableName},
@@ -1,3 +1,61 @@
 // Copyright 2021 Molecula Corp. All rights reserved.
 
 package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileShowDatabasesStatement compiles a parser.ShowDatabasesStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileShowDatabasesStatement(stmt *parser.ShowDatabasesStatement) (types.PlanOperator, error) {
+	return NewPlanOpQuery(p, NewPlanOpShowDatabases(p), p.sql), nil
+}
+
+// compileShowTablesStatement compiles a parser.ShowTablesStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileShowTablesStatement(stmt *parser.ShowTablesStatement) (types.PlanOperator, error) {
+	return NewPlanOpQuery(p, NewPlanOpShowTables(p), p.sql), nil
+}
+
+// compileShowColumnsStatement compiles a parser.ShowColumnsStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileShowColumnsStatement(stmt *parser.ShowColumnsStatement) (types.PlanOperator, error) {
+	tableName := strings.ToLower(parser.IdentName(stmt.TableName))
+	return NewPlanOpQuery(p, NewPlanOpShowColumns(p, tableName), p.sql), nil
+}
+
+// compileShowCreateTableStatement compiles a parser.ShowCreateTableStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileShowCreateTableStatement(stmt *parser.ShowCreateTableStatement) (types.PlanOperator, error) {
+	tableName := strings.ToLower(parser.IdentName(stmt.TableName))
+	return NewPlanOpQuery(p, NewPlanOpShowCreateTable(p, tableName), p.sql), nil
+}
+
+// analyzeShowTablesStatement analyzes a parser.ShowTablesStatement, checking
+// the show option
+func (p *ExecutionPlanner) analyzeShowTablesStatement(stmt *parser.ShowTablesStatement) error {
+	if !stmt.With.IsValid() {
+		return nil
+	}
+	// SYSTEM is accepted, but there are no system tables to add to the list
+	option := parser.IdentName(stmt.System)
+	if !strings.EqualFold(option, "system") {
+		return sql3.NewErrUnknownShowOption(stmt.System.NamePos.Line, stmt.System.NamePos.Column, option)
+	}
+	return nil
+}
+
+// analyzeShowTableName checks that the table named in a show statement exists
+func (p *ExecutionPlanner) analyzeShowTableName(ctx context.Context, table *parser.Ident) error {
+	tableName := strings.ToLower(parser.IdentName(table))
+	if _, err := p.schemaAPI.TableByName(ctx, dax.TableName(tableName)); err != nil {
+		if isTableNotFoundError(err) {
+			return sql3.NewErrTableNotFound(table.NamePos.Line, table.NamePos.Column, tableName)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// compileUpdateStatement compiles a parser.UpdateStatement AST into a PlanOperator
+func (p *ExecutionPlanner) compileUpdateStatement(stmt *parser.UpdateStatement) (types.PlanOperator, error) {
+	query := NewPlanOpQuery(p, NewPlanOpNullTable(), p.sql)
+
+	tableName := strings.ToLower(parser.IdentName(stmt.Table.Name))
+
+	where, err := p.compileExpr(stmt.WhereExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	// the records to update are those the source returns
+	source, err := p.compileSource(query, stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	if where != nil {
+		source = NewPlanOpFilter(p, where, source)
+	}
+
+	targetColumns := make([]*qualifiedRefPlanExpression, len(stmt.Assignments))
+	updateValues := make([]types.PlanExpression, len(stmt.Assignments))
+	for i, a := range stmt.Assignments {
+		oc, err := stmt.Table.OutputColumnNamed(parser.IdentName(a.Columns[0]))
+		if err != nil {
+			return nil, err
+		}
+		targetColumns[i] = newQualifiedRefPlanExpression(tableName, strings.ToLower(oc.ColumnName), i, oc.Datatype)
+
+		updateValues[i], err = p.compileExpr(a.Expr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query.ChildOp = NewPlanOpUpdate(p, tableName, targetColumns, updateValues, source)
+	return query, nil
+}
+
+// analyzeUpdateStatement analyzes a parser.UpdateStatement, checking the
+// assignments against the columns of the table
+func (p *ExecutionPlanner) analyzeUpdateStatement(ctx context.Context, stmt *parser.UpdateStatement) error {
+	if stmt.WithClause != nil {
+		return sql3.NewErrUnsupported(stmt.WithClause.With.Line, stmt.WithClause.With.Column, false, "WITH clauses on UPDATE")
+	}
+	if stmt.UpdateOr.IsValid() {
+		return sql3.NewErrUnsupported(stmt.UpdateOr.Line, stmt.UpdateOr.Column, true, "UPDATE OR")
+	}
+
+	// analyze the table first - needed for name resolution
+	source, err := p.analyzeSource(ctx, stmt.Table, stmt)
+	if err != nil {
+		return err
+	}
+	table, ok := source.(*parser.QualifiedTableName)
+	if !ok {
+		return sql3.NewErrInternalf("unexpected update source type '%T'", source)
+	}
+	stmt.Table = table
+
+	seen := make(map[string]struct{})
+	for _, a := range stmt.Assignments {
+		if len(a.Columns) != 1 {
+			return sql3.NewErrUnsupported(a.Lparen.Line, a.Lparen.Column, false, "column list assignments")
+		}
+		col := a.Columns[0]
+		columnName := strings.ToLower(parser.IdentName(col))
+
+		oc, err := stmt.Table.OutputColumnNamed(columnName)
+		if err != nil {
+			return err
+		} else if oc == nil {
+			return sql3.NewErrColumnNotFound(col.NamePos.Line, col.NamePos.Column, columnName)
+		}
+		if strings.EqualFold(oc.ColumnName, string(dax.PrimaryKeyFieldName)) {
+			return sql3.NewErrUnsupported(col.NamePos.Line, col.NamePos.Column, true, "updating the '_id' column")
+		}
+		if _, ok := seen[columnName]; ok {
+			return sql3.NewErrDuplicateColumn(col.NamePos.Line, col.NamePos.Column, columnName)
+		}
+		seen[columnName] = struct{}{}
+
+		expr, err := p.analyzeExpression(ctx, a.Expr, stmt)
+		if err != nil {
+			return err
+		}
+		if !typesAreAssignmentCompatible(oc.Datatype, expr.DataType()) {
+			return sql3.NewErrTypeAssignmentIncompatible(expr.Pos().Line, expr.Pos().Column, expr.DataType().TypeDescription(), oc.Datatype.TypeDescription())
+		}
+		a.Expr = expr
+	}
+
+	expr, err := p.analyzeExpression(ctx, stmt.WhereExpr, stmt)
+	if err != nil {
+		return err
+	}
+	if expr != nil {
+		if !typeIsBool(expr.DataType()) {
+			return sql3.NewErrBooleanExpressionExpected(expr.Pos().Line, expr.Pos().Column)
+		}
+		stmt.WhereExpr = expr
+	}
+	return nil
+}
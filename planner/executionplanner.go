@@ -19,6 +19,20 @@ func isTableNotFoundError(err error) bool {
 		errors.Is(errors.Unwrap(err), dax.ErrTableNameDoesNotExist)
 }
 
+func isTableExistsError(err error) bool {
+	return errors.Is(err, dax.ErrTableNameExists) ||
+		errors.Is(errors.Unwrap(err), dax.ErrTableNameExists)
+}
+
+func isDatabaseNotFoundError(err error) bool {
+	return errors.Is(err, dax.ErrDatabaseNameDoesNotExist) ||
+		errors.Is(err, dax.ErrDatabaseIDDoesNotExist)
+}
+
+func isDatabaseExistsError(err error) bool {
+	return errors.Is(err, dax.ErrDatabaseNameExists)
+}
+
 // ExecutionPlanner compiles SQL text into a query plan
 type ExecutionPlanner struct {
 	executor       api.Executor
@@ -28,6 +42,17 @@ type ExecutionPlanner struct {
 	importer       api.Importer
 	logger         slog.Logger
 	sql            string
+
+	// names of the optimizer rules that are not applied
+	disabledRules map[string]struct{}
+
+	// the common table expressions in scope during analysis, innermost last,
+	// and the bindings of the compiled ones
+	ctes        []*cteScope
+	cteBindings map[*parser.CTE]*cteBinding
+
+	// the tables of the table names in the query, resolved during analysis
+	tables map[*parser.QualifiedTableName]*dax.Table
 }
 
 func NewExecutionPlanner(executor api.Executor, schemaAPI api.SchemaAPI, systemAPI api.SystemAPI, systemLayerAPI api.SystemLayerAPI, importer api.Importer, logger slog.Logger, sql string) *ExecutionPlanner {
@@ -54,17 +79,60 @@ func (p *ExecutionPlanner) CompilePlan(ctx context.Context, stmt parser.Statemen
 		return nil, err
 	}
 
-	var rootOperator types.PlanOperator
+	rootOperator, err := p.compileStatement(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	// an explained statement is optimized when it is compiled, so the plan can
+	// show what the optimizer did
+	if _, ok := stmt.(*parser.ExplainStatement); ok {
+		return rootOperator, nil
+	}
+
+	// optimize the plan
+	rootOperator, _, err = p.optimizePlan(ctx, rootOperator, false)
+	return rootOperator, err
+}
+
+// compileStatement compiles an analyzed statement into a query plan
+func (p *ExecutionPlanner) compileStatement(ctx context.Context, stmt parser.Statement) (rootOperator types.PlanOperator, err error) {
 	switch stmt := stmt.(type) {
 	case *parser.SelectStatement:
 		rootOperator, err = p.compileSelectStatement(stmt, false)
+	case *parser.InsertStatement:
+		rootOperator, err = p.compileInsertStatement(ctx, stmt)
+	case *parser.BulkInsertStatement:
+		rootOperator, err = p.compileBulkInsertStatement(ctx, stmt)
+	case *parser.DeleteStatement:
+		rootOperator, err = p.compileDeleteStatement(stmt)
+	case *parser.UpdateStatement:
+		rootOperator, err = p.compileUpdateStatement(stmt)
+	case *parser.CreateTableStatement:
+		rootOperator, err = p.compileCreateTableStatement(stmt)
+	case *parser.AlterTableStatement:
+		rootOperator, err = p.compileAlterTableStatement(stmt)
+	case *parser.DropTableStatement:
+		rootOperator, err = p.compileDropTableStatement(stmt)
+	case *parser.CreateDatabaseStatement:
+		rootOperator, err = p.compileCreateDatabaseStatement(stmt)
+	case *parser.DropDatabaseStatement:
+		rootOperator, err = p.compileDropDatabaseStatement(stmt)
+	case *parser.AlterDatabaseStatement:
+		rootOperator, err = p.compileAlterDatabaseStatement(stmt)
+	case *parser.ShowDatabasesStatement:
+		rootOperator, err = p.compileShowDatabasesStatement(stmt)
+	case *parser.ShowTablesStatement:
+		rootOperator, err = p.compileShowTablesStatement(stmt)
+	case *parser.ShowColumnsStatement:
+		rootOperator, err = p.compileShowColumnsStatement(stmt)
+	case *parser.ShowCreateTableStatement:
+		rootOperator, err = p.compileShowCreateTableStatement(stmt)
+	case *parser.ExplainStatement:
+		rootOperator, err = p.compileExplainStatement(ctx, stmt)
 	default:
 		return nil, sql3.NewErrInternalf("cannot plan statement: %T", stmt)
 	}
-	// optimize the plan
-	if err == nil {
-		// rootOperator, err = p.optimizePlan(ctx, rootOperator)
-	}
 	return rootOperator, err
 }
 
@@ -73,6 +141,36 @@ func (p *ExecutionPlanner) analyzePlan(ctx context.Context, stmt parser.Statemen
 	case *parser.SelectStatement:
 		_, err := p.analyzeSelectStatement(ctx, stmt)
 		return err
+	case *parser.InsertStatement:
+		return p.analyzeInsertStatement(ctx, stmt)
+	case *parser.BulkInsertStatement:
+		return p.analyzeBulkInsertStatement(ctx, stmt)
+	case *parser.DeleteStatement:
+		return p.analyzeDeleteStatement(ctx, stmt)
+	case *parser.UpdateStatement:
+		return p.analyzeUpdateStatement(ctx, stmt)
+	case *parser.CreateTableStatement:
+		return p.analyzeCreateTableStatement(stmt)
+	case *parser.AlterTableStatement:
+		return p.analyzeAlterTableStatement(ctx, stmt)
+	case *parser.DropTableStatement:
+		return p.analyzeDropTableStatement(ctx, stmt)
+	case *parser.CreateDatabaseStatement:
+		return p.analyzeCreateDatabaseStatement(stmt)
+	case *parser.DropDatabaseStatement:
+		return p.analyzeDropDatabaseStatement(ctx, stmt)
+	case *parser.AlterDatabaseStatement:
+		return p.analyzeAlterDatabaseStatement(ctx, stmt)
+	case *parser.ShowDatabasesStatement:
+		return nil
+	case *parser.ShowTablesStatement:
+		return p.analyzeShowTablesStatement(stmt)
+	case *parser.ShowColumnsStatement:
+		return p.analyzeShowTableName(ctx, stmt.TableName)
+	case *parser.ShowCreateTableStatement:
+		return p.analyzeShowTableName(ctx, stmt.TableName)
+	case *parser.ExplainStatement:
+		return p.analyzeExplainStatement(ctx, stmt)
 	default:
 		return sql3.NewErrInternalf("cannot analyze statement: %T", stmt)
 	}
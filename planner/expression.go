@@ -104,6 +104,12 @@ func coerceValue(sourceType parser.ExprDataType, targetType parser.ExprDataType,
 			return value, nil
 		}
 
+	case *parser.DataTypeBool:
+		switch targetType.(type) {
+		case *parser.DataTypeBool:
+			return value, nil
+		}
+
 	case *parser.DataTypeIDSet:
 		switch targetType.(type) {
 		case *parser.DataTypeIDSet:
@@ -169,6 +175,15 @@ func (n *unaryOpPlanExpression) Evaluate(currentRow []interface{}) (interface{},
 		return n.plusWithTypeCheck(evalRhs)
 	case parser.MINUS:
 		return n.minusWithTypeCheck(evalRhs)
+	case parser.NOT:
+		if evalRhs == nil {
+			return nil, nil
+		}
+		b, ok := evalRhs.(bool)
+		if !ok {
+			return nil, sql3.NewErrInternalf("unexpected incompatible types '%T", evalRhs)
+		}
+		return !b, nil
 	default:
 		return nil, sql3.NewErrInternalf("unhandled operator %d", n.op)
 	}
@@ -179,6 +194,9 @@ func (n *unaryOpPlanExpression) Type() parser.ExprDataType {
 }
 
 func (n *unaryOpPlanExpression) String() string {
+	if n.op == parser.NOT {
+		return fmt.Sprintf("NOT %s", n.rhs.String())
+	}
 	return fmt.Sprintf("%s%s", n.op.String(), n.rhs.String())
 }
 
@@ -344,6 +362,16 @@ func (n *binOpPlanExpression) Evaluate(currentRow []interface{}) (interface{}, e
 
 	switch coercedDataType.(type) {
 	case *parser.DataTypeBool:
+		// TRUE decides OR, and FALSE decides AND, even if the other side is nil
+		if n.op == parser.AND || n.op == parser.OR {
+			decided := n.op == parser.OR
+			if b, ok := evalLhs.(bool); ok && b == decided {
+				return decided, nil
+			}
+			if b, ok := evalRhs.(bool); ok && b == decided {
+				return decided, nil
+			}
+		}
 		// if either side is nil, return nil
 		if evalLhs == nil || evalRhs == nil {
 			return nil, nil
@@ -679,6 +707,14 @@ func (n *binOpPlanExpression) Type() parser.ExprDataType {
 }
 
 func (n *binOpPlanExpression) String() string {
+	switch n.op {
+	case parser.AND, parser.OR, parser.IS, parser.LIKE:
+		return fmt.Sprintf("%s %s %s", n.lhs.String(), n.op.String(), n.rhs.String())
+	case parser.ISNOT:
+		return fmt.Sprintf("%s IS NOT %s", n.lhs.String(), n.rhs.String())
+	case parser.NOTLIKE:
+		return fmt.Sprintf("%s NOT LIKE %s", n.lhs.String(), n.rhs.String())
+	}
 	return fmt.Sprintf("%s%s%s", n.lhs.String(), n.op.String(), n.rhs.String())
 }
 
@@ -1263,10 +1299,15 @@ func (n *betweenOpPlanExpression) Type() parser.ExprDataType {
 }
 
 func (n *betweenOpPlanExpression) String() string {
-	if n.op == parser.BETWEEN {
-		return fmt.Sprintf("between %s and %s", n.lhs.String(), n.rhs.String())
+	not := ""
+	if n.op == parser.NOTBETWEEN {
+		not = "not "
+	}
+	// the range prints its own between
+	if _, ok := n.rhs.(*rangePlanExpression); ok {
+		return fmt.Sprintf("%s %s%s", n.lhs.String(), not, n.rhs.String())
 	}
-	return fmt.Sprintf("not between %s and %s", n.lhs.String(), n.rhs.String())
+	return fmt.Sprintf("%s %sbetween %s", n.lhs.String(), not, n.rhs.String())
 }
 
 func (n *betweenOpPlanExpression) Plan() map[string]interface{} {
@@ -1480,7 +1521,11 @@ func (n *inOpPlanExpression) Type() parser.ExprDataType {
 func (n *inOpPlanExpression) String() string {
 	s := n.lhs.String()
 	if n.op == parser.NOTIN {
-		s += " not "
+		s += " not"
+	}
+	// a list prints its own parentheses
+	if _, ok := n.rhs.(*exprListPlanExpression); ok {
+		return s + " in " + n.rhs.String()
 	}
 	s += " in ("
 	s += n.rhs.String()
@@ -2765,6 +2810,14 @@ func (p *ExecutionPlanner) compileUnaryExpr(expr *parser.UnaryExpr) (_ types.Pla
 			return nil, err
 		}
 		return newUnaryOpPlanExpression(expr.Op, x, expr.ResultDataType), nil
+
+	// logical operators
+	case parser.NOT:
+		x, err := p.compileExpr(expr.X)
+		if err != nil {
+			return nil, err
+		}
+		return newUnaryOpPlanExpression(expr.Op, x, expr.ResultDataType), nil
 	default:
 		return nil, sql3.NewErrInternalf("unexpected unary expression operator: %s", expr.Op)
 	}
@@ -2797,48 +2850,14 @@ func (p *ExecutionPlanner) compileBinaryExpr(expr *parser.BinaryExpr) (_ types.P
 	// arithmetic operators
 	case parser.PLUS, parser.MINUS, parser.STAR, parser.SLASH, parser.REM:
 
-		// TODO(pok) move constant folding to optimizer
-		opx, okx := x.(*intLiteralPlanExpression)
+		// constant expressions are folded by the optimizer, but dividing a
+		// literal by zero is reported here, where the position is known
+		_, okx := x.(*intLiteralPlanExpression)
 		opy, oky := y.(*intLiteralPlanExpression)
-		if okx && oky {
-			// both literals so we can fold
-			numx := opx.value
-			numy := opy.value
-
-			switch op {
-			case parser.PLUS:
-				value := numx + numy
-				return newIntLiteralPlanExpression(value), nil
-
-			case parser.MINUS:
-				value := numx - numy
-				return newIntLiteralPlanExpression(value), nil
-
-			case parser.STAR:
-				value := numx * numy
-				return newIntLiteralPlanExpression(value), nil
-
-			case parser.SLASH:
-				if numy == 0 {
-					return nil, sql3.NewErrDivideByZero(expr.OpPos.Line, expr.OpPos.Column)
-				}
-				value := numx / numy
-				return newIntLiteralPlanExpression(value), nil
-
-			case parser.REM:
-				if numy == 0 {
-					return nil, sql3.NewErrDivideByZero(expr.OpPos.Line, expr.OpPos.Column)
-				}
-				value := numx % numy
-				return newIntLiteralPlanExpression(value), nil
-
-			default:
-				// run home to momma
-				return newBinOpPlanExpression(x, expr.Op, y, expr.ResultDataType), nil
-			}
-		} else {
-			return newBinOpPlanExpression(x, expr.Op, y, expr.ResultDataType), nil
+		if okx && oky && opy.value == 0 && (op == parser.SLASH || op == parser.REM) {
+			return nil, sql3.NewErrDivideByZero(expr.OpPos.Line, expr.OpPos.Column)
 		}
+		return newBinOpPlanExpression(x, expr.Op, y, expr.ResultDataType), nil
 
 	// bitwise operators
 	case parser.BITAND, parser.BITOR, parser.LSHIFT, parser.RSHIFT:
@@ -2919,7 +2938,12 @@ func (p *ExecutionPlanner) compileCallExpr(expr *parser.Call) (_ types.PlanExpre
 		return agg, nil
 
 	default:
-		return nil, sql3.NewErrCallUnknownFunction(0, 0, callName)
+		// scalar functions are evaluated by the call expression, once the
+		// analyzer has resolved them and set their result type
+		if expr.ResultDataType == nil {
+			return nil, sql3.NewErrCallUnknownFunction(expr.Name.NamePos.Line, expr.Name.NamePos.Column, callName)
+		}
+		return newCallPlanExpression(callName, args, expr.ResultDataType, nil), nil
 	}
 }
 
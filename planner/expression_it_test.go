@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gernest/sql3"
 	"github.com/gernest/sql3/parser"
 	"github.com/gernest/sql3/planner/types"
 	"github.com/stretchr/testify/assert"
@@ -35,11 +36,20 @@ func TestExpressions(t *testing.T) {
 		assert.Equal(t, cop.String(), "case 'foo' when '20' then 20 end when '30' then 20 end else 20 end")
 
 		bwop := newBetweenOpPlanExpression(newIntLiteralPlanExpression(10), parser.BETWEEN, newIntLiteralPlanExpression(20))
-		assert.Equal(t, bwop.String(), "between 10 and 20")
+		assert.Equal(t, bwop.String(), "10 between 20")
+
+		bwop1 := newBetweenOpPlanExpression(newIntLiteralPlanExpression(5), parser.NOTBETWEEN, rop)
+		assert.Equal(t, bwop1.String(), "5 not between 10 and 20")
 
 		iop := newInOpPlanExpression(newIntLiteralPlanExpression(10), parser.IN, newIntLiteralPlanExpression(20))
 		assert.Equal(t, iop.String(), "10 in (20)")
 
+		iop1 := newInOpPlanExpression(newIntLiteralPlanExpression(10), parser.NOTIN, newIntLiteralPlanExpression(20))
+		assert.Equal(t, iop1.String(), "10 not in (20)")
+
+		bop1 := newBinOpPlanExpression(newIntLiteralPlanExpression(10), parser.ISNOT, newNullLiteralPlanExpression(), parser.NewDataTypeBool())
+		assert.Equal(t, bop1.String(), "10 IS NOT null")
+
 		callop := newCallPlanExpression("foo", []types.PlanExpression{newIntLiteralPlanExpression(10)}, parser.NewDataTypeInt(), nil)
 		assert.Equal(t, callop.String(), "foo(10)")
 
@@ -83,4 +93,21 @@ func TestExpressions(t *testing.T) {
 		tplop := newExprTupleLiteralPlanExpression([]types.PlanExpression{newStringLiteralPlanExpression("foo"), newStringLiteralPlanExpression("bar")}, parser.NewDataTypeString())
 		assert.Equal(t, tplop.String(), "{'foo', 'bar'}")
 	})
+
+	t.Run("CompileCallTest", func(t *testing.T) {
+		p := &ExecutionPlanner{}
+
+		// a call the analyzer resolved has a result type
+		call := &parser.Call{
+			Name:           &parser.Ident{Name: "upper"},
+			Args:           []parser.Expr{&parser.StringLit{Value: "foo"}},
+			ResultDataType: parser.NewDataTypeString(),
+		}
+		expr, err := p.compileCallExpr(call)
+		assert.NoError(t, err)
+		assert.Equal(t, "UPPER('foo')", expr.String())
+
+		_, err = p.compileCallExpr(&parser.Call{Name: &parser.Ident{Name: "nope"}})
+		assert.ErrorIs(t, err, sql3.ErrCallUnknownFunction)
+	})
 }
@@ -6,6 +6,8 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
+	"time"
 
 	"github.com/gernest/sql3"
 	"github.com/gernest/sql3/decimal"
@@ -323,7 +325,10 @@ func (m *aggregateSum) Update(ctx context.Context, row types.Row) error {
 		} else {
 			dsum = 0
 		}
-		dsum = dsum + val
+		dsum, ok = addInt64(dsum, val)
+		if !ok {
+			return sql3.NewErrOutputValueOutOfRange(0, 0)
+		}
 		m.sum = dsum
 
 	default:
@@ -332,7 +337,17 @@ func (m *aggregateSum) Update(ctx context.Context, row types.Row) error {
 	return nil
 }
 
+// addInt64 returns a + b, and false if the sum overflows an int64
+func addInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	return sum, (sum >= a) == (b >= 0)
+}
+
 func (m *aggregateSum) Eval(ctx context.Context) (interface{}, error) {
+	// the sum of no values is null
+	if m.sum == nil {
+		return nil, nil
+	}
 	switch m.expr.Type().(type) {
 	case *parser.DataTypeDecimal:
 		dsum, ok := m.sum.(decimal.Decimal)
@@ -643,6 +658,21 @@ func (m *aggregateMin) Update(ctx context.Context, row types.Row) error {
 			m.val = thisVal
 		}
 
+	case *parser.DataTypeTimestamp:
+		thisVal, ok := v.(time.Time)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected type conversion '%T'", v)
+		}
+
+		aggVal, ok := m.val.(time.Time)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected type conversion '%T'", v)
+		}
+
+		if thisVal.Before(aggVal) {
+			m.val = thisVal
+		}
+
 	case *parser.DataTypeString:
 		thisVal, ok := v.(string)
 		if !ok {
@@ -792,6 +822,21 @@ func (m *aggregateMax) Update(ctx context.Context, row types.Row) error {
 			m.val = thisVal
 		}
 
+	case *parser.DataTypeTimestamp:
+		thisVal, ok := v.(time.Time)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected type conversion '%T'", v)
+		}
+
+		aggVal, ok := m.val.(time.Time)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected type conversion '%T'", v)
+		}
+
+		if thisVal.After(aggVal) {
+			m.val = thisVal
+		}
+
 	case *parser.DataTypeString:
 		thisVal, ok := v.(string)
 		if !ok {
@@ -879,6 +924,82 @@ func (n *maxPlanExpression) WithChildren(children ...types.PlanExpression) (type
 	return newMaxPlanExpression(children[0], n.returnDataType), nil
 }
 
+const (
+	// percentileScale is the scale of the percentile asked for by PERCENTILE
+	percentileScale = 4
+	// percentileMax is 100 percent at percentileScale
+	percentileMax = 100 * 10000
+)
+
+// percentileRank returns the rank, counting from 1, of the value at the nth
+// percentile of count values, where nth is scaled by percentileScale. It uses
+// the nearest rank method, so the value is always one of the values.
+func percentileRank(nth int64, count int64) int64 {
+	rank := (nth*count + percentileMax - 1) / percentileMax
+	if rank < 1 {
+		rank = 1
+	}
+	return rank
+}
+
+// aggregator for PERCENTILE(). It keeps every value, so it is only suitable
+// for small inputs; the optimizer computes percentiles over large tables from
+// bitmaps instead.
+type aggregatePercentile struct {
+	values []interface{}
+	expr   *percentilePlanExpression
+}
+
+func NewAggPercentileBuffer(child *percentilePlanExpression) *aggregatePercentile {
+	return &aggregatePercentile{
+		values: make([]interface{}, 0),
+		expr:   child,
+	}
+}
+
+func (m *aggregatePercentile) Update(ctx context.Context, row types.Row) error {
+	v, err := m.expr.Evaluate(row)
+	if err != nil {
+		return err
+	}
+
+	// skip if nil
+	if v == nil {
+		return nil
+	}
+
+	switch v.(type) {
+	case int64, decimal.Decimal, time.Time:
+		m.values = append(m.values, v)
+	default:
+		return sql3.NewErrInternalf("unexpected type conversion '%T'", v)
+	}
+	return nil
+}
+
+func (m *aggregatePercentile) Eval(ctx context.Context) (interface{}, error) {
+	// the percentile of no values is null
+	if len(m.values) == 0 {
+		return nil, nil
+	}
+	nth, err := m.expr.nth()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(m.values, func(i, j int) bool {
+		switch a := m.values[i].(type) {
+		case int64:
+			return a < m.values[j].(int64)
+		case decimal.Decimal:
+			return a.LessThan(m.values[j].(decimal.Decimal))
+		case time.Time:
+			return a.Before(m.values[j].(time.Time))
+		}
+		return false
+	})
+	return m.values[percentileRank(nth, int64(len(m.values)))-1], nil
+}
+
 // percentilePlanExpression handles PERCENTILE()
 type percentilePlanExpression struct {
 	pos            parser.Pos
@@ -907,7 +1028,33 @@ func (n *percentilePlanExpression) Evaluate(currentRow []interface{}) (interface
 }
 
 func (n *percentilePlanExpression) NewBuffer() (types.AggregationBuffer, error) {
-	return nil, sql3.NewErrUnsupported(n.pos.Line, n.pos.Column, true, "Percentile call that can't be pushed down to PQL")
+	return NewAggPercentileBuffer(n), nil
+}
+
+// nth returns the percentile asked for, as an integer scaled by
+// percentileScale
+func (n *percentilePlanExpression) nth() (int64, error) {
+	v, err := n.nthArg.Evaluate(nil)
+	if err != nil {
+		return 0, err
+	}
+	var d decimal.Decimal
+	switch val := v.(type) {
+	case int64:
+		d = decimal.NewDecimal(val, 0)
+	case decimal.Decimal:
+		d = val
+	default:
+		return 0, sql3.NewErrInternalf("unexpected type conversion '%T'", v)
+	}
+	if !d.SupportedByScale(percentileScale) {
+		return 0, sql3.NewErrValueOutOfRange(n.pos.Line, n.pos.Column, v)
+	}
+	nth := d.ToInt64(percentileScale)
+	if nth < 0 || nth > percentileMax {
+		return 0, sql3.NewErrValueOutOfRange(n.pos.Line, n.pos.Column, v)
+	}
+	return nth, nil
 }
 
 func (n *percentilePlanExpression) FirstChildExpr() types.PlanExpression {
@@ -919,7 +1066,7 @@ func (n *percentilePlanExpression) Type() parser.ExprDataType {
 }
 
 func (n *percentilePlanExpression) String() string {
-	return fmt.Sprintf("percentile(%s)", n.arg.String())
+	return fmt.Sprintf("percentile(%s, %s)", n.arg.String(), n.nthArg.String())
 }
 
 func (n *percentilePlanExpression) Plan() map[string]interface{} {
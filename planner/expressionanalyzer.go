@@ -109,6 +109,29 @@ func (p *ExecutionPlanner) analyzeExpression(ctx context.Context, expr parser.Ex
 			}
 			return p.analyzeExpression(ctx, ident, scope)
 
+		case *parser.UpdateStatement:
+
+			// go find the ident in the table being updated
+			oc, err := sc.Table.OutputColumnNamed(e.Name)
+			if err != nil {
+				return nil, err
+			} else if oc == nil {
+				return nil, sql3.NewErrColumnNotFound(e.NamePos.Line, e.NamePos.Column, e.Name)
+			}
+
+			ident := &parser.QualifiedRef{
+				Table: &parser.Ident{
+					Name:    oc.TableName,
+					NamePos: e.NamePos,
+				},
+				Column: &parser.Ident{
+					Name:    oc.ColumnName,
+					NamePos: e.NamePos,
+				},
+				ColumnIndex: oc.ColumnIndex,
+			}
+			return p.analyzeExpression(ctx, ident, scope)
+
 		default:
 			return nil, sql3.NewErrInternalf("unhandled scope type '%T'", sc)
 		}
@@ -264,6 +287,19 @@ func (p *ExecutionPlanner) analyzeExpression(ctx context.Context, expr parser.Ex
 			}
 			return nil, sql3.NewErrColumnNotFound(e.Column.NamePos.Line, e.Column.NamePos.Column, e.Column.Name)
 
+		case *parser.UpdateStatement:
+			oc, err := sc.Table.OutputColumnQualifierNamed(e.Table.Name, e.Column.Name)
+			if err != nil {
+				return nil, err
+			}
+			if oc != nil {
+				e.RefDataType = oc.Datatype
+				e.ColumnIndex = oc.ColumnIndex
+				return e, nil
+
+			}
+			return nil, sql3.NewErrColumnNotFound(e.Column.NamePos.Line, e.Column.NamePos.Column, e.Column.Name)
+
 		default:
 			return nil, sql3.NewErrInternalf("unhandled scope type '%T'", sc)
 		}
@@ -389,6 +425,14 @@ func (p *ExecutionPlanner) analyzeUnaryExpression(ctx context.Context, expr *par
 		}
 		return expr, nil
 
+	//logical operators
+	case parser.NOT:
+		if !typeIsCompatibleWithLogicalOperator(x.DataType()) {
+			return nil, sql3.NewErrTypeIncompatibleWithLogicalOperator(x.Pos().Line, x.Pos().Column, op.String(), x.DataType().TypeDescription())
+		}
+		expr.ResultDataType = parser.NewDataTypeBool()
+		return expr, nil
+
 	default:
 		return nil, sql3.NewErrInternalf("unexpected unary expression operator: %s", op)
 	}
@@ -96,6 +96,9 @@ func dataTypeFromParserType(typ *parser.Type) (parser.ExprDataType, error) {
 	case dax.BaseTypeIDSet:
 		return parser.NewDataTypeIDSet(), nil
 
+	case dax.BaseTypeIDSetQ:
+		return parser.NewDataTypeIDSetQuantum(), nil
+
 	case dax.BaseTypeInt:
 		return parser.NewDataTypeInt(), nil
 
@@ -105,6 +108,9 @@ func dataTypeFromParserType(typ *parser.Type) (parser.ExprDataType, error) {
 	case dax.BaseTypeStringSet:
 		return parser.NewDataTypeStringSet(), nil
 
+	case dax.BaseTypeStringSetQ:
+		return parser.NewDataTypeStringSetQuantum(), nil
+
 	case dax.BaseTypeTimestamp:
 		return parser.NewDataTypeTimestamp(), nil
 
@@ -0,0 +1,528 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"time"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/parser"
+)
+
+// importBatch accumulates records destined for a table and writes them
+// through the importer, one ImportRoaringShard request per shard.
+//
+// Values are expected to have been coerced to the type of the field they are
+// written to; a nil value clears the field for that record. When replace is
+// true, the existing values of set fields are cleared before the new values
+// are set (values of single valued fields are always replaced).
+type importBatch struct {
+	planner *ExecutionPlanner
+	table   *dax.Table
+	fields  []*dax.Field
+	replace bool
+
+	ids    []interface{}
+	values [][]interface{}
+}
+
+func newImportBatch(planner *ExecutionPlanner, table *dax.Table, fields []*dax.Field, replace bool) *importBatch {
+	return &importBatch{
+		planner: planner,
+		table:   table,
+		fields:  fields,
+		replace: replace,
+	}
+}
+
+// add adds a record to the batch. id is the value of the _id column, values
+// are in the same order as the fields of the batch.
+func (b *importBatch) add(id interface{}, values []interface{}) {
+	b.ids = append(b.ids, id)
+	b.values = append(b.values, values)
+}
+
+func (b *importBatch) len() int {
+	return len(b.ids)
+}
+
+// flush writes all the records in the batch and resets it.
+func (b *importBatch) flush(ctx context.Context) error {
+	if b.len() == 0 {
+		return nil
+	}
+	defer func() {
+		b.ids = b.ids[:0]
+		b.values = b.values[:0]
+	}()
+
+	recordIDs, err := b.recordIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	fieldKeys := make([]map[string]uint64, len(b.fields))
+	for i, fld := range b.fields {
+		if !fld.StringKeys() {
+			continue
+		}
+		fieldKeys[i], err = b.createFieldKeys(ctx, i, fld)
+		if err != nil {
+			return err
+		}
+	}
+
+	// records are visited last to first so that when a record appears more
+	// than once, the last value written to a single valued field wins, and
+	// the last row for a record replaces it entirely
+	shards := make(map[uint64]*shardUpdates)
+	seen := make(map[uint64]bool)
+	for r := len(recordIDs) - 1; r >= 0; r-- {
+		id := recordIDs[r]
+		dup := seen[id]
+		seen[id] = true
+		if dup && b.replace {
+			continue
+		}
+		shard := id / api.ShardWidth
+		su, ok := shards[shard]
+		if !ok {
+			su = newShardUpdates()
+			shards[shard] = su
+		}
+		su.set(api.ExistenceFieldName, api.ViewStandard).DirectAdd(api.Pos(0, id))
+
+		for i, fld := range b.fields {
+			if dup && (b.values[r][i] == nil || !isSetField(fld)) {
+				continue
+			}
+			if err := su.addValue(fld, id, b.values[r][i], fieldKeys[i], b.replace); err != nil {
+				return err
+			}
+		}
+	}
+
+	for shard, su := range shards {
+		err := b.planner.importer.ImportRoaringShard(ctx, b.table.ID, shard, &api.ImportRoaringShardRequest{
+			Views: su.updates(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordIDs returns the record id for each record in the batch, creating keys
+// for tables with string keys.
+func (b *importBatch) recordIDs(ctx context.Context) ([]uint64, error) {
+	result := make([]uint64, len(b.ids))
+	if b.table.StringKeys() {
+		keys := make([]string, len(b.ids))
+		for i, id := range b.ids {
+			key, ok := id.(string)
+			if !ok {
+				return nil, sql3.NewErrInternalf("unexpected _id value type '%T'", id)
+			}
+			keys[i] = key
+		}
+		ids, err := b.planner.importer.CreateTableKeys(ctx, b.table.ID, keys...)
+		if err != nil {
+			return nil, err
+		}
+		for i, key := range keys {
+			result[i] = ids[key]
+		}
+		return result, nil
+	}
+
+	for i, id := range b.ids {
+		switch v := id.(type) {
+		case int64:
+			if v < 0 {
+				return nil, sql3.NewErrInsertValueOutOfRange(0, 0, string(dax.PrimaryKeyFieldName), i+1, v)
+			}
+			result[i] = uint64(v)
+		case uint64:
+			result[i] = v
+		default:
+			return nil, sql3.NewErrInternalf("unexpected _id value type '%T'", id)
+		}
+	}
+	return result, nil
+}
+
+// createFieldKeys creates keys for all the values of a string keyed field in
+// the batch.
+func (b *importBatch) createFieldKeys(ctx context.Context, idx int, fld *dax.Field) (map[string]uint64, error) {
+	keys := make([]string, 0)
+	for _, row := range b.values {
+		switch v := row[idx].(type) {
+		case string:
+			keys = append(keys, v)
+		case []string:
+			keys = append(keys, v...)
+		case []interface{}:
+			if len(v) == 2 {
+				if set, ok := v[1].([]string); ok {
+					keys = append(keys, set...)
+				}
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return map[string]uint64{}, nil
+	}
+	return b.planner.importer.CreateFieldKeys(ctx, b.table.ID, fld.Name, keys...)
+}
+
+// shardUpdates collects the bits to clear and set for each view in a shard.
+type shardUpdates struct {
+	order []fieldView
+	sets  map[fieldView]*roaring.Bitmap
+	clear map[fieldView]*roaring.Bitmap
+}
+
+type fieldView struct {
+	field dax.FieldName
+	view  string
+}
+
+func newShardUpdates() *shardUpdates {
+	return &shardUpdates{
+		sets:  make(map[fieldView]*roaring.Bitmap),
+		clear: make(map[fieldView]*roaring.Bitmap),
+	}
+}
+
+func (s *shardUpdates) touch(fv fieldView) {
+	if _, ok := s.sets[fv]; ok {
+		return
+	}
+	s.order = append(s.order, fv)
+	s.sets[fv] = roaring.NewSliceBitmap()
+	s.clear[fv] = roaring.NewSliceBitmap()
+}
+
+// set returns the bitmap of bits to set in a view.
+func (s *shardUpdates) set(field dax.FieldName, view string) *roaring.Bitmap {
+	fv := fieldView{field: field, view: view}
+	s.touch(fv)
+	return s.sets[fv]
+}
+
+// clearRecords returns the bitmap of records to clear from every row of a
+// view.
+func (s *shardUpdates) clearRecords(field dax.FieldName, view string) *roaring.Bitmap {
+	fv := fieldView{field: field, view: view}
+	s.touch(fv)
+	return s.clear[fv]
+}
+
+func (s *shardUpdates) updates() []api.RoaringUpdate {
+	// records are cleared from every view of a field before any bits are
+	// set in its views
+	order := make([]fieldView, 0, len(s.order))
+	for _, fv := range s.order {
+		if fv.view == api.ViewAll {
+			order = append(order, fv)
+		}
+	}
+	for _, fv := range s.order {
+		if fv.view != api.ViewAll {
+			order = append(order, fv)
+		}
+	}
+
+	result := make([]api.RoaringUpdate, 0, len(order))
+	for _, fv := range order {
+		u := api.RoaringUpdate{
+			Field: string(fv.field),
+			View:  fv.view,
+			Set:   s.sets[fv].Roaring(),
+		}
+		if clear := s.clear[fv]; clear.Any() {
+			u.Clear = clear.Roaring()
+			u.ClearRecords = true
+		}
+		result = append(result, u)
+	}
+	return result
+}
+
+// addValue adds the bits for a single value of a field.
+func (s *shardUpdates) addValue(fld *dax.Field, id uint64, value interface{}, keys map[string]uint64, replace bool) error {
+	view := api.FieldView(fld)
+	col := id % api.ShardWidth
+
+	if value == nil {
+		if replace {
+			s.clearRecords(fld.Name, api.ViewAll).DirectAdd(col)
+		} else {
+			s.clearRecords(fld.Name, view).DirectAdd(col)
+		}
+		return nil
+	}
+
+	// single valued fields are cleared before the new value is set, which for
+	// bit-sliced fields rewrites every bit of the value
+	if !isSetField(fld) {
+		s.clearRecords(fld.Name, view).DirectAdd(col)
+	}
+
+	switch fld.Type {
+	case dax.BaseTypeID:
+		row, ok := value.(int64)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected value type '%T'", value)
+		}
+		if row < 0 {
+			return sql3.NewErrInsertValueOutOfRange(0, 0, string(fld.Name), 0, row)
+		}
+		s.set(fld.Name, view).DirectAdd(api.Pos(uint64(row), col))
+
+	case dax.BaseTypeString:
+		key, ok := value.(string)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected value type '%T'", value)
+		}
+		s.set(fld.Name, view).DirectAdd(api.Pos(keys[key], col))
+
+	case dax.BaseTypeBool:
+		v, ok := value.(bool)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected value type '%T'", value)
+		}
+		row := uint64(api.FalseRowID)
+		if v {
+			row = api.TrueRowID
+		}
+		s.set(fld.Name, view).DirectAdd(api.Pos(row, col))
+
+	case dax.BaseTypeInt:
+		v, ok := value.(int64)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected value type '%T'", value)
+		}
+		api.SetBSI(s.set(fld.Name, view), col, v)
+
+	case dax.BaseTypeDecimal:
+		v, ok := value.(decimal.Decimal)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected value type '%T'", value)
+		}
+		api.SetBSI(s.set(fld.Name, view), col, v.ToInt64(fld.Options.Scale))
+
+	case dax.BaseTypeTimestamp:
+		v, ok := value.(time.Time)
+		if !ok {
+			return sql3.NewErrInternalf("unexpected value type '%T'", value)
+		}
+		api.SetBSI(s.set(fld.Name, view), col, api.TimestampToVal(fld, v))
+
+	case dax.BaseTypeIDSet, dax.BaseTypeStringSet:
+		rows, err := setRows(value, keys)
+		if err != nil {
+			return err
+		}
+		if replace {
+			s.clearRecords(fld.Name, view).DirectAdd(col)
+		}
+		bm := s.set(fld.Name, view)
+		for _, row := range rows {
+			bm.DirectAdd(api.Pos(row, col))
+		}
+
+	case dax.BaseTypeIDSetQ, dax.BaseTypeStringSetQ:
+		var ts interface{}
+		set := value
+		if tuple, ok := value.([]interface{}); ok {
+			if len(tuple) != 2 {
+				return sql3.NewErrUnexpectedTimeQuantumTupleLength(0, 0, string(fld.Name), 0, tuple, 2)
+			}
+			ts, set = tuple[0], tuple[1]
+		}
+		// replacing a value removes it from the time views too
+		if replace {
+			s.clearRecords(fld.Name, api.ViewAll).DirectAdd(col)
+		}
+		if set == nil {
+			s.clearRecords(fld.Name, view).DirectAdd(col)
+			return nil
+		}
+		rows, err := setRows(set, keys)
+		if err != nil {
+			return err
+		}
+		views := []string{}
+		if !fld.Options.NoStandardView {
+			views = append(views, view)
+		}
+		if tm, ok := ts.(time.Time); ok {
+			views = append(views, api.ViewsByTime(tm, fld.Options.TimeQuantum)...)
+		}
+		for _, v := range views {
+			bm := s.set(fld.Name, v)
+			for _, row := range rows {
+				bm.DirectAdd(api.Pos(row, col))
+			}
+		}
+
+	default:
+		return sql3.NewErrInternalf("unexpected field type '%s'", fld.Type)
+	}
+	return nil
+}
+
+// isSetField returns true if a record can hold more than one value for fld
+func isSetField(fld *dax.Field) bool {
+	switch fld.Type {
+	case dax.BaseTypeIDSet, dax.BaseTypeIDSetQ, dax.BaseTypeStringSet, dax.BaseTypeStringSetQ:
+		return true
+	}
+	return false
+}
+
+// setRows returns the row ids for the members of a set value
+func setRows(value interface{}, keys map[string]uint64) ([]uint64, error) {
+	switch v := value.(type) {
+	case []int64:
+		rows := make([]uint64, len(v))
+		for i, m := range v {
+			if m < 0 {
+				return nil, sql3.NewErrValueOutOfRange(0, 0, m)
+			}
+			rows[i] = uint64(m)
+		}
+		return rows, nil
+	case []string:
+		rows := make([]uint64, len(v))
+		for i, m := range v {
+			rows[i] = keys[m]
+		}
+		return rows, nil
+	default:
+		return nil, sql3.NewErrInternalf("unexpected value type '%T'", value)
+	}
+}
+
+// insertTarget maps the target columns of an insert onto the fields of the
+// table
+type insertTarget struct {
+	table        *dax.Table
+	idIndex      int
+	fields       []*dax.Field
+	fieldColumns []int
+	columnTypes  []parser.ExprDataType
+}
+
+func newInsertTarget(tbl *dax.Table, targetColumns []*qualifiedRefPlanExpression) (*insertTarget, error) {
+	t := &insertTarget{
+		table:       tbl,
+		idIndex:     -1,
+		columnTypes: make([]parser.ExprDataType, len(targetColumns)),
+	}
+	for idx, col := range targetColumns {
+		fld, ok := tbl.Field(dax.FieldName(col.columnName))
+		if !ok {
+			return nil, sql3.NewErrColumnNotFound(0, 0, col.columnName)
+		}
+		t.columnTypes[idx] = col.dataType
+		if fld.IsPrimaryKey() {
+			t.idIndex = idx
+			continue
+		}
+		t.fields = append(t.fields, fld)
+		t.fieldColumns = append(t.fieldColumns, idx)
+	}
+	if t.idIndex < 0 {
+		return nil, sql3.NewErrInsertMustHaveIDColumn(0, 0)
+	}
+	return t, nil
+}
+
+// record returns the _id and the field values for a row of values for the
+// target columns. rowTypes are the types of the values in row.
+func (t *insertTarget) record(row []interface{}, rowTypes []parser.ExprDataType, rowNumber int) (interface{}, []interface{}, error) {
+	id := row[t.idIndex]
+	if id == nil {
+		return nil, nil, sql3.NewErrInsertValueOutOfRange(0, 0, string(dax.PrimaryKeyFieldName), rowNumber, id)
+	}
+	id, err := coerceValue(rowTypes[t.idIndex], t.columnTypes[t.idIndex], id, parser.Pos{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]interface{}, len(t.fields))
+	for i, fld := range t.fields {
+		col := t.fieldColumns[i]
+		values[i], err = columnValue(fld, rowTypes[col], row[col], rowNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return id, values, nil
+}
+
+// columnValue coerces a value of sourceType to the type of the field it is
+// written to, and checks it against the constraints of the field. rowNumber is
+// used for error reporting.
+func columnValue(fld *dax.Field, sourceType parser.ExprDataType, value interface{}, rowNumber int) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	targetType := fieldSQLDataType(api.FieldToFieldInfo(fld))
+
+	// the timestamp member of a quantum tuple may need coercing too
+	if tt, ok := sourceType.(*parser.DataTypeTuple); ok {
+		tuple, ok := value.([]interface{})
+		if !ok || len(tuple) != 2 || len(tt.Members) != 2 {
+			return nil, sql3.NewErrUnexpectedTimeQuantumTupleLength(0, 0, string(fld.Name), rowNumber, tuple, 2)
+		}
+		ts := tuple[0]
+		if ts != nil {
+			var err error
+			ts, err = coerceValue(tt.Members[0], parser.NewDataTypeTimestamp(), ts, parser.Pos{})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return []interface{}{ts, tuple[1]}, nil
+	}
+
+	v, err := coerceValue(sourceType, targetType, value, parser.Pos{})
+	if err != nil {
+		return nil, err
+	}
+
+	switch fld.Type {
+	case dax.BaseTypeInt:
+		i, ok := v.(int64)
+		if !ok {
+			return nil, sql3.NewErrInternalf("unexpected value type '%T'", v)
+		}
+		if fld.Options.Max > fld.Options.Min && (i < fld.Options.Min || i > fld.Options.Max) {
+			return nil, sql3.NewErrInsertValueOutOfRange(0, 0, string(fld.Name), rowNumber, i)
+		}
+
+	case dax.BaseTypeDecimal:
+		d, ok := v.(decimal.Decimal)
+		if !ok {
+			return nil, sql3.NewErrInternalf("unexpected value type '%T'", v)
+		}
+		if !d.SupportedByScale(fld.Options.Scale) {
+			return nil, sql3.NewErrInsertValueOutOfRange(0, 0, string(fld.Name), rowNumber, d)
+		}
+		if fld.Options.Max > fld.Options.Min {
+			if i := d.ToInt64(fld.Options.Scale); i < fld.Options.Min || i > fld.Options.Max {
+				return nil, sql3.NewErrInsertValueOutOfRange(0, 0, string(fld.Name), rowNumber, d)
+			}
+		}
+	}
+	return v, nil
+}
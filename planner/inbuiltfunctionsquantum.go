@@ -1,8 +1,11 @@
 package planner
 
 import (
+	"strings"
+
 	"github.com/gernest/sql3"
 	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
 )
 
 func (p *ExecutionPlanner) analyzeFunctionRangeQ(call *parser.Call, scope parser.Statement) (parser.Expr, error) {
@@ -40,6 +43,29 @@ func (p *ExecutionPlanner) analyzeFunctionRangeQ(call *parser.Call, scope parser
 	return call, nil
 }
 
+// isRangeQFilter returns true if expr is a call to rangeq() that can be pushed
+// down into a scan as a time quantum filter, which requires the bounds not to
+// depend on the row
+func isRangeQFilter(expr types.PlanExpression) bool {
+	call, ok := expr.(*callPlanExpression)
+	if !ok || !strings.EqualFold(call.name, "RANGEQ") || len(call.args) != 3 {
+		return false
+	}
+	if _, ok := call.args[0].(*qualifiedRefPlanExpression); !ok {
+		return false
+	}
+	constant := true
+	for _, arg := range call.args[1:] {
+		InspectExpression(arg, func(expr types.PlanExpression) bool {
+			if _, ok := expr.(*qualifiedRefPlanExpression); ok {
+				constant = false
+			}
+			return constant
+		})
+	}
+	return constant
+}
+
 func (n *callPlanExpression) EvaluateRangeQ(currentRow []interface{}) (interface{}, error) {
 	// rangeq() should only ever be used as a push down filter for now - if we get to here, we should error
 	return nil, sql3.NewErrQRangeInvalidUse(0, 0)
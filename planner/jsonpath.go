@@ -0,0 +1,168 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type jsonPathSelectorKind int
+
+const (
+	jsonPathChild jsonPathSelectorKind = iota
+	jsonPathIndex
+	jsonPathWildcard
+)
+
+type jsonPathSelector struct {
+	kind  jsonPathSelectorKind
+	name  string
+	index int
+}
+
+// jsonPath is a compiled JSONPath expression. The subset of JSONPath supported
+// is the root ($), child (.name and ['name']), array index ([n], where a
+// negative n counts from the end of the array) and wildcard (.* and [*])
+// selectors.
+type jsonPath struct {
+	expr      string
+	selectors []jsonPathSelector
+	wildcard  bool
+}
+
+func parseJSONPath(expr string) (*jsonPath, error) {
+	p := &jsonPath{
+		expr: expr,
+	}
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("path must start with '$'")
+	}
+	i := 1
+	for i < len(s) {
+		switch s[i] {
+		case '.':
+			i++
+			if i < len(s) && s[i] == '*' {
+				p.selectors = append(p.selectors, jsonPathSelector{kind: jsonPathWildcard})
+				i++
+				continue
+			}
+			start := i
+			for i < len(s) && s[i] != '.' && s[i] != '[' {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("expected name at offset %d", start)
+			}
+			p.selectors = append(p.selectors, jsonPathSelector{kind: jsonPathChild, name: s[start:i]})
+
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '[' at offset %d", i)
+			}
+			sub := strings.TrimSpace(s[i+1 : i+end])
+			switch {
+			case sub == "*":
+				p.selectors = append(p.selectors, jsonPathSelector{kind: jsonPathWildcard})
+			case len(sub) >= 2 && (sub[0] == '\'' || sub[0] == '"') && sub[len(sub)-1] == sub[0]:
+				p.selectors = append(p.selectors, jsonPathSelector{kind: jsonPathChild, name: sub[1 : len(sub)-1]})
+			default:
+				idx, err := strconv.Atoi(sub)
+				if err != nil {
+					return nil, fmt.Errorf("invalid subscript '%s' at offset %d", sub, i)
+				}
+				p.selectors = append(p.selectors, jsonPathSelector{kind: jsonPathIndex, index: idx})
+			}
+			i += end + 1
+
+		default:
+			return nil, fmt.Errorf("unexpected '%c' at offset %d", s[i], i)
+		}
+	}
+	for _, sel := range p.selectors {
+		if sel.kind == jsonPathWildcard {
+			p.wildcard = true
+		}
+	}
+	return p, nil
+}
+
+func (p *jsonPath) String() string {
+	return p.expr
+}
+
+// evaluate returns the value selected by the path from doc, which is a value
+// decoded by encoding/json. If the path contains a wildcard, the result is a
+// []interface{} containing all the values selected; otherwise it is an error
+// for the path to select nothing.
+func (p *jsonPath) evaluate(doc interface{}) (interface{}, error) {
+	nodes := []interface{}{doc}
+	for _, sel := range p.selectors {
+		next := make([]interface{}, 0, len(nodes))
+		for _, node := range nodes {
+			switch sel.kind {
+			case jsonPathChild:
+				obj, ok := node.(map[string]interface{})
+				if !ok {
+					if p.wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("cannot select '%s' from a non-object", sel.name)
+				}
+				v, ok := obj[sel.name]
+				if !ok {
+					if p.wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("key '%s' not found", sel.name)
+				}
+				next = append(next, v)
+
+			case jsonPathIndex:
+				arr, ok := node.([]interface{})
+				if !ok {
+					if p.wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("cannot index a non-array")
+				}
+				idx := sel.index
+				if idx < 0 {
+					idx += len(arr)
+				}
+				if idx < 0 || idx >= len(arr) {
+					if p.wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("index %d out of range", sel.index)
+				}
+				next = append(next, arr[idx])
+
+			case jsonPathWildcard:
+				switch v := node.(type) {
+				case []interface{}:
+					next = append(next, v...)
+				case map[string]interface{}:
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	if p.wildcard {
+		return nodes, nil
+	}
+	return nodes[0], nil
+}
@@ -0,0 +1,42 @@
+package planner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"a": {"b": [1, 2, {"c": "x"}]}, "d e": true, "f": [{"g": 1}, {"g": 2}, {}]}`), &doc))
+
+	for path, want := range map[string]interface{}{
+		"$":          doc,
+		"$.a.b[0]":   float64(1),
+		"$.a.b[-1]":  map[string]interface{}{"c": "x"},
+		"$.a.b[2].c": "x",
+		"$['d e']":   true,
+		"$.f[*].g":   []interface{}{float64(1), float64(2)},
+		"$.a.b.*":    []interface{}{float64(1), float64(2), map[string]interface{}{"c": "x"}},
+	} {
+		p, err := parseJSONPath(path)
+		require.NoError(t, err, path)
+		got, err := p.evaluate(doc)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, got, path)
+	}
+
+	for _, path := range []string{"$.x", "$.a.b[3]", "$.a.c", "$['d e'].x"} {
+		p, err := parseJSONPath(path)
+		require.NoError(t, err, path)
+		_, err = p.evaluate(doc)
+		assert.Error(t, err, path)
+	}
+
+	for _, path := range []string{"", "a.b", "$.", "$[", "$[x]", "$a"} {
+		_, err := parseJSONPath(path)
+		assert.Error(t, err, path)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpAlterDatabase plan operator to set an option on a database.
+type PlanOpAlterDatabase struct {
+	planner      *ExecutionPlanner
+	databaseName string
+	option       string
+	value        string
+	warnings     []string
+}
+
+func NewPlanOpAlterDatabase(p *ExecutionPlanner, databaseName string, option string, value string) *PlanOpAlterDatabase {
+	return &PlanOpAlterDatabase{
+		planner:      p,
+		databaseName: databaseName,
+		option:       option,
+		value:        value,
+		warnings:     make([]string, 0),
+	}
+}
+
+func (p *PlanOpAlterDatabase) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["databaseName"] = p.databaseName
+	result["option"] = p.option
+	result["value"] = p.value
+	return result
+}
+
+func (p *PlanOpAlterDatabase) String() string {
+	return fmt.Sprintf("AlterDatabase(%s; set %s %s)", p.databaseName, p.option, p.value)
+}
+
+func (p *PlanOpAlterDatabase) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpAlterDatabase) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpAlterDatabase) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpAlterDatabase) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpAlterDatabase) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &alterDatabaseRowIter{
+		planner:      p.planner,
+		databaseName: p.databaseName,
+		option:       p.option,
+		value:        p.value,
+	}, nil
+}
+
+func (p *PlanOpAlterDatabase) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+type alterDatabaseRowIter struct {
+	planner      *ExecutionPlanner
+	databaseName string
+	option       string
+	value        string
+}
+
+var _ types.RowIterator = (*alterDatabaseRowIter)(nil)
+
+func (i *alterDatabaseRowIter) Next(ctx context.Context) (types.Row, error) {
+	// options are set by database id
+	db, err := i.planner.schemaAPI.DatabaseByName(ctx, dax.DatabaseName(i.databaseName))
+	if err == nil {
+		err = i.planner.schemaAPI.SetDatabaseOption(ctx, db.ID, i.option, i.value)
+	}
+	if err != nil {
+		if isDatabaseNotFoundError(err) {
+			return nil, sql3.NewErrDatabaseNotFound(0, 0, i.databaseName)
+		}
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/planner/types"
+)
+
+type alterOperation int8
+
+const (
+	alterOpAdd alterOperation = iota
+	alterOpDrop
+)
+
+func (o alterOperation) String() string {
+	switch o {
+	case alterOpAdd:
+		return "ADD"
+	case alterOpDrop:
+		return "DROP"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// PlanOpAlterTable plan operator to alter a table, adding or dropping a
+// column.
+type PlanOpAlterTable struct {
+	planner    *ExecutionPlanner
+	tableName  string
+	operation  alterOperation
+	field      *dax.Field
+	columnName string
+	warnings   []string
+}
+
+func NewPlanOpAlterTable(p *ExecutionPlanner, tableName string, operation alterOperation, field *dax.Field, columnName string) *PlanOpAlterTable {
+	return &PlanOpAlterTable{
+		planner:    p,
+		tableName:  tableName,
+		operation:  operation,
+		field:      field,
+		columnName: columnName,
+		warnings:   make([]string, 0),
+	}
+}
+
+func (p *PlanOpAlterTable) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	result["operation"] = p.operation.String()
+	switch p.operation {
+	case alterOpAdd:
+		result["column"] = p.field.CreateSQL()
+	case alterOpDrop:
+		result["column"] = p.columnName
+	}
+	return result
+}
+
+func (p *PlanOpAlterTable) String() string {
+	column := p.columnName
+	if p.operation == alterOpAdd {
+		column = p.field.CreateSQL()
+	}
+	return fmt.Sprintf("AlterTable(%s; %s column %s)", p.tableName, p.operation, column)
+}
+
+func (p *PlanOpAlterTable) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpAlterTable) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpAlterTable) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpAlterTable) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpAlterTable) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &alterTableRowIter{
+		planner:    p.planner,
+		tableName:  p.tableName,
+		operation:  p.operation,
+		field:      p.field,
+		columnName: p.columnName,
+	}, nil
+}
+
+func (p *PlanOpAlterTable) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+type alterTableRowIter struct {
+	planner    *ExecutionPlanner
+	tableName  string
+	operation  alterOperation
+	field      *dax.Field
+	columnName string
+}
+
+var _ types.RowIterator = (*alterTableRowIter)(nil)
+
+func (i *alterTableRowIter) Next(ctx context.Context) (types.Row, error) {
+	var err error
+	switch i.operation {
+	case alterOpAdd:
+		fld := *i.field
+		err = i.planner.schemaAPI.CreateField(ctx, dax.TableName(i.tableName), &fld)
+		if errors.Is(err, dax.ErrFieldExists) {
+			return nil, sql3.NewErrDuplicateColumn(0, 0, string(fld.Name))
+		}
+
+	case alterOpDrop:
+		err = i.planner.schemaAPI.DeleteField(ctx, dax.TableName(i.tableName), dax.FieldName(i.columnName))
+		if errors.Is(err, dax.ErrFieldDoesNotExist) {
+			return nil, sql3.NewErrColumnNotFound(0, 0, i.columnName)
+		}
+	}
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return nil, sql3.NewErrTableNotFound(0, 0, i.tableName)
+		}
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"time"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// heapObjectsMetric is the runtime metric used to approximate the memory used
+// by an operator
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// operatorStats holds the statistics collected for a plan operator during
+// EXPLAIN ANALYZE
+type operatorStats struct {
+	// number of rows produced
+	rows int64
+	// number of iterators created
+	loops int64
+	// time spent in Next, including the time spent in the children
+	duration time.Duration
+	// largest growth in heap size seen between the creation of an iterator
+	// and the end of its rows. The heap is process wide, so this includes the
+	// allocations of the children and of anything else running at the same
+	// time, less what the garbage collector freed; it is only an approximation.
+	heapDelta uint64
+}
+
+func (s *operatorStats) String() string {
+	return fmt.Sprintf("rows=%d loops=%d time=%s approx_heap_delta=%dB", s.rows, s.loops, s.duration, s.heapDelta)
+}
+
+func (s *operatorStats) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["rows"] = s.rows
+	result["loops"] = s.loops
+	result["time"] = s.duration.String()
+	result["approxHeapDelta"] = s.heapDelta
+	return result
+}
+
+// PlanOpAnalyze plan operator wraps another operator to collect statistics on
+// its execution for EXPLAIN ANALYZE. It is otherwise transparent.
+type PlanOpAnalyze struct {
+	ChildOp  types.PlanOperator
+	stats    *operatorStats
+	warnings []string
+}
+
+func NewPlanOpAnalyze(child types.PlanOperator) *PlanOpAnalyze {
+	return &PlanOpAnalyze{
+		ChildOp:  child,
+		stats:    &operatorStats{},
+		warnings: make([]string, 0),
+	}
+}
+
+// instrumentPlan wraps every operator in the plan rooted at op in a
+// PlanOpAnalyze
+func instrumentPlan(op types.PlanOperator) (types.PlanOperator, error) {
+	result, _, err := TransformPlanOp(op, func(op types.PlanOperator) (types.PlanOperator, bool, error) {
+		return NewPlanOpAnalyze(op), false, nil
+	})
+	return result, err
+}
+
+func (p *PlanOpAnalyze) Plan() map[string]interface{} {
+	result := p.ChildOp.Plan()
+	result["_analyze"] = p.stats.Plan()
+	return result
+}
+
+func (p *PlanOpAnalyze) String() string {
+	return fmt.Sprintf("%s (%s)", p.ChildOp.String(), p.stats.String())
+}
+
+func (p *PlanOpAnalyze) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpAnalyze) Warnings() []string {
+	var w []string
+	w = append(w, p.warnings...)
+	w = append(w, p.ChildOp.Warnings()...)
+	return w
+}
+
+func (p *PlanOpAnalyze) Schema() types.Schema {
+	return p.ChildOp.Schema()
+}
+
+func (p *PlanOpAnalyze) Children() []types.PlanOperator {
+	return []types.PlanOperator{
+		p.ChildOp,
+	}
+}
+
+func (p *PlanOpAnalyze) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	iter, err := p.ChildOp.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	p.stats.loops++
+	i := &analyzeRowIter{
+		stats:   p.stats,
+		child:   iter,
+		samples: []metrics.Sample{{Name: heapObjectsMetric}},
+	}
+	i.heapStart = i.heapBytes()
+	return i, nil
+}
+
+func (p *PlanOpAnalyze) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 1 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpAnalyze(children[0])
+	op.stats = p.stats
+	return op, nil
+}
+
+type analyzeRowIter struct {
+	stats     *operatorStats
+	child     types.RowIterator
+	samples   []metrics.Sample
+	heapStart uint64
+	done      bool
+}
+
+var _ types.RowIterator = (*analyzeRowIter)(nil)
+
+func (i *analyzeRowIter) Next(ctx context.Context) (types.Row, error) {
+	start := time.Now()
+	row, err := i.child.Next(ctx)
+	i.stats.duration += time.Since(start)
+	if err == nil {
+		i.stats.rows++
+		return row, nil
+	}
+	// reading the heap is not free, so it is only sampled once more when the
+	// iterator runs out of rows
+	if !i.done {
+		i.done = true
+		if end := i.heapBytes(); end > i.heapStart && end-i.heapStart > i.stats.heapDelta {
+			i.stats.heapDelta = end - i.heapStart
+		}
+	}
+	return row, err
+}
+
+func (i *analyzeRowIter) lastRecordID() (uint64, bool) {
+	if records, ok := i.child.(recordIterator); ok {
+		return records.lastRecordID()
+	}
+	return 0, false
+}
+
+func (i *analyzeRowIter) heapBytes() uint64 {
+	metrics.Read(i.samples)
+	if i.samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return i.samples[0].Value.Uint64()
+}
@@ -0,0 +1,475 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// bitmapAggregateKind is the kind of aggregate computed by a
+// PlanOpBitmapAggregate
+type bitmapAggregateKind int
+
+const (
+	bitmapAggregateCount bitmapAggregateKind = iota
+	bitmapAggregateSum
+	bitmapAggregateMin
+	bitmapAggregateMax
+	bitmapAggregatePercentile
+)
+
+// bitmapAggregate is an aggregate that can be computed from the bitmaps of a
+// shard. field is nil for COUNT(*).
+type bitmapAggregate struct {
+	kind  bitmapAggregateKind
+	field *dax.Field
+
+	// the percentile asked for by PERCENTILE, scaled by percentileScale
+	nth int64
+}
+
+// newBitmapAggregate returns the bitmapAggregate for agg over the fields of a
+// table scan, or false if agg can't be computed from bitmaps
+func newBitmapAggregate(agg types.PlanExpression, fields []*dax.Field) (*bitmapAggregate, bool) {
+	var kind bitmapAggregateKind
+	var arg types.PlanExpression
+	var nth int64
+	switch a := agg.(type) {
+	case *countStarPlanExpression:
+		return &bitmapAggregate{kind: bitmapAggregateCount}, true
+	case *countPlanExpression:
+		kind, arg = bitmapAggregateCount, a.arg
+	case *sumPlanExpression:
+		kind, arg = bitmapAggregateSum, a.arg
+	case *minPlanExpression:
+		kind, arg = bitmapAggregateMin, a.arg
+	case *maxPlanExpression:
+		kind, arg = bitmapAggregateMax, a.arg
+	case *percentilePlanExpression:
+		var err error
+		if nth, err = a.nth(); err != nil {
+			// leave the error to the aggregation buffer
+			return nil, false
+		}
+		kind, arg = bitmapAggregatePercentile, a.arg
+	default:
+		return nil, false
+	}
+
+	b := &bitmapFilterBuilder{fields: fields}
+	fld, ok := b.column(arg)
+	if !ok {
+		return nil, false
+	}
+	switch kind {
+	case bitmapAggregateSum:
+		if fld.Type != dax.BaseTypeInt && fld.Type != dax.BaseTypeDecimal {
+			return nil, false
+		}
+	case bitmapAggregateMin, bitmapAggregateMax, bitmapAggregatePercentile:
+		if !api.IsBSIField(fld) {
+			return nil, false
+		}
+	}
+	return &bitmapAggregate{kind: kind, field: fld, nth: nth}, true
+}
+
+// bitmapAggregateState holds an aggregate as it is computed across shards
+type bitmapAggregateState struct {
+	// the count for COUNT, otherwise the number of values seen
+	count int64
+	value int64
+
+	// the values in each shard, for PERCENTILE
+	shards []*bsiValueSet
+}
+
+// update adds the values of the records in a shard to the state
+func (a *bitmapAggregate) update(tx api.Tx, records *roaring.Bitmap, state *bitmapAggregateState) error {
+	if a.field == nil {
+		state.count += int64(records.Count())
+		return nil
+	}
+	if a.kind == bitmapAggregateCount {
+		cols, err := notNullPredicate(a.field)(tx, 0, records)
+		if err != nil {
+			return err
+		}
+		state.count += int64(cols.Count())
+		return nil
+	}
+
+	bm, err := tx.RoaringBitmap(api.BitmapName(a.field.Name, api.FieldView(a.field)))
+	if err != nil {
+		return err
+	}
+	switch a.kind {
+	case bitmapAggregateSum:
+		sum, count, err := bsiSum(bm, records)
+		if err != nil {
+			return err
+		}
+		value, ok := addInt64(state.value, sum)
+		if !ok {
+			return sql3.NewErrOutputValueOutOfRange(0, 0)
+		}
+		state.value = value
+		state.count += count
+	case bitmapAggregateMin, bitmapAggregateMax:
+		max := a.kind == bitmapAggregateMax
+		v, ok := bsiMinMax(bm, records, max)
+		if !ok {
+			return nil
+		}
+		if state.count == 0 || (max && v > state.value) || (!max && v < state.value) {
+			state.value = v
+		}
+		state.count++
+	case bitmapAggregatePercentile:
+		set := newBSIValueSet(bm, records)
+		state.shards = append(state.shards, set)
+		state.count += int64(set.positive.Count() + set.negative.Count())
+	}
+	return nil
+}
+
+// result returns the value of the aggregate, which is null for a SUM, MIN or
+// MAX of no values
+func (a *bitmapAggregate) result(state *bitmapAggregateState) (interface{}, error) {
+	if a.kind == bitmapAggregateCount {
+		return state.count, nil
+	}
+	if state.count == 0 {
+		return nil, nil
+	}
+	if a.kind == bitmapAggregatePercentile {
+		state.value = bsiSelect(state.shards, percentileRank(a.nth, state.count))
+	}
+	switch a.field.Type {
+	case dax.BaseTypeDecimal:
+		return decimal.NewDecimal(state.value, a.field.Options.Scale), nil
+	case dax.BaseTypeTimestamp:
+		return api.ValToTimestampField(a.field, state.value)
+	default:
+		return state.value, nil
+	}
+}
+
+// PlanOpBitmapAggregate computes ungrouped aggregates over the records of a
+// table scan from the bitmaps of each shard, without reading any rows. It
+// replaces a PlanOpGroupBy with no group by expressions when the optimizer can
+// compute all its aggregates, and the filters of the scan, this way.
+type PlanOpBitmapAggregate struct {
+	planner    *ExecutionPlanner
+	scan       *PlanOpTableScan
+	Aggregates []types.PlanExpression
+	warnings   []string
+}
+
+func NewPlanOpBitmapAggregate(p *ExecutionPlanner, scan *PlanOpTableScan, aggregates []types.PlanExpression) *PlanOpBitmapAggregate {
+	return &PlanOpBitmapAggregate{
+		planner:    p,
+		scan:       scan,
+		Aggregates: aggregates,
+		warnings:   make([]string, 0),
+	}
+}
+
+// Schema for BitmapAggregate is the aggregate expressions, as for a
+// PlanOpGroupBy with no group by expressions
+func (p *PlanOpBitmapAggregate) Schema() types.Schema {
+	result := make(types.Schema, len(p.Aggregates))
+	for idx, agg := range p.Aggregates {
+		result[idx] = &types.PlannerColumn{
+			ColumnName:   agg.String(),
+			RelationName: "",
+			Type:         agg.Type(),
+		}
+	}
+	return result
+}
+
+func (p *PlanOpBitmapAggregate) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &bitmapAggregateIter{
+		op: p,
+	}, nil
+}
+
+func (p *PlanOpBitmapAggregate) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpBitmapAggregate) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 0 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpBitmapAggregate(p.planner, p.scan, p.Aggregates)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+func (p *PlanOpBitmapAggregate) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["scan"] = p.scan.Plan()
+	ps := make([]interface{}, 0)
+	for _, e := range p.Aggregates {
+		ps = append(ps, e.Plan())
+	}
+	result["aggregates"] = ps
+	return result
+}
+
+func (p *PlanOpBitmapAggregate) String() string {
+	return fmt.Sprintf("BitmapAggregate(%s; %s)", expressionListString(p.Aggregates), p.scan.String())
+}
+
+func (p *PlanOpBitmapAggregate) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpBitmapAggregate) Warnings() []string {
+	var w []string
+	w = append(w, p.warnings...)
+	w = append(w, p.scan.Warnings()...)
+	return w
+}
+
+type bitmapAggregateIter struct {
+	op   *PlanOpBitmapAggregate
+	done bool
+}
+
+var _ types.RowIterator = (*bitmapAggregateIter)(nil)
+
+func (i *bitmapAggregateIter) Next(ctx context.Context) (types.Row, error) {
+	if i.done {
+		return nil, types.ErrNoMoreRows
+	}
+	i.done = true
+
+	scan := i.op.scan
+	tbl := scan.table
+	fields, err := scan.fields(tbl)
+	if err != nil {
+		return nil, err
+	}
+	predicates, filter, err := scan.bitmapFilters(ctx, tbl, fields)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		return nil, sql3.NewErrInternalf("unexpected filter '%s'", filter.String())
+	}
+	aggregates := make([]*bitmapAggregate, len(i.op.Aggregates))
+	for j, expr := range i.op.Aggregates {
+		agg, ok := newBitmapAggregate(expr, fields)
+		if !ok {
+			return nil, sql3.NewErrInternalf("unexpected aggregate '%s'", expr.String())
+		}
+		aggregates[j] = agg
+	}
+	shards, err := i.op.planner.executor.Shards(ctx, tbl.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*bitmapAggregateState, len(aggregates))
+	for j := range states {
+		states[j] = &bitmapAggregateState{}
+	}
+	for _, shard := range shards {
+		err := i.op.planner.executor.View(ctx, tbl.ID, shard, func(tx api.Tx) error {
+			records, err := shardRecords(tx, shard, predicates)
+			if err != nil {
+				return err
+			}
+			if !records.Any() {
+				return nil
+			}
+			for j, agg := range aggregates {
+				if err := agg.update(tx, records, states[j]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	row := make(types.Row, len(aggregates))
+	for j, agg := range aggregates {
+		row[j], err = agg.result(states[j])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// bsiSum returns the sum of the values of a bit-sliced bitmap for the columns
+// in set, and the number of those columns that have a value. It returns an
+// error if the sum doesn't fit in an int64.
+func bsiSum(bm *roaring.Bitmap, set *roaring.Bitmap) (int64, int64, error) {
+	exists := api.Row(bm, api.BSIExistsBit).Intersect(set)
+	sign := api.Row(bm, api.BSISignBit)
+	positive := exists.Difference(sign)
+	negative := exists.Intersect(sign)
+
+	var sum, n big.Int
+	for i, slice := range bsiSlices(bm) {
+		n.SetUint64(slice.IntersectionCount(positive))
+		sum.Add(&sum, n.Lsh(&n, uint(i)))
+		n.SetUint64(slice.IntersectionCount(negative))
+		sum.Sub(&sum, n.Lsh(&n, uint(i)))
+	}
+	if !sum.IsInt64() {
+		return 0, 0, sql3.NewErrOutputValueOutOfRange(0, 0)
+	}
+	return sum.Int64(), int64(exists.Count()), nil
+}
+
+// bsiMinMax returns the smallest value, or the largest if max is true, of a
+// bit-sliced bitmap for the columns in set. It returns false if none of the
+// columns have a value.
+func bsiMinMax(bm *roaring.Bitmap, set *roaring.Bitmap, max bool) (int64, bool) {
+	exists := api.Row(bm, api.BSIExistsBit).Intersect(set)
+	if !exists.Any() {
+		return 0, false
+	}
+	sign := api.Row(bm, api.BSISignBit)
+	positive := exists.Difference(sign)
+	negative := exists.Intersect(sign)
+	slices := bsiSlices(bm)
+
+	// the largest value is the largest positive magnitude if there are positive
+	// values, otherwise the smallest negative magnitude, and the reverse for
+	// the smallest value
+	if max {
+		if positive.Any() {
+			return int64(bsiExtremeMagnitude(positive, slices, true)), true
+		}
+		return -int64(bsiExtremeMagnitude(negative, slices, false)), true
+	}
+	if negative.Any() {
+		return -int64(bsiExtremeMagnitude(negative, slices, true)), true
+	}
+	return int64(bsiExtremeMagnitude(positive, slices, false)), true
+}
+
+// bsiExtremeMagnitude returns the largest magnitude, or the smallest if largest
+// is false, held in slices for the columns in set, which must not be empty
+func bsiExtremeMagnitude(set *roaring.Bitmap, slices []*roaring.Bitmap, largest bool) uint64 {
+	candidates := set
+	var m uint64
+	for i := len(slices) - 1; i >= 0; i-- {
+		var next *roaring.Bitmap
+		if largest {
+			next = candidates.Intersect(slices[i])
+		} else {
+			next = candidates.Difference(slices[i])
+		}
+		if next.Any() {
+			candidates = next
+		}
+		// the bit is set if the largest candidates have it, or the smallest
+		// candidates can't avoid it
+		if next.Any() == largest {
+			m |= 1 << uint(i)
+		}
+	}
+	return m
+}
+
+// bsiValueSet holds the values of a bit-sliced bitmap for a set of columns
+type bsiValueSet struct {
+	positive *roaring.Bitmap
+	negative *roaring.Bitmap
+	slices   []*roaring.Bitmap
+}
+
+func newBSIValueSet(bm *roaring.Bitmap, set *roaring.Bitmap) *bsiValueSet {
+	exists := api.Row(bm, api.BSIExistsBit).Intersect(set)
+	sign := api.Row(bm, api.BSISignBit)
+	return &bsiValueSet{
+		positive: exists.Difference(sign),
+		negative: exists.Intersect(sign),
+		slices:   bsiSlices(bm),
+	}
+}
+
+// bsiSelect returns the value with the given rank, counting from 1 in
+// ascending order, among the values of sets, which must have at least rank
+// values. It searches the bit slices from the most significant down, keeping
+// the columns that can still hold the value, so it never decodes any values.
+func bsiSelect(sets []*bsiValueSet, rank int64) int64 {
+	var negatives int64
+	for _, set := range sets {
+		negatives += int64(set.negative.Count())
+	}
+	if rank <= negatives {
+		// the value is negative, so it has the rank counted from the
+		// largest magnitude down
+		candidates := make([]*roaring.Bitmap, len(sets))
+		for i, set := range sets {
+			candidates[i] = set.negative
+		}
+		return -int64(bsiSelectMagnitude(sets, candidates, negatives-rank+1))
+	}
+	candidates := make([]*roaring.Bitmap, len(sets))
+	for i, set := range sets {
+		candidates[i] = set.positive
+	}
+	return int64(bsiSelectMagnitude(sets, candidates, rank-negatives))
+}
+
+// bsiSelectMagnitude returns the magnitude with the given rank, counting from
+// 1 in ascending order, among the candidate columns of each set
+func bsiSelectMagnitude(sets []*bsiValueSet, candidates []*roaring.Bitmap, rank int64) uint64 {
+	depth := 0
+	for _, set := range sets {
+		if len(set.slices) > depth {
+			depth = len(set.slices)
+		}
+	}
+	var m uint64
+	for bit := depth - 1; bit >= 0; bit-- {
+		// split the candidates into those with the bit clear and those with
+		// it set; the value is among the first if there are enough of them
+		zeros := make([]*roaring.Bitmap, len(sets))
+		var count int64
+		for i, set := range sets {
+			zeros[i] = candidates[i]
+			if bit < len(set.slices) {
+				zeros[i] = candidates[i].Difference(set.slices[bit])
+			}
+			count += int64(zeros[i].Count())
+		}
+		if rank <= count {
+			candidates = zeros
+			continue
+		}
+		rank -= count
+		m |= 1 << uint(bit)
+		for i, set := range sets {
+			if bit < len(set.slices) {
+				candidates[i] = candidates[i].Intersect(set.slices[bit])
+			} else {
+				candidates[i] = roaring.NewBitmap()
+			}
+		}
+	}
+	return m
+}
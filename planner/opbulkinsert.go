@@ -0,0 +1,517 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpBulkInsert plan operator to handle BULK INSERT and BULK REPLACE.
+type PlanOpBulkInsert struct {
+	planner       *ExecutionPlanner
+	tableName     string
+	targetColumns []*qualifiedRefPlanExpression
+	options       *bulkInsertOptions
+	replace       bool
+	warnings      []string
+}
+
+func NewPlanOpBulkInsert(p *ExecutionPlanner, tableName string, targetColumns []*qualifiedRefPlanExpression, options *bulkInsertOptions, replace bool) *PlanOpBulkInsert {
+	return &PlanOpBulkInsert{
+		planner:       p,
+		tableName:     tableName,
+		targetColumns: targetColumns,
+		options:       options,
+		replace:       replace,
+		warnings:      make([]string, 0),
+	}
+}
+
+func (p *PlanOpBulkInsert) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	result["replace"] = p.replace
+	result["format"] = p.options.format
+	result["input"] = p.options.input
+	result["batchSize"] = p.options.batchSize
+	result["rowsLimit"] = p.options.rowsLimit
+	result["hasHeaderRow"] = p.options.hasHeaderRow
+	result["allowMissingValues"] = p.options.allowMissingValues
+	if p.options.input == "FILE" {
+		result["sourceData"] = p.options.sourceData
+	}
+	ps := make([]interface{}, 0)
+	for _, e := range p.targetColumns {
+		ps = append(ps, e.Plan())
+	}
+	result["targetColumns"] = ps
+	ps = make([]interface{}, 0)
+	for _, m := range p.options.mapExpressions {
+		mp := make(map[string]interface{})
+		if m.path != nil {
+			mp["path"] = m.path.String()
+		} else {
+			mp["position"] = m.position
+		}
+		mp["dataType"] = m.dataType.TypeDescription()
+		ps = append(ps, mp)
+	}
+	result["mapExpressions"] = ps
+	ps = make([]interface{}, 0)
+	for _, e := range p.options.transformExpressions {
+		ps = append(ps, e.Plan())
+	}
+	result["transformExpressions"] = ps
+	return result
+}
+
+func (p *PlanOpBulkInsert) String() string {
+	op := "BulkInsert"
+	if p.replace {
+		op = "BulkReplace"
+	}
+	return fmt.Sprintf("%s(%s; columns %s; format %s; input %s)", op, p.tableName, qualifiedRefListString(p.targetColumns), p.options.format, p.options.input)
+}
+
+func (p *PlanOpBulkInsert) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpBulkInsert) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpBulkInsert) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpBulkInsert) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpBulkInsert) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &bulkInsertRowIter{
+		planner:       p.planner,
+		tableName:     p.tableName,
+		targetColumns: p.targetColumns,
+		options:       p.options,
+		replace:       p.replace,
+	}, nil
+}
+
+func (p *PlanOpBulkInsert) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return NewPlanOpBulkInsert(p.planner, p.tableName, p.targetColumns, p.options, p.replace), nil
+}
+
+type bulkInsertRowIter struct {
+	planner       *ExecutionPlanner
+	tableName     string
+	targetColumns []*qualifiedRefPlanExpression
+	options       *bulkInsertOptions
+	replace       bool
+	done          bool
+}
+
+var _ types.RowIterator = (*bulkInsertRowIter)(nil)
+
+func (i *bulkInsertRowIter) Next(ctx context.Context) (types.Row, error) {
+	if i.done {
+		return nil, types.ErrNoMoreRows
+	}
+	// the insert happens on the first call; there are never any rows
+	i.done = true
+
+	tbl, err := i.planner.schemaAPI.TableByName(ctx, dax.TableName(i.tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return nil, sql3.NewErrTableNotFound(0, 0, i.tableName)
+		}
+		return nil, err
+	}
+	target, err := newInsertTarget(tbl, i.targetColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := newBulkInsertSource(i.options)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	// the types of the values read from the source, or produced by the
+	// transforms
+	rowTypes := make([]parser.ExprDataType, 0)
+	if len(i.options.transformExpressions) > 0 {
+		for _, t := range i.options.transformExpressions {
+			rowTypes = append(rowTypes, t.Type())
+		}
+	} else {
+		for _, m := range i.options.mapExpressions {
+			rowTypes = append(rowTypes, m.dataType)
+		}
+	}
+
+	batch := newImportBatch(i.planner, tbl, target.fields, i.replace)
+	rowNumber := 0
+	for i.options.rowsLimit == 0 || rowNumber < i.options.rowsLimit {
+		mapped, err := source.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		rowNumber++
+
+		row := mapped
+		if len(i.options.transformExpressions) > 0 {
+			row = make([]interface{}, len(i.options.transformExpressions))
+			for j, t := range i.options.transformExpressions {
+				row[j], err = t.Evaluate(mapped)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		id, values, err := target.record(row, rowTypes, rowNumber)
+		if err != nil {
+			return nil, err
+		}
+		batch.add(id, values)
+
+		if batch.len() >= i.options.batchSize {
+			if err := batch.flush(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
+
+// bulkInsertSource reads records from the data source of a bulk insert
+type bulkInsertSource interface {
+	// Next returns the mapped values of the next record, or io.EOF if there
+	// are no more records.
+	Next() ([]interface{}, error)
+	Close() error
+}
+
+func newBulkInsertSource(options *bulkInsertOptions) (bulkInsertSource, error) {
+	var r io.ReadCloser
+	switch options.input {
+	case "FILE":
+		f, err := os.Open(options.sourceData)
+		if err != nil {
+			return nil, sql3.NewErrReadingDatasource(0, 0, options.sourceName(), err.Error())
+		}
+		r = f
+	case "STREAM":
+		r = io.NopCloser(strings.NewReader(options.sourceData))
+	default:
+		return nil, sql3.NewErrInvalidInputSpecifier(0, 0, options.input)
+	}
+
+	switch options.format {
+	case "CSV":
+		return newBulkInsertCSVSource(r, options)
+	case "NDJSON":
+		return newBulkInsertNDJSONSource(r, options), nil
+	default:
+		r.Close()
+		return nil, sql3.NewErrInvalidFormatSpecifier(0, 0, options.format)
+	}
+}
+
+// sourceName returns the data source name used in errors
+func (o *bulkInsertOptions) sourceName() string {
+	if o.input == "STREAM" {
+		return "stream"
+	}
+	return o.sourceData
+}
+
+// bulkInsertCSVSource reads records from CSV data
+type bulkInsertCSVSource struct {
+	r       io.ReadCloser
+	reader  *csv.Reader
+	options *bulkInsertOptions
+}
+
+func newBulkInsertCSVSource(r io.ReadCloser, options *bulkInsertOptions) (*bulkInsertCSVSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+
+	s := &bulkInsertCSVSource{
+		r:       r,
+		reader:  reader,
+		options: options,
+	}
+	if options.hasHeaderRow {
+		if _, err := reader.Read(); err != nil && err != io.EOF {
+			r.Close()
+			return nil, sql3.NewErrReadingDatasource(0, 0, options.sourceName(), err.Error())
+		}
+	}
+	return s, nil
+}
+
+func (s *bulkInsertCSVSource) Next() ([]interface{}, error) {
+	rec, err := s.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, sql3.NewErrReadingDatasource(0, 0, s.options.sourceName(), err.Error())
+	}
+
+	result := make([]interface{}, len(s.options.mapExpressions))
+	for i, m := range s.options.mapExpressions {
+		if m.position >= len(rec) {
+			if s.options.allowMissingValues {
+				continue
+			}
+			line, _ := s.reader.FieldPos(0)
+			return nil, sql3.NewErrMappingFromDatasource(0, 0, s.options.sourceName(), fmt.Sprintf("map index %d out of range on line %d", m.position, line))
+		}
+		result[i], err = mapStringValue(rec[m.position], m.dataType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *bulkInsertCSVSource) Close() error {
+	return s.r.Close()
+}
+
+// bulkInsertNDJSONSource reads records from newline delimited JSON data
+type bulkInsertNDJSONSource struct {
+	r       io.ReadCloser
+	reader  *bufio.Reader
+	options *bulkInsertOptions
+}
+
+func newBulkInsertNDJSONSource(r io.ReadCloser, options *bulkInsertOptions) *bulkInsertNDJSONSource {
+	return &bulkInsertNDJSONSource{
+		r:       r,
+		reader:  bufio.NewReader(r),
+		options: options,
+	}
+}
+
+func (s *bulkInsertNDJSONSource) Next() ([]interface{}, error) {
+	// skip blank lines
+	var line []byte
+	for len(line) == 0 {
+		b, err := s.reader.ReadBytes('\n')
+		if err == io.EOF && len(b) == 0 {
+			return nil, io.EOF
+		} else if err != nil && err != io.EOF {
+			return nil, sql3.NewErrReadingDatasource(0, 0, s.options.sourceName(), err.Error())
+		}
+		line = bytes.TrimSpace(b)
+	}
+
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, sql3.NewErrParsingJSON(0, 0, string(line), err.Error())
+	}
+
+	result := make([]interface{}, len(s.options.mapExpressions))
+	for i, m := range s.options.mapExpressions {
+		v, err := m.path.evaluate(doc)
+		if err != nil {
+			if s.options.allowMissingValues {
+				continue
+			}
+			return nil, sql3.NewErrEvaluatingJSONPathExpr(0, 0, m.path.String(), string(line), err.Error())
+		}
+		result[i], err = mapJSONValue(v, m.dataType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *bulkInsertNDJSONSource) Close() error {
+	return s.r.Close()
+}
+
+// mapJSONValue converts a value decoded from JSON (with numbers decoded as
+// json.Number) to dataType. Arrays are converted to sets, and a single value
+// is converted to a set with one member.
+func mapJSONValue(value interface{}, dataType parser.ExprDataType) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+
+	case string:
+		switch dataType.(type) {
+		case *parser.DataTypeString:
+			return v, nil
+		case *parser.DataTypeStringSet:
+			return []string{v}, nil
+		}
+		return mapStringValue(v, dataType)
+
+	case json.Number:
+		switch dataType.(type) {
+		case *parser.DataTypeString:
+			return v.String(), nil
+		case *parser.DataTypeIDSet:
+			m, err := v.Int64()
+			if err != nil {
+				return nil, sql3.NewErrTypeConversionOnMap(0, 0, v, dataType.TypeDescription())
+			}
+			return []int64{m}, nil
+		case *parser.DataTypeID, *parser.DataTypeInt, *parser.DataTypeDecimal, *parser.DataTypeTimestamp:
+			return mapStringValue(v.String(), dataType)
+		}
+
+	case bool:
+		switch dataType.(type) {
+		case *parser.DataTypeBool:
+			return v, nil
+		}
+
+	case []interface{}:
+		switch dataType.(type) {
+		case *parser.DataTypeIDSet:
+			result := make([]int64, 0, len(v))
+			for _, m := range v {
+				n, ok := m.(json.Number)
+				if !ok {
+					return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+				}
+				i, err := n.Int64()
+				if err != nil {
+					return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+				}
+				result = append(result, i)
+			}
+			return result, nil
+
+		case *parser.DataTypeStringSet:
+			result := make([]string, 0, len(v))
+			for _, m := range v {
+				switch sm := m.(type) {
+				case string:
+					result = append(result, sm)
+				case json.Number:
+					result = append(result, sm.String())
+				default:
+					return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+				}
+			}
+			return result, nil
+		}
+	}
+	return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+}
+
+// mapStringValue converts a string read from a data source to dataType. An
+// empty string is a null.
+func mapStringValue(value string, dataType parser.ExprDataType) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	switch t := dataType.(type) {
+	case *parser.DataTypeID, *parser.DataTypeInt:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+		}
+		return v, nil
+
+	case *parser.DataTypeBool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+		}
+		return v, nil
+
+	case *parser.DataTypeDecimal:
+		v, err := decimal.ParseDecimal(value)
+		if err != nil || !v.SupportedByScale(t.Scale) {
+			return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+		}
+		return decimal.NewDecimal(v.ToInt64(t.Scale), t.Scale), nil
+
+	case *parser.DataTypeString:
+		return value, nil
+
+	case *parser.DataTypeTimestamp:
+		if tm, err := time.ParseInLocation(time.RFC3339Nano, value, time.UTC); err == nil {
+			return tm, nil
+		} else if tm, err := time.ParseInLocation("2006-01-02", value, time.UTC); err == nil {
+			return tm, nil
+		} else if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(v, 0).UTC(), nil
+		}
+		return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+
+	case *parser.DataTypeIDSet:
+		members := splitSetValue(value)
+		result := make([]int64, len(members))
+		for i, m := range members {
+			v, err := strconv.ParseInt(m, 10, 64)
+			if err != nil {
+				return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+			}
+			result[i] = v
+		}
+		return result, nil
+
+	case *parser.DataTypeStringSet:
+		members := splitSetValue(value)
+		for i, m := range members {
+			members[i] = strings.Trim(m, `'"`)
+		}
+		return members, nil
+
+	default:
+		return nil, sql3.NewErrTypeConversionOnMap(0, 0, value, dataType.TypeDescription())
+	}
+}
+
+// splitSetValue splits the members of a set value, which are separated by
+// commas and optionally enclosed in brackets
+func splitSetValue(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if strings.TrimSpace(value) == "" {
+		return []string{}
+	}
+	members := strings.Split(value, ",")
+	for i, m := range members {
+		members[i] = strings.TrimSpace(m)
+	}
+	return members
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpCreateDatabase plan operator to create a database.
+type PlanOpCreateDatabase struct {
+	planner      *ExecutionPlanner
+	db           *dax.Database
+	failIfExists bool
+	warnings     []string
+}
+
+func NewPlanOpCreateDatabase(p *ExecutionPlanner, db *dax.Database, failIfExists bool) *PlanOpCreateDatabase {
+	return &PlanOpCreateDatabase{
+		planner:      p,
+		db:           db,
+		failIfExists: failIfExists,
+		warnings:     make([]string, 0),
+	}
+}
+
+func (p *PlanOpCreateDatabase) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["databaseName"] = string(p.db.Name)
+	result["failIfExists"] = p.failIfExists
+	result["units"] = p.db.Options.WorkersMin
+	result["description"] = p.db.Description
+	return result
+}
+
+func (p *PlanOpCreateDatabase) String() string {
+	return fmt.Sprintf("CreateDatabase(%s)", p.db.Name)
+}
+
+func (p *PlanOpCreateDatabase) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpCreateDatabase) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpCreateDatabase) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpCreateDatabase) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpCreateDatabase) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &createDatabaseRowIter{
+		planner:      p.planner,
+		db:           p.db,
+		failIfExists: p.failIfExists,
+	}, nil
+}
+
+func (p *PlanOpCreateDatabase) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+type createDatabaseRowIter struct {
+	planner      *ExecutionPlanner
+	db           *dax.Database
+	failIfExists bool
+}
+
+var _ types.RowIterator = (*createDatabaseRowIter)(nil)
+
+func (i *createDatabaseRowIter) Next(ctx context.Context) (types.Row, error) {
+	// a copy is created so the plan can be executed more than once
+	db := *i.db
+	err := i.planner.schemaAPI.CreateDatabase(ctx, &db)
+	if err != nil {
+		if isDatabaseExistsError(err) {
+			if !i.failIfExists {
+				return nil, types.ErrNoMoreRows
+			}
+			return nil, sql3.NewErrDatabaseExists(0, 0, string(db.Name))
+		}
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
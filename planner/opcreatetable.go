@@ -0,0 +1,107 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpCreateTable plan operator to create a table.
+type PlanOpCreateTable struct {
+	planner      *ExecutionPlanner
+	table        *dax.Table
+	failIfExists bool
+	warnings     []string
+}
+
+func NewPlanOpCreateTable(p *ExecutionPlanner, table *dax.Table, failIfExists bool) *PlanOpCreateTable {
+	return &PlanOpCreateTable{
+		planner:      p,
+		table:        table,
+		failIfExists: failIfExists,
+		warnings:     make([]string, 0),
+	}
+}
+
+func (p *PlanOpCreateTable) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = string(p.table.Name)
+	result["failIfExists"] = p.failIfExists
+	result["keyPartitions"] = p.table.PartitionN
+	result["description"] = p.table.Description
+	ps := make([]interface{}, 0)
+	for _, fld := range p.table.Fields {
+		ps = append(ps, fld.CreateSQL())
+	}
+	result["columns"] = ps
+	return result
+}
+
+func (p *PlanOpCreateTable) String() string {
+	return fmt.Sprintf("CreateTable(%s)", p.table.Name)
+}
+
+func (p *PlanOpCreateTable) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpCreateTable) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpCreateTable) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpCreateTable) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpCreateTable) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &createTableRowIter{
+		planner:      p.planner,
+		table:        p.table,
+		failIfExists: p.failIfExists,
+	}, nil
+}
+
+func (p *PlanOpCreateTable) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+type createTableRowIter struct {
+	planner      *ExecutionPlanner
+	table        *dax.Table
+	failIfExists bool
+}
+
+var _ types.RowIterator = (*createTableRowIter)(nil)
+
+func (i *createTableRowIter) Next(ctx context.Context) (types.Row, error) {
+	// a copy is created so the plan can be executed more than once
+	tbl := *i.table
+	tbl.Fields = make([]*dax.Field, len(i.table.Fields))
+	for j, fld := range i.table.Fields {
+		f := *fld
+		tbl.Fields[j] = &f
+	}
+
+	err := i.planner.schemaAPI.CreateTable(ctx, &tbl)
+	if err != nil {
+		if isTableExistsError(err) {
+			if !i.failIfExists {
+				return nil, types.ErrNoMoreRows
+			}
+			return nil, sql3.NewErrTableExists(0, 0, string(tbl.Name))
+		}
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
@@ -0,0 +1,413 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// cteBinding holds the rows of a common table expression while a query runs.
+// The rows are read from the operator of the expression by the first scan of
+// them, and every other scan reads the same rows.
+type cteBinding struct {
+	name   string
+	schema types.Schema
+
+	// set while the recursive part of the expression is compiled, so that
+	// references to it read the rows of the previous step
+	recursing bool
+
+	// execution state, reset by PlanOpWith for each run of the query
+	op           types.PlanOperator
+	rows         []types.Row
+	materialized bool
+}
+
+// cteWorkingKey is the context key of the rows of the previous step of a
+// recursive common table expression
+type cteWorkingKey struct {
+	binding *cteBinding
+}
+
+// materialize reads all the rows of the expression, if that hasn't been done
+func (b *cteBinding) materialize(ctx context.Context) error {
+	if b.materialized {
+		return nil
+	}
+	iter, err := b.op.Iterator(ctx, nil)
+	if err != nil {
+		return err
+	}
+	rows := make([]types.Row, 0)
+	for {
+		row, err := iter.Next(ctx)
+		if err == types.ErrNoMoreRows {
+			break
+		} else if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	b.rows = rows
+	b.materialized = true
+	return nil
+}
+
+// PlanOpWith plan operator handles a WITH clause. Its children are the
+// operators of the common table expressions followed by the operator of the
+// query that uses them.
+type PlanOpWith struct {
+	bindings []*cteBinding
+	ctes     []types.PlanOperator
+	ChildOp  types.PlanOperator
+	warnings []string
+}
+
+func NewPlanOpWith(bindings []*cteBinding, ctes []types.PlanOperator, child types.PlanOperator) *PlanOpWith {
+	return &PlanOpWith{
+		bindings: bindings,
+		ctes:     ctes,
+		ChildOp:  child,
+		warnings: make([]string, 0),
+	}
+}
+
+func (p *PlanOpWith) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	ctes := make(map[string]interface{})
+	for i, b := range p.bindings {
+		ctes[b.name] = p.ctes[i].Plan()
+	}
+	result["ctes"] = ctes
+	result["child"] = p.ChildOp.Plan()
+	return result
+}
+
+func (p *PlanOpWith) String() string {
+	names := make([]string, len(p.bindings))
+	for i, b := range p.bindings {
+		names[i] = b.name
+	}
+	return fmt.Sprintf("With(%s)", strings.Join(names, ", "))
+}
+
+func (p *PlanOpWith) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpWith) Warnings() []string {
+	var w []string
+	w = append(w, p.warnings...)
+	w = append(w, p.ChildOp.Warnings()...)
+	return w
+}
+
+func (p *PlanOpWith) Schema() types.Schema {
+	return p.ChildOp.Schema()
+}
+
+func (p *PlanOpWith) Children() []types.PlanOperator {
+	children := make([]types.PlanOperator, 0, len(p.ctes)+1)
+	children = append(children, p.ctes...)
+	return append(children, p.ChildOp)
+}
+
+func (p *PlanOpWith) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	for i, b := range p.bindings {
+		b.op = p.ctes[i]
+		b.rows = nil
+		b.materialized = false
+	}
+	return p.ChildOp.Iterator(ctx, row)
+}
+
+func (p *PlanOpWith) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != len(p.ctes)+1 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	ctes := make([]types.PlanOperator, len(p.ctes))
+	copy(ctes, children)
+	op := NewPlanOpWith(p.bindings, ctes, children[len(children)-1])
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+// PlanOpCTEScan plan operator returns the rows of a common table expression.
+// A recursive scan is in the recursive part of the expression, and returns the
+// rows produced by the previous step of the recursion.
+type PlanOpCTEScan struct {
+	binding   *cteBinding
+	recursive bool
+	warnings  []string
+}
+
+func NewPlanOpCTEScan(binding *cteBinding, recursive bool) *PlanOpCTEScan {
+	return &PlanOpCTEScan{
+		binding:   binding,
+		recursive: recursive,
+		warnings:  make([]string, 0),
+	}
+}
+
+func (p *PlanOpCTEScan) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["name"] = p.binding.name
+	result["recursive"] = p.recursive
+	return result
+}
+
+func (p *PlanOpCTEScan) String() string {
+	if p.recursive {
+		return fmt.Sprintf("CTEScan(%s; recursive)", p.binding.name)
+	}
+	return fmt.Sprintf("CTEScan(%s)", p.binding.name)
+}
+
+func (p *PlanOpCTEScan) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpCTEScan) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpCTEScan) Schema() types.Schema {
+	// the columns are copied, since aliases change them
+	result := make(types.Schema, len(p.binding.schema))
+	for i, col := range p.binding.schema {
+		c := *col
+		result[i] = &c
+	}
+	return result
+}
+
+func (p *PlanOpCTEScan) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpCTEScan) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	if p.recursive {
+		rows, _ := ctx.Value(cteWorkingKey{p.binding}).([]types.Row)
+		return &cteScanIter{rows: rows}, nil
+	}
+	return &cteScanIter{binding: p.binding}, nil
+}
+
+func (p *PlanOpCTEScan) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 0 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpCTEScan(p.binding, p.recursive)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+type cteScanIter struct {
+	// the binding to read the rows of, or nil if the rows are already known
+	binding *cteBinding
+	rows    []types.Row
+	next    int
+}
+
+var _ types.RowIterator = (*cteScanIter)(nil)
+
+func (i *cteScanIter) Next(ctx context.Context) (types.Row, error) {
+	if i.binding != nil {
+		if err := i.binding.materialize(ctx); err != nil {
+			return nil, err
+		}
+		i.rows = i.binding.rows
+		i.binding = nil
+	}
+	if i.next >= len(i.rows) {
+		return nil, types.ErrNoMoreRows
+	}
+	row := i.rows[i.next]
+	i.next++
+	return row, nil
+}
+
+// PlanOpRecursiveCTE plan operator computes a recursive common table
+// expression. It returns the rows of the anchor, then runs the recursive part
+// on the rows returned by the previous step until a step returns no rows.
+// Unless it is a UNION ALL, rows that have already been returned are dropped,
+// so that the recursion ends when no new rows are found. The recursion fails
+// if it runs more than maxRecursiveCTESteps steps or returns more than
+// maxRecursiveCTERows rows.
+type PlanOpRecursiveCTE struct {
+	binding   *cteBinding
+	anchor    types.PlanOperator
+	recursive types.PlanOperator
+	all       bool
+	line, col int
+	warnings  []string
+}
+
+const (
+	maxRecursiveCTESteps = 10000
+	maxRecursiveCTERows  = 10000000
+)
+
+func NewPlanOpRecursiveCTE(binding *cteBinding, anchor, recursive types.PlanOperator, all bool, line, col int) *PlanOpRecursiveCTE {
+	return &PlanOpRecursiveCTE{
+		binding:   binding,
+		anchor:    anchor,
+		recursive: recursive,
+		all:       all,
+		line:      line,
+		col:       col,
+		warnings:  make([]string, 0),
+	}
+}
+
+func (p *PlanOpRecursiveCTE) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["name"] = p.binding.name
+	result["all"] = p.all
+	result["anchor"] = p.anchor.Plan()
+	result["recursive"] = p.recursive.Plan()
+	return result
+}
+
+func (p *PlanOpRecursiveCTE) String() string {
+	if p.all {
+		return fmt.Sprintf("RecursiveCTE(%s; union all)", p.binding.name)
+	}
+	return fmt.Sprintf("RecursiveCTE(%s; union)", p.binding.name)
+}
+
+func (p *PlanOpRecursiveCTE) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpRecursiveCTE) Warnings() []string {
+	var w []string
+	w = append(w, p.warnings...)
+	w = append(w, p.anchor.Warnings()...)
+	w = append(w, p.recursive.Warnings()...)
+	return w
+}
+
+func (p *PlanOpRecursiveCTE) Schema() types.Schema {
+	return p.binding.schema
+}
+
+func (p *PlanOpRecursiveCTE) Children() []types.PlanOperator {
+	return []types.PlanOperator{
+		p.anchor,
+		p.recursive,
+	}
+}
+
+func (p *PlanOpRecursiveCTE) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	anchorIter, err := p.anchor.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	return &recursiveCTEIter{
+		op:      p,
+		row:     row,
+		current: anchorIter,
+		schema:  p.anchor.Schema(),
+		seen:    make(map[string]struct{}),
+	}, nil
+}
+
+func (p *PlanOpRecursiveCTE) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 2 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpRecursiveCTE(p.binding, children[0], children[1], p.all, p.line, p.col)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+type recursiveCTEIter struct {
+	op *PlanOpRecursiveCTE
+	// the outer row the iterator was created with
+	row types.Row
+
+	// the iterator of the current step, and the schema of its rows
+	current types.RowIterator
+	schema  types.Schema
+
+	// the rows returned by the previous step, read by the recursive part
+	// through the context of the current step
+	working []types.Row
+	// the rows returned by the current step
+	stepRows []types.Row
+	// the rows already returned, unless it is a UNION ALL
+	seen map[string]struct{}
+
+	steps int
+	rows  int
+}
+
+var _ types.RowIterator = (*recursiveCTEIter)(nil)
+
+func (i *recursiveCTEIter) Next(ctx context.Context) (types.Row, error) {
+	for {
+		row, err := i.current.Next(i.stepContext(ctx))
+		if err == types.ErrNoMoreRows {
+			// the recursion ends when a step returns no rows
+			if len(i.stepRows) == 0 {
+				return nil, types.ErrNoMoreRows
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			i.steps++
+			if i.steps > maxRecursiveCTESteps {
+				return nil, sql3.NewErrRecursiveCTELimitExceeded(i.op.line, i.op.col, i.op.binding.name, fmt.Sprintf("%d steps", maxRecursiveCTESteps))
+			}
+			i.working = i.stepRows
+			i.stepRows = nil
+			i.current, err = i.op.recursive.Iterator(i.stepContext(ctx), i.row)
+			if err != nil {
+				return nil, err
+			}
+			i.schema = i.op.recursive.Schema()
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		row, err = coerceRow(row, i.schema, i.op.binding.schema)
+		if err != nil {
+			return nil, err
+		}
+		if !i.op.all {
+			key := string(generateRowKey(row))
+			if _, ok := i.seen[key]; ok {
+				continue
+			}
+			i.seen[key] = struct{}{}
+		}
+		i.rows++
+		if i.rows > maxRecursiveCTERows {
+			return nil, sql3.NewErrRecursiveCTELimitExceeded(i.op.line, i.op.col, i.op.binding.name, fmt.Sprintf("%d rows", maxRecursiveCTERows))
+		}
+		i.stepRows = append(i.stepRows, row)
+		return row, nil
+	}
+}
+
+// stepContext returns the context the current step runs with, which holds
+// the rows of the previous step for the recursive scans of the expression
+func (i *recursiveCTEIter) stepContext(ctx context.Context) context.Context {
+	if i.working == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, cteWorkingKey{i.op.binding}, i.working)
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpDelete plan operator to delete the records returned by its child from
+// a table. It returns a single row containing the number of records deleted.
+type PlanOpDelete struct {
+	planner   *ExecutionPlanner
+	tableName string
+	ChildOp   types.PlanOperator
+	warnings  []string
+}
+
+func NewPlanOpDelete(p *ExecutionPlanner, tableName string, child types.PlanOperator) *PlanOpDelete {
+	return &PlanOpDelete{
+		planner:   p,
+		tableName: tableName,
+		ChildOp:   child,
+		warnings:  make([]string, 0),
+	}
+}
+
+func (p *PlanOpDelete) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	result["child"] = p.ChildOp.Plan()
+	return result
+}
+
+func (p *PlanOpDelete) String() string {
+	return fmt.Sprintf("Delete(%s)", p.tableName)
+}
+
+func (p *PlanOpDelete) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpDelete) Warnings() []string {
+	var w []string
+	w = append(w, p.warnings...)
+	w = append(w, p.ChildOp.Warnings()...)
+	return w
+}
+
+func (p *PlanOpDelete) Schema() types.Schema {
+	return types.Schema{
+		&types.PlannerColumn{
+			ColumnName:   "count",
+			RelationName: "",
+			Type:         parser.NewDataTypeInt(),
+		},
+	}
+}
+
+func (p *PlanOpDelete) Children() []types.PlanOperator {
+	return []types.PlanOperator{
+		p.ChildOp,
+	}
+}
+
+func (p *PlanOpDelete) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	iter, err := p.ChildOp.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	return &deleteRowIter{
+		planner:   p.planner,
+		tableName: p.tableName,
+		schema:    p.ChildOp.Schema(),
+		child:     iter,
+	}, nil
+}
+
+func (p *PlanOpDelete) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 1 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpDelete(p.planner, p.tableName, children[0])
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+// recordIterator is implemented by row iterators that return the rows of a
+// table scan, or some of them, unchanged. lastRecordID returns the record id
+// of the last row returned by Next, or false if it isn't known.
+type recordIterator interface {
+	types.RowIterator
+	lastRecordID() (uint64, bool)
+}
+
+type deleteRowIter struct {
+	planner   *ExecutionPlanner
+	tableName string
+	schema    types.Schema
+	child     types.RowIterator
+	done      bool
+}
+
+var _ types.RowIterator = (*deleteRowIter)(nil)
+
+func (i *deleteRowIter) Next(ctx context.Context) (types.Row, error) {
+	if i.done {
+		return nil, types.ErrNoMoreRows
+	}
+	i.done = true
+
+	tbl, err := i.planner.schemaAPI.TableByName(ctx, dax.TableName(i.tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return nil, sql3.NewErrTableNotFound(0, 0, i.tableName)
+		}
+		return nil, err
+	}
+
+	idIndex := -1
+	for idx, col := range i.schema {
+		if strings.EqualFold(col.ColumnName, string(dax.PrimaryKeyFieldName)) {
+			idIndex = idx
+			break
+		}
+	}
+	if idIndex < 0 {
+		return nil, sql3.NewErrInternalf("unable to find '%s' in delete source", dax.PrimaryKeyFieldName)
+	}
+
+	// gather the records to delete, from the positions of the rows in the
+	// table when the source knows them, otherwise from their _id
+	records, _ := i.child.(recordIterator)
+	recordIDs := make([]uint64, 0)
+	ids := make([]interface{}, 0)
+	for {
+		row, err := i.child.Next(ctx)
+		if err == types.ErrNoMoreRows {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if records != nil {
+			if id, ok := records.lastRecordID(); ok {
+				recordIDs = append(recordIDs, id)
+				continue
+			}
+		}
+		ids = append(ids, row[idIndex])
+	}
+
+	found, err := i.recordIDs(ctx, tbl, ids)
+	if err != nil {
+		return nil, err
+	}
+	recordIDs = append(recordIDs, found...)
+
+	// clear the records in every view of every field, shard by shard
+	shards := make(map[uint64]*roaring.Bitmap)
+	for _, id := range recordIDs {
+		shard := id / api.ShardWidth
+		cols, ok := shards[shard]
+		if !ok {
+			cols = roaring.NewSliceBitmap()
+			shards[shard] = cols
+		}
+		cols.DirectAdd(id % api.ShardWidth)
+	}
+	shardNums := make([]uint64, 0, len(shards))
+	for shard := range shards {
+		shardNums = append(shardNums, shard)
+	}
+	sort.Slice(shardNums, func(a, b int) bool { return shardNums[a] < shardNums[b] })
+
+	count := int64(0)
+	for _, shard := range shardNums {
+		cols := shards[shard]
+		count += int64(cols.Count())
+		if err := i.clearShard(ctx, tbl, shard, cols); err != nil {
+			return nil, err
+		}
+	}
+	return types.Row{count}, nil
+}
+
+// recordIDs returns the record ids for _id values, which are keys for tables
+// with string keys.
+func (i *deleteRowIter) recordIDs(ctx context.Context, tbl *dax.Table, ids []interface{}) ([]uint64, error) {
+	result := make([]uint64, 0, len(ids))
+	if !tbl.StringKeys() {
+		for _, id := range ids {
+			v, ok := id.(int64)
+			if !ok {
+				return nil, sql3.NewErrInternalf("unexpected _id value type '%T'", id)
+			}
+			result = append(result, uint64(v))
+		}
+		return result, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		v, ok := id.(string)
+		if !ok {
+			return nil, sql3.NewErrInternalf("unexpected _id value type '%T'", id)
+		}
+		keys = append(keys, v)
+	}
+	if len(keys) == 0 {
+		return result, nil
+	}
+	// a key that isn't found has no record to delete
+	m, err := i.planner.executor.FindTableKeys(ctx, tbl.ID, keys...)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		id, ok := m[key]
+		if !ok {
+			continue
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+// clearShard clears the records in cols (which are shard relative) from every
+// view of every field in the shard, in a single request so that views created
+// concurrently are cleared too.
+func (i *deleteRowIter) clearShard(ctx context.Context, tbl *dax.Table, shard uint64, cols *roaring.Bitmap) error {
+	fieldNames := []dax.FieldName{api.ExistenceFieldName}
+	for _, fld := range tbl.Fields {
+		if fld.IsPrimaryKey() {
+			continue
+		}
+		fieldNames = append(fieldNames, fld.Name)
+	}
+
+	clear := cols.Roaring()
+	updates := make([]api.RoaringUpdate, 0, len(fieldNames))
+	for _, fname := range fieldNames {
+		updates = append(updates, api.RoaringUpdate{
+			Field:        string(fname),
+			View:         api.ViewAll,
+			Clear:        clear,
+			ClearRecords: true,
+		})
+	}
+	return i.planner.importer.ImportRoaringShard(ctx, tbl.ID, shard, &api.ImportRoaringShardRequest{
+		Views: updates,
+	})
+}
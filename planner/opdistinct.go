@@ -70,7 +70,7 @@ func (p *PlanOpDistinct) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpDistinct) String() string {
-	return ""
+	return "Distinct"
 }
 
 func (p *PlanOpDistinct) AddWarning(warning string) {
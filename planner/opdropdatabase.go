@@ -0,0 +1,96 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpDropDatabase plan operator to drop a database.
+type PlanOpDropDatabase struct {
+	planner      *ExecutionPlanner
+	databaseName string
+	ifExists     bool
+	warnings     []string
+}
+
+func NewPlanOpDropDatabase(p *ExecutionPlanner, databaseName string, ifExists bool) *PlanOpDropDatabase {
+	return &PlanOpDropDatabase{
+		planner:      p,
+		databaseName: databaseName,
+		ifExists:     ifExists,
+		warnings:     make([]string, 0),
+	}
+}
+
+func (p *PlanOpDropDatabase) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["databaseName"] = p.databaseName
+	result["ifExists"] = p.ifExists
+	return result
+}
+
+func (p *PlanOpDropDatabase) String() string {
+	return fmt.Sprintf("DropDatabase(%s)", p.databaseName)
+}
+
+func (p *PlanOpDropDatabase) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpDropDatabase) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpDropDatabase) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpDropDatabase) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpDropDatabase) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &dropDatabaseRowIter{
+		planner:      p.planner,
+		databaseName: p.databaseName,
+		ifExists:     p.ifExists,
+	}, nil
+}
+
+func (p *PlanOpDropDatabase) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+type dropDatabaseRowIter struct {
+	planner      *ExecutionPlanner
+	databaseName string
+	ifExists     bool
+}
+
+var _ types.RowIterator = (*dropDatabaseRowIter)(nil)
+
+func (i *dropDatabaseRowIter) Next(ctx context.Context) (types.Row, error) {
+	// databases are dropped by id
+	db, err := i.planner.schemaAPI.DatabaseByName(ctx, dax.DatabaseName(i.databaseName))
+	if err == nil {
+		err = i.planner.schemaAPI.DropDatabase(ctx, db.ID)
+	}
+	if err != nil {
+		if isDatabaseNotFoundError(err) {
+			if i.ifExists {
+				return nil, types.ErrNoMoreRows
+			}
+			return nil, sql3.NewErrDatabaseNotFound(0, 0, i.databaseName)
+		}
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
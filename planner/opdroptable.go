@@ -0,0 +1,92 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpDropTable plan operator to drop a table.
+type PlanOpDropTable struct {
+	planner   *ExecutionPlanner
+	tableName string
+	ifExists  bool
+	warnings  []string
+}
+
+func NewPlanOpDropTable(p *ExecutionPlanner, tableName string, ifExists bool) *PlanOpDropTable {
+	return &PlanOpDropTable{
+		planner:   p,
+		tableName: tableName,
+		ifExists:  ifExists,
+		warnings:  make([]string, 0),
+	}
+}
+
+func (p *PlanOpDropTable) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	result["ifExists"] = p.ifExists
+	return result
+}
+
+func (p *PlanOpDropTable) String() string {
+	return fmt.Sprintf("DropTable(%s)", p.tableName)
+}
+
+func (p *PlanOpDropTable) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpDropTable) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpDropTable) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpDropTable) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpDropTable) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &dropTableRowIter{
+		planner:   p.planner,
+		tableName: p.tableName,
+		ifExists:  p.ifExists,
+	}, nil
+}
+
+func (p *PlanOpDropTable) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+type dropTableRowIter struct {
+	planner   *ExecutionPlanner
+	tableName string
+	ifExists  bool
+}
+
+var _ types.RowIterator = (*dropTableRowIter)(nil)
+
+func (i *dropTableRowIter) Next(ctx context.Context) (types.Row, error) {
+	err := i.planner.schemaAPI.DeleteTable(ctx, dax.TableName(i.tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			if i.ifExists {
+				return nil, types.ErrNoMoreRows
+			}
+			return nil, sql3.NewErrTableNotFound(0, 0, i.tableName)
+		}
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
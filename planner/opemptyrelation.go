@@ -0,0 +1,68 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpEmptyRelation is an operator for a relation that is known to have no
+// rows, such as one filtered by a predicate that is always false. It has the
+// schema of the relation it replaces, but nothing is read.
+type PlanOpEmptyRelation struct {
+	schema   types.Schema
+	warnings []string
+}
+
+func NewPlanOpEmptyRelation(schema types.Schema) *PlanOpEmptyRelation {
+	return &PlanOpEmptyRelation{
+		schema:   schema,
+		warnings: make([]string, 0),
+	}
+}
+
+func (p *PlanOpEmptyRelation) Schema() types.Schema {
+	return p.schema
+}
+
+func (p *PlanOpEmptyRelation) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &emptyRelationIterator{}, nil
+}
+
+func (p *PlanOpEmptyRelation) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	op := NewPlanOpEmptyRelation(p.schema)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+func (p *PlanOpEmptyRelation) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpEmptyRelation) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	return result
+}
+
+func (p *PlanOpEmptyRelation) String() string {
+	return "EmptyRelation"
+}
+
+func (p *PlanOpEmptyRelation) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpEmptyRelation) Warnings() []string {
+	return p.warnings
+}
+
+type emptyRelationIterator struct{}
+
+func (i *emptyRelationIterator) Next(ctx context.Context) (types.Row, error) {
+	return nil, types.ErrNoMoreRows
+}
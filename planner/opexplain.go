@@ -0,0 +1,213 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpExplain plan operator handles EXPLAIN. It returns a single row
+// describing the plan of its child, as an indented text tree and as JSON,
+// along with the plan after each optimizer rule that changed it. The
+// child is only executed for EXPLAIN ANALYZE, in which case the description
+// includes the statistics collected for each operator.
+type PlanOpExplain struct {
+	planner *ExecutionPlanner
+	ChildOp types.PlanOperator
+	analyze bool
+
+	// the optimizer rules that changed the plan of the child
+	steps []*optimizerStep
+
+	// the instrumented child, once it has been executed by EXPLAIN ANALYZE
+	analyzedOp types.PlanOperator
+
+	warnings []string
+}
+
+func NewPlanOpExplain(p *ExecutionPlanner, child types.PlanOperator, analyze bool) *PlanOpExplain {
+	return &PlanOpExplain{
+		planner:  p,
+		ChildOp:  child,
+		analyze:  analyze,
+		warnings: make([]string, 0),
+	}
+}
+
+func (p *PlanOpExplain) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["analyze"] = p.analyze
+	rules := make([]interface{}, len(p.steps))
+	for i, step := range p.steps {
+		rules[i] = step.rule
+	}
+	result["rules"] = rules
+	if p.analyzedOp != nil {
+		result["child"] = p.analyzedOp.Plan()
+	} else {
+		result["child"] = p.ChildOp.Plan()
+	}
+	return result
+}
+
+func (p *PlanOpExplain) String() string {
+	if p.analyze {
+		return "ExplainAnalyze"
+	}
+	return "Explain"
+}
+
+func (p *PlanOpExplain) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpExplain) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpExplain) Schema() types.Schema {
+	return types.Schema{
+		&types.PlannerColumn{
+			ColumnName: "plan",
+			Type:       parser.NewDataTypeString(),
+		},
+		&types.PlannerColumn{
+			ColumnName: "json",
+			Type:       parser.NewDataTypeString(),
+		},
+		&types.PlannerColumn{
+			ColumnName: "rules",
+			Type:       parser.NewDataTypeString(),
+		},
+	}
+}
+
+func (p *PlanOpExplain) Children() []types.PlanOperator {
+	return []types.PlanOperator{
+		p.ChildOp,
+	}
+}
+
+func (p *PlanOpExplain) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &explainRowIter{
+		op:  p,
+		row: row,
+	}, nil
+}
+
+func (p *PlanOpExplain) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 1 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpExplain(p.planner, children[0], p.analyze)
+	op.steps = p.steps
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+type explainRowIter struct {
+	op   *PlanOpExplain
+	row  types.Row
+	done bool
+}
+
+var _ types.RowIterator = (*explainRowIter)(nil)
+
+func (i *explainRowIter) Next(ctx context.Context) (types.Row, error) {
+	if i.done {
+		return nil, types.ErrNoMoreRows
+	}
+	i.done = true
+
+	child := i.op.ChildOp
+	if i.op.analyze {
+		analyzed, err := instrumentPlan(child)
+		if err != nil {
+			return nil, err
+		}
+		iter, err := analyzed.Iterator(ctx, i.row)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			if _, err := iter.Next(ctx); err != nil {
+				if err == types.ErrNoMoreRows {
+					break
+				}
+				return nil, err
+			}
+		}
+		i.op.analyzedOp = analyzed
+		child = analyzed
+	}
+
+	plan, err := json.MarshalIndent(child.Plan(), "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return types.Row{
+		explainTree(child),
+		string(plan),
+		explainSteps(i.op.steps),
+	}, nil
+}
+
+// explainTree returns the plan rooted at op as a text tree, one operator per
+// line, with each level of children indented by two spaces.
+func explainTree(op types.PlanOperator) string {
+	var sb strings.Builder
+	writeExplainTree(&sb, op, 0)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func writeExplainTree(sb *strings.Builder, op types.PlanOperator, depth int) {
+	desc := op.String()
+	if desc == "" {
+		desc = fmt.Sprintf("%T", op)
+	}
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(desc)
+	sb.WriteString("\n")
+	// an instrumented operator is described along with its statistics, so
+	// its children are the next level down
+	if analyze, ok := op.(*PlanOpAnalyze); ok {
+		op = analyze.ChildOp
+	}
+	for _, child := range op.Children() {
+		writeExplainTree(sb, child, depth+1)
+	}
+}
+
+// explainSteps returns the name of each optimizer rule in steps, followed by
+// the plan after it was applied, indented by two spaces
+func explainSteps(steps []*optimizerStep) string {
+	var sb strings.Builder
+	for _, step := range steps {
+		sb.WriteString(step.rule)
+		sb.WriteString("\n")
+		for _, line := range strings.Split(step.plan, "\n") {
+			sb.WriteString("  ")
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// expressionListString returns the descriptions of exprs separated by commas
+func expressionListString(exprs []types.PlanExpression) string {
+	s := make([]string, len(exprs))
+	for i, e := range exprs {
+		s[i] = e.String()
+	}
+	return strings.Join(s, ", ")
+}
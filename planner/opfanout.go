@@ -59,7 +59,7 @@ func (p *PlanOpFanout) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpFanout) String() string {
-	return ""
+	return "Fanout"
 }
 
 func (p *PlanOpFanout) AddWarning(warning string) {
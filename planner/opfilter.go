@@ -63,7 +63,7 @@ func (p *PlanOpFilter) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpFilter) String() string {
-	return ""
+	return fmt.Sprintf("Filter(%s)", p.Predicate.String())
 }
 
 func (p *PlanOpFilter) AddWarning(warning string) {
@@ -104,6 +104,13 @@ func newFilterIterator(ctx context.Context, predicate types.PlanExpression, chil
 	}
 }
 
+func (i *filterIterator) lastRecordID() (uint64, bool) {
+	if records, ok := i.child.(recordIterator); ok {
+		return records.lastRecordID()
+	}
+	return 0, false
+}
+
 func (i *filterIterator) Next(ctx context.Context) (types.Row, error) {
 	for {
 		row, err := i.child.Next(ctx)
@@ -34,15 +34,15 @@ func NewPlanOpGroupBy(aggregates []types.PlanExpression, groupByExprs []types.Pl
 func (p *PlanOpGroupBy) Schema() types.Schema {
 	result := make(types.Schema, len(p.GroupByExprs)+len(p.Aggregates))
 	for idx, expr := range p.GroupByExprs {
-		ref, ok := expr.(*qualifiedRefPlanExpression)
-		if !ok {
-			continue
-		}
 		s := &types.PlannerColumn{
-			ColumnName:   ref.columnName,
-			RelationName: ref.tableName,
+			ColumnName:   expr.String(),
+			RelationName: "",
 			Type:         expr.Type(),
 		}
+		if ref, ok := expr.(*qualifiedRefPlanExpression); ok {
+			s.ColumnName = ref.columnName
+			s.RelationName = ref.tableName
+		}
 		result[idx] = s
 	}
 	offset := len(p.GroupByExprs)
@@ -90,10 +90,12 @@ func (p *PlanOpGroupBy) Expressions() []types.PlanExpression {
 }
 
 func (p *PlanOpGroupBy) WithUpdatedExpressions(exprs ...types.PlanExpression) (types.PlanOperator, error) {
-	if len(exprs) != 1 {
+	if len(exprs) != len(p.Aggregates) {
 		return nil, sql3.NewErrInternalf("unexpected number of exprs '%d'", len(exprs))
 	}
-	return NewPlanOpGroupBy(exprs, p.GroupByExprs, p.ChildOp), nil
+	op := NewPlanOpGroupBy(exprs, p.GroupByExprs, p.ChildOp)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
 }
 
 func (p *PlanOpGroupBy) Plan() map[string]interface{} {
@@ -115,7 +117,10 @@ func (p *PlanOpGroupBy) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpGroupBy) String() string {
-	return ""
+	if len(p.GroupByExprs) == 0 {
+		return fmt.Sprintf("GroupBy(%s)", expressionListString(p.Aggregates))
+	}
+	return fmt.Sprintf("GroupBy(%s; group by %s)", expressionListString(p.Aggregates), expressionListString(p.GroupByExprs))
 }
 
 func (p *PlanOpGroupBy) AddWarning(warning string) {
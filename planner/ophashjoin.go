@@ -0,0 +1,328 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpHashJoin plan operator handles a join where the condition includes
+// equalities between expressions of the top input and expressions of the
+// bottom input. The rows of the smaller input are put in a hash table keyed on
+// their side of the equalities, and the rows of the other input are matched by
+// looking up their side of the equalities. Any other part of the condition is
+// evaluated for each match.
+type PlanOpHashJoin struct {
+	top    types.PlanOperator
+	bottom types.PlanOperator
+	jType  joinType
+
+	// the keys of the join, evaluated on top rows and on joined rows
+	topKeys    []types.PlanExpression
+	bottomKeys []types.PlanExpression
+
+	// the rest of the join condition, evaluated on joined rows
+	cond types.PlanExpression
+
+	warnings []string
+}
+
+func NewPlanOpHashJoin(top, bottom types.PlanOperator, jType joinType, topKeys, bottomKeys []types.PlanExpression, condition types.PlanExpression) *PlanOpHashJoin {
+	return &PlanOpHashJoin{
+		top:        top,
+		bottom:     bottom,
+		jType:      jType,
+		topKeys:    topKeys,
+		bottomKeys: bottomKeys,
+		cond:       condition,
+		warnings:   make([]string, 0),
+	}
+}
+
+func (p *PlanOpHashJoin) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["top"] = p.top.Plan()
+	result["bottom"] = p.bottom.Plan()
+	keys := make([]interface{}, len(p.topKeys))
+	for i := range p.topKeys {
+		keys[i] = map[string]interface{}{
+			"top":    p.topKeys[i].Plan(),
+			"bottom": p.bottomKeys[i].Plan(),
+		}
+	}
+	result["keys"] = keys
+	if p.cond != nil {
+		result["condition"] = p.cond.Plan()
+	}
+	return result
+}
+
+func (p *PlanOpHashJoin) String() string {
+	keys := make([]string, len(p.topKeys))
+	for i := range p.topKeys {
+		keys[i] = fmt.Sprintf("%s=%s", p.topKeys[i].String(), p.bottomKeys[i].String())
+	}
+	if p.cond == nil {
+		return fmt.Sprintf("HashJoin(%s join on %s)", p.jType, strings.Join(keys, ", "))
+	}
+	return fmt.Sprintf("HashJoin(%s join on %s; filter %s)", p.jType, strings.Join(keys, ", "), p.cond.String())
+}
+
+func (p *PlanOpHashJoin) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpHashJoin) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpHashJoin) Schema() types.Schema {
+	result := types.Schema{}
+	result = append(result, p.top.Schema()...)
+	result = append(result, p.bottom.Schema()...)
+	return result
+}
+
+func (p *PlanOpHashJoin) Children() []types.PlanOperator {
+	return []types.PlanOperator{
+		p.top,
+		p.bottom,
+	}
+}
+
+func (p *PlanOpHashJoin) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	topIter, err := p.top.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	bottomIter, err := p.bottom.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	return &hashJoinIter{
+		op:        p,
+		top:       topIter,
+		bottom:    bottomIter,
+		topWidth:  len(p.top.Schema()),
+		rowWidth:  len(row) + len(p.top.Schema()) + len(p.bottom.Schema()),
+		hashTable: make(map[string][]int),
+	}, nil
+}
+
+func (p *PlanOpHashJoin) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 2 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpHashJoin(children[0], children[1], p.jType, p.topKeys, p.bottomKeys, p.cond)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+type hashJoinIter struct {
+	op       *PlanOpHashJoin
+	top      types.RowIterator
+	bottom   types.RowIterator
+	topWidth int
+	rowWidth int
+
+	// the rows of the input with the hash table, and whether each has been
+	// matched
+	built     bool
+	buildTop  bool
+	buildRows []types.Row
+	matched   []bool
+	hashTable map[string][]int
+
+	// the rows of the other input read while deciding which input is
+	// smaller, followed by the rest of that input
+	probeRows []types.Row
+	probe     types.RowIterator
+	probeDone bool
+
+	// joined rows waiting to be returned
+	pending []types.Row
+
+	// the next build row to check for a match, once the probe is done
+	unmatched int
+}
+
+var _ types.RowIterator = (*hashJoinIter)(nil)
+
+// build reads rows from both inputs in turn until one of them runs out, and
+// puts the rows of that input in the hash table
+func (i *hashJoinIter) build(ctx context.Context) error {
+	var topRows, bottomRows []types.Row
+	for {
+		row, err := i.bottom.Next(ctx)
+		if err == types.ErrNoMoreRows {
+			i.buildRows, i.probeRows, i.probe = bottomRows, topRows, i.top
+			break
+		} else if err != nil {
+			return err
+		}
+		bottomRows = append(bottomRows, row)
+
+		row, err = i.top.Next(ctx)
+		if err == types.ErrNoMoreRows {
+			i.buildTop = true
+			i.buildRows, i.probeRows, i.probe = topRows, bottomRows, i.bottom
+			break
+		} else if err != nil {
+			return err
+		}
+		topRows = append(topRows, row)
+	}
+
+	i.matched = make([]bool, len(i.buildRows))
+	for idx, row := range i.buildRows {
+		key, ok, err := i.key(ctx, row, i.buildTop)
+		if err != nil {
+			return err
+		}
+		if ok {
+			i.hashTable[key] = append(i.hashTable[key], idx)
+		}
+	}
+	i.built = true
+	return nil
+}
+
+// key returns the hash table key for a row of the top or bottom input, or
+// false if any of its key values is null, since null matches nothing
+func (i *hashJoinIter) key(ctx context.Context, row types.Row, top bool) (string, bool, error) {
+	keys := i.op.bottomKeys
+	if top {
+		keys = i.op.topKeys
+	} else {
+		// the bottom keys are evaluated on joined rows
+		joined := make(types.Row, i.topWidth+len(row))
+		copy(joined[i.topWidth:], row)
+		row = joined
+	}
+	// each value is prefixed with its length, so that the values of
+	// different rows can't run together into the same key
+	var buf bytes.Buffer
+	for _, expr := range keys {
+		v, err := expr.Evaluate(row)
+		if err != nil {
+			return "", false, err
+		}
+		if v == nil {
+			return "", false, nil
+		}
+		s := fmt.Sprintf("%#v", v)
+		fmt.Fprintf(&buf, "%d:%s", len(s), s)
+	}
+	return buf.String(), true, nil
+}
+
+func (i *hashJoinIter) nextProbeRow(ctx context.Context) (types.Row, error) {
+	if len(i.probeRows) > 0 {
+		row := i.probeRows[0]
+		i.probeRows = i.probeRows[1:]
+		return row, nil
+	}
+	return i.probe.Next(ctx)
+}
+
+// joinRow returns the joined row for a top row and a bottom row, either of
+// which may be nil
+func (i *hashJoinIter) joinRow(top, bottom types.Row) types.Row {
+	row := make(types.Row, i.rowWidth)
+	copy(row, top)
+	copy(row[i.topWidth:], bottom)
+	return row
+}
+
+// match adds the joined rows for a row of the probe input to the pending rows
+func (i *hashJoinIter) match(ctx context.Context, probeRow types.Row) error {
+	key, ok, err := i.key(ctx, probeRow, !i.buildTop)
+	if err != nil {
+		return err
+	}
+	found := false
+	if ok {
+		for _, idx := range i.hashTable[key] {
+			var row types.Row
+			if i.buildTop {
+				row = i.joinRow(i.buildRows[idx], probeRow)
+			} else {
+				row = i.joinRow(probeRow, i.buildRows[idx])
+			}
+			matches, err := conditionIsTrue(ctx, row, i.op.cond)
+			if err != nil {
+				return err
+			}
+			if !matches {
+				continue
+			}
+			found = true
+			i.matched[idx] = true
+			i.pending = append(i.pending, row)
+		}
+	}
+	// outer joins return rows that nothing matches, padded with nulls
+	if !found {
+		if i.buildTop && i.op.jType.preservesBottom() {
+			i.pending = append(i.pending, i.joinRow(nil, probeRow))
+		} else if !i.buildTop && i.op.jType.preservesTop() {
+			i.pending = append(i.pending, i.joinRow(probeRow, nil))
+		}
+	}
+	return nil
+}
+
+func (i *hashJoinIter) Next(ctx context.Context) (types.Row, error) {
+	if !i.built {
+		if err := i.build(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		if len(i.pending) > 0 {
+			row := i.pending[0]
+			i.pending = i.pending[1:]
+			return row, nil
+		}
+
+		if !i.probeDone {
+			probeRow, err := i.nextProbeRow(ctx)
+			if err == types.ErrNoMoreRows {
+				i.probeDone = true
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if err := i.match(ctx, probeRow); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// the rows in the hash table that nothing matched are returned once
+		// all the rows of the other input have been seen
+		if i.buildTop && !i.op.jType.preservesTop() || !i.buildTop && !i.op.jType.preservesBottom() {
+			return nil, types.ErrNoMoreRows
+		}
+		for i.unmatched < len(i.buildRows) {
+			idx := i.unmatched
+			i.unmatched++
+			if i.matched[idx] {
+				continue
+			}
+			if i.buildTop {
+				return i.joinRow(i.buildRows[idx], nil), nil
+			}
+			return i.joinRow(nil, i.buildRows[idx]), nil
+		}
+		return nil, types.ErrNoMoreRows
+	}
+}
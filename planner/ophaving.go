@@ -63,7 +63,7 @@ func (p *PlanOpHaving) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpHaving) String() string {
-	return ""
+	return fmt.Sprintf("Having(%s)", p.Predicate.String())
 }
 
 func (p *PlanOpHaving) AddWarning(warning string) {
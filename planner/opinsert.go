@@ -0,0 +1,170 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// insertBatchSize is the number of records written in a single import
+const insertBatchSize = 1000
+
+// PlanOpInsert plan operator to handle INSERT and REPLACE.
+type PlanOpInsert struct {
+	planner       *ExecutionPlanner
+	tableName     string
+	targetColumns []*qualifiedRefPlanExpression
+	insertValues  [][]types.PlanExpression
+	replace       bool
+	warnings      []string
+}
+
+func NewPlanOpInsert(p *ExecutionPlanner, tableName string, targetColumns []*qualifiedRefPlanExpression, insertValues [][]types.PlanExpression, replace bool) *PlanOpInsert {
+	return &PlanOpInsert{
+		planner:       p,
+		tableName:     tableName,
+		targetColumns: targetColumns,
+		insertValues:  insertValues,
+		replace:       replace,
+		warnings:      make([]string, 0),
+	}
+}
+
+func (p *PlanOpInsert) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	result["replace"] = p.replace
+	ps := make([]interface{}, 0)
+	for _, e := range p.targetColumns {
+		ps = append(ps, e.Plan())
+	}
+	result["targetColumns"] = ps
+	pps := make([]interface{}, 0)
+	for _, tuple := range p.insertValues {
+		ps := make([]interface{}, 0)
+		for _, e := range tuple {
+			ps = append(ps, e.Plan())
+		}
+		pps = append(pps, ps)
+	}
+	result["insertValues"] = pps
+	return result
+}
+
+func (p *PlanOpInsert) String() string {
+	op := "Insert"
+	if p.replace {
+		op = "Replace"
+	}
+	return fmt.Sprintf("%s(%s; columns %s; %d rows)", op, p.tableName, qualifiedRefListString(p.targetColumns), len(p.insertValues))
+}
+
+func (p *PlanOpInsert) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpInsert) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpInsert) Schema() types.Schema {
+	return types.Schema{}
+}
+
+func (p *PlanOpInsert) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpInsert) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	return &insertRowIter{
+		planner:       p.planner,
+		tableName:     p.tableName,
+		targetColumns: p.targetColumns,
+		insertValues:  p.insertValues,
+		replace:       p.replace,
+	}, nil
+}
+
+func (p *PlanOpInsert) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return NewPlanOpInsert(p.planner, p.tableName, p.targetColumns, p.insertValues, p.replace), nil
+}
+
+type insertRowIter struct {
+	planner       *ExecutionPlanner
+	tableName     string
+	targetColumns []*qualifiedRefPlanExpression
+	insertValues  [][]types.PlanExpression
+	replace       bool
+}
+
+var _ types.RowIterator = (*insertRowIter)(nil)
+
+func (i *insertRowIter) Next(ctx context.Context) (types.Row, error) {
+	if i.insertValues == nil {
+		return nil, types.ErrNoMoreRows
+	}
+	// the insert happens on the first call; there are never any rows
+	defer func() {
+		i.insertValues = nil
+	}()
+
+	tbl, err := i.planner.schemaAPI.TableByName(ctx, dax.TableName(i.tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return nil, sql3.NewErrTableNotFound(0, 0, i.tableName)
+		}
+		return nil, err
+	}
+
+	target, err := newInsertTarget(tbl, i.targetColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := newImportBatch(i.planner, tbl, target.fields, i.replace)
+	for rowIdx, tuple := range i.insertValues {
+		row := make([]interface{}, len(tuple))
+		rowTypes := make([]parser.ExprDataType, len(tuple))
+		for j, expr := range tuple {
+			row[j], err = expr.Evaluate(nil)
+			if err != nil {
+				return nil, err
+			}
+			rowTypes[j] = expr.Type()
+		}
+		id, values, err := target.record(row, rowTypes, rowIdx+1)
+		if err != nil {
+			return nil, err
+		}
+		batch.add(id, values)
+
+		if batch.len() >= insertBatchSize {
+			if err := batch.flush(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
+	}
+	return nil, types.ErrNoMoreRows
+}
+
+// qualifiedRefListString returns the names of the columns in refs separated
+// by commas
+func qualifiedRefListString(refs []*qualifiedRefPlanExpression) string {
+	s := make([]string, len(refs))
+	for i, r := range refs {
+		s[i] = r.columnName
+	}
+	return strings.Join(s, ", ")
+}
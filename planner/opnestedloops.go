@@ -43,7 +43,10 @@ func (p *PlanOpNestedLoops) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpNestedLoops) String() string {
-	return ""
+	if p.cond == nil {
+		return fmt.Sprintf("NestedLoops(%s join)", p.jType)
+	}
+	return fmt.Sprintf("NestedLoops(%s join on %s)", p.jType, p.cond.String())
 }
 
 func (p *PlanOpNestedLoops) AddWarning(warning string) {
@@ -74,8 +77,9 @@ func (p *PlanOpNestedLoops) Iterator(ctx context.Context, row types.Row) (types.
 		return nil, err
 	}
 
-	rowWidth := len(row) + len(p.top.Schema()) + len(p.bottom.Schema())
-	return newNestedLoopsIter(ctx, p.jType, topIter, p.bottom, row, p.cond, rowWidth, row), nil
+	topWidth := len(p.top.Schema())
+	rowWidth := len(row) + topWidth + len(p.bottom.Schema())
+	return newNestedLoopsIter(ctx, p.jType, topIter, p.bottom, row, p.cond, topWidth, rowWidth, row), nil
 }
 
 func (p *PlanOpNestedLoops) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
@@ -95,10 +99,13 @@ func (p *PlanOpNestedLoops) Expressions() []types.PlanExpression {
 }
 
 func (p *PlanOpNestedLoops) WithUpdatedExpressions(exprs ...types.PlanExpression) (types.PlanOperator, error) {
+	cond := p.cond
 	if len(exprs) == 1 {
-		p.cond = exprs[0]
+		cond = exprs[0]
 	}
-	return p, nil
+	op := NewPlanOpNestedLoops(p.top, p.bottom, p.jType, cond)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
 }
 
 type joinType byte
@@ -110,6 +117,33 @@ const (
 	joinTypeFull                  // all records when there is a match in either left or right table
 )
 
+// preservesTop returns true if the join returns top rows that match no bottom
+// row
+func (j joinType) preservesTop() bool {
+	return j == joinTypeLeft || j == joinTypeFull
+}
+
+// preservesBottom returns true if the join returns bottom rows that match no
+// top row
+func (j joinType) preservesBottom() bool {
+	return j == joinTypeRight || j == joinTypeFull
+}
+
+func (j joinType) String() string {
+	switch j {
+	case joinTypeInner:
+		return "inner"
+	case joinTypeLeft:
+		return "left"
+	case joinTypeRight:
+		return "right"
+	case joinTypeFull:
+		return "full"
+	default:
+		return "unknown"
+	}
+}
+
 type nestedLoopsIter struct {
 	typ joinType
 
@@ -122,17 +156,25 @@ type nestedLoopsIter struct {
 
 	topRow     types.Row
 	foundMatch bool
+	topWidth   int
 	rowSize    int
 
+	// the position of the next row of the bottom input, and which positions
+	// have matched a top row, for joins that return unmatched bottom rows
+	bottomPos     int
+	bottomMatched []bool
+	topDone       bool
+
 	originalRow types.Row
 }
 
-func newNestedLoopsIter(ctx context.Context, jt joinType, top types.RowIterator, bottom types.RowIterable, scopeRow types.Row, joinCondition types.PlanExpression, rowWidth int, originalRow types.Row) *nestedLoopsIter {
+func newNestedLoopsIter(ctx context.Context, jt joinType, top types.RowIterator, bottom types.RowIterable, scopeRow types.Row, joinCondition types.PlanExpression, topWidth int, rowWidth int, originalRow types.Row) *nestedLoopsIter {
 	return &nestedLoopsIter{
 		typ:            jt,
 		top:            top,
 		bottomProvider: bottom,
 		cond:           joinCondition,
+		topWidth:       topWidth,
 		rowSize:        rowWidth,
 		originalRow:    originalRow,
 		ctx:            ctx,
@@ -166,6 +208,7 @@ func (i *nestedLoopsIter) loadBottom(ctx context.Context) (row types.Row, err er
 		}
 
 		i.bottom = iter
+		i.bottomPos = 0
 	}
 	rightRow, err := i.bottom.Next(ctx)
 	if err != nil {
@@ -177,35 +220,66 @@ func (i *nestedLoopsIter) loadBottom(ctx context.Context) (row types.Row, err er
 		}
 		return nil, err
 	}
+	i.bottomPos++
 
 	//DEBUG log.Printf("bottom row %v", rightRow)
 	return rightRow, nil
 }
 
+// matchBottom records that the last bottom row loaded matched a top row
+func (i *nestedLoopsIter) matchBottom() {
+	if !i.typ.preservesBottom() {
+		return
+	}
+	pos := i.bottomPos - 1
+	for len(i.bottomMatched) <= pos {
+		i.bottomMatched = append(i.bottomMatched, false)
+	}
+	i.bottomMatched[pos] = true
+}
+
+// nextUnmatchedBottom returns the next bottom row that didn't match any top
+// row, once all the top rows have been seen. The bottom input is read in the
+// same order for each top row, so rows are identified by their position.
+func (i *nestedLoopsIter) nextUnmatchedBottom(ctx context.Context) (types.Row, error) {
+	if i.bottom == nil {
+		iter, err := i.bottomProvider.Iterator(ctx, i.originalRow)
+		if err != nil {
+			return nil, err
+		}
+		i.bottom = iter
+		i.bottomPos = 0
+	}
+	for {
+		row, err := i.bottom.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		pos := i.bottomPos
+		i.bottomPos++
+		if pos < len(i.bottomMatched) && i.bottomMatched[pos] {
+			continue
+		}
+		return i.buildRow(nil, row)
+	}
+}
+
+// buildRow returns the joined row for a top row, which starts with the
+// original row, and a bottom row. Either may be nil if it is missing from
+// an outer join.
 func (i *nestedLoopsIter) buildRow(primary, secondary types.Row) (types.Row, error) {
 	row := make(types.Row, i.rowSize)
 
-	primary = primary[len(i.originalRow):]
-
-	var first, second types.Row
-	var secondOffset int
 	switch i.typ {
-	case joinTypeLeft:
-		first = primary
-		second = secondary
-		secondOffset = len(first)
-
-	case joinTypeInner:
-		first = primary
-		second = secondary
-		secondOffset = len(first)
-
+	case joinTypeInner, joinTypeLeft, joinTypeRight, joinTypeFull:
 	default:
 		return nil, sql3.NewErrInternalf("unsupported join type %d", i.typ)
 	}
 
-	copy(row, first)
-	copy(row[secondOffset:], second)
+	if primary != nil {
+		copy(row, primary[len(i.originalRow):])
+	}
+	copy(row[i.topWidth:], secondary)
 	return row, nil
 }
 
@@ -222,7 +296,16 @@ func conditionIsTrue(ctx context.Context, row types.Row, cond types.PlanExpressi
 
 func (i *nestedLoopsIter) Next(ctx context.Context) (types.Row, error) {
 	for {
+		// once all the top rows have been seen, right and full joins return
+		// the bottom rows that nothing matched
+		if i.topDone {
+			return i.nextUnmatchedBottom(ctx)
+		}
 		if err := i.loadTop(ctx); err != nil {
+			if err == types.ErrNoMoreRows && i.typ.preservesBottom() {
+				i.topDone = true
+				continue
+			}
 			return nil, err
 		}
 
@@ -232,10 +315,10 @@ func (i *nestedLoopsIter) Next(ctx context.Context) (types.Row, error) {
 			if err == types.ErrNoMoreRows {
 				// no more rows from secondary
 				switch i.typ {
-				case joinTypeInner:
+				case joinTypeInner, joinTypeRight:
 					continue
 
-				case joinTypeLeft:
+				case joinTypeLeft, joinTypeFull:
 					if !i.foundMatch {
 						row, err := i.buildRow(primary, nil)
 						if err != nil {
@@ -245,12 +328,6 @@ func (i *nestedLoopsIter) Next(ctx context.Context) (types.Row, error) {
 					}
 					continue
 
-				case joinTypeRight:
-					return nil, sql3.NewErrInternalf("unhandled join type %v", i.typ)
-
-				case joinTypeFull:
-					return nil, sql3.NewErrInternalf("unhandled join type %v", i.typ)
-
 				default:
 					return nil, sql3.NewErrInternalf("unhandled join type %v", i.typ)
 				}
@@ -272,6 +349,7 @@ func (i *nestedLoopsIter) Next(ctx context.Context) (types.Row, error) {
 		}
 
 		i.foundMatch = true
+		i.matchBottom()
 
 		// DEBUG log.Printf("join result %v", row)
 
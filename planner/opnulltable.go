@@ -45,7 +45,7 @@ func (p *PlanOpNullTable) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpNullTable) String() string {
-	return ""
+	return "NullTable"
 }
 
 func (p *PlanOpNullTable) AddWarning(warning string) {
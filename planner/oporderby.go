@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gernest/sql3"
@@ -22,6 +23,13 @@ const (
 	orderByDesc orderByOrder = 2
 )
 
+func (o orderByOrder) String() string {
+	if o == orderByDesc {
+		return "desc"
+	}
+	return "asc"
+}
+
 // nullOrdering specifies how to handle null values during order by.
 type nullOrdering byte
 
@@ -90,14 +98,25 @@ func (n *PlanOpOrderBy) WithUpdatedExpressions(exprs ...types.PlanExpression) (t
 	if len(exprs) != len(n.orderByFields) {
 		return nil, sql3.NewErrInternalf("unexpected number of exprs '%d'", len(exprs))
 	}
+	fields := make([]*OrderByExpression, len(exprs))
 	for i, e := range exprs {
-		n.orderByFields[i].Expr = e
+		fields[i] = &OrderByExpression{
+			Expr:         e,
+			Order:        n.orderByFields[i].Order,
+			NullOrdering: n.orderByFields[i].NullOrdering,
+		}
 	}
-	return n, nil
+	op := NewPlanOpOrderBy(fields, n.ChildOp)
+	op.warnings = append(op.warnings, n.warnings...)
+	return op, nil
 }
 
 func (n *PlanOpOrderBy) String() string {
-	return ""
+	fields := make([]string, len(n.orderByFields))
+	for i, f := range n.orderByFields {
+		fields[i] = fmt.Sprintf("%s %s", f.Expr.String(), f.Order)
+	}
+	return fmt.Sprintf("OrderBy(%s)", strings.Join(fields, ", "))
 }
 
 func (n *PlanOpOrderBy) Plan() map[string]interface{} {
@@ -75,7 +75,7 @@ func (p *PlanOpProjection) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpProjection) String() string {
-	return ""
+	return fmt.Sprintf("Projection(%s)", expressionListString(p.Projections))
 }
 
 func (p *PlanOpProjection) AddWarning(warning string) {
@@ -99,7 +99,9 @@ func (p *PlanOpProjection) WithUpdatedExpressions(exprs ...types.PlanExpression)
 	if len(exprs) != len(p.Projections) {
 		return nil, sql3.NewErrInternalf("unexpected number of exprs '%d'", len(exprs))
 	}
-	return NewPlanOpProjection(exprs, p.ChildOp), nil
+	op := NewPlanOpProjection(exprs, p.ChildOp)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
 }
 
 func ExpressionToColumn(e types.PlanExpression) *types.PlannerColumn {
@@ -89,7 +89,7 @@ func (p *PlanOpQuery) Warnings() []string {
 }
 
 func (p *PlanOpQuery) String() string {
-	return ""
+	return "Query"
 }
 
 type queryIterator struct {
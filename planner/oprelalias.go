@@ -60,7 +60,7 @@ func (p *PlanOpRelAlias) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpRelAlias) String() string {
-	return ""
+	return fmt.Sprintf("RelAlias(%s)", p.alias)
 }
 
 func (p *PlanOpRelAlias) AddWarning(warning string) {
@@ -95,8 +95,7 @@ func (p *PlanOpRelAlias) UpdateFilters(filterCondition types.PlanExpression) (ty
 	if err != nil {
 		return nil, err
 	}
-	p.ChildOp = newChild
-	return p, nil
+	return p.WithChildren(newChild)
 }
 
 func (p *PlanOpRelAlias) UpdateTimeQuantumFilters(filters ...types.PlanExpression) (types.PlanOperator, error) {
@@ -108,6 +107,5 @@ func (p *PlanOpRelAlias) UpdateTimeQuantumFilters(filters ...types.PlanExpressio
 	if err != nil {
 		return nil, err
 	}
-	p.ChildOp = newChild
-	return p, nil
+	return p.WithChildren(newChild)
 }
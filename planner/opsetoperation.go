@@ -0,0 +1,264 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+type setOperation int
+
+const (
+	setOperationUnion setOperation = iota
+	setOperationIntersect
+	setOperationExcept
+)
+
+func (o setOperation) String() string {
+	switch o {
+	case setOperationIntersect:
+		return "intersect"
+	case setOperationExcept:
+		return "except"
+	default:
+		return "union"
+	}
+}
+
+// PlanOpSetOperation plan operator handles UNION, INTERSECT and EXCEPT. The
+// values of both inputs are coerced to the types of its schema, which are the
+// unified types of the columns of its inputs. Duplicate rows are removed unless
+// it is a UNION ALL, which returns the rows of the top input followed by the
+// rows of the bottom input as they are read.
+type PlanOpSetOperation struct {
+	top       types.PlanOperator
+	bottom    types.PlanOperator
+	operation setOperation
+	all       bool
+	schema    types.Schema
+	warnings  []string
+
+	// the position of the bottom select statement, for errors
+	pos parser.Pos
+}
+
+// NewPlanOpSetOperation returns a set operation on top and bottom, or an error
+// if the columns of its inputs don't have the same number and compatible
+// types. pos is the position of the select statement of bottom.
+func NewPlanOpSetOperation(top, bottom types.PlanOperator, operation setOperation, all bool, pos parser.Pos) (*PlanOpSetOperation, error) {
+	topSchema := top.Schema()
+	bottomSchema := bottom.Schema()
+	if len(topSchema) != len(bottomSchema) {
+		return nil, sql3.NewErrSetOperationColumnCountMismatch(pos.Line, pos.Column, operation.String())
+	}
+	schema := make(types.Schema, len(topSchema))
+	for i, tc := range topSchema {
+		bc := bottomSchema[i]
+		col := *tc
+		switch {
+		case typesAreAssignmentCompatible(tc.Type, bc.Type):
+			col.Type = tc.Type
+		case typesAreAssignmentCompatible(bc.Type, tc.Type):
+			col.Type = bc.Type
+		default:
+			return nil, sql3.NewErrTypeMismatch(pos.Line, pos.Column, tc.Type.TypeDescription(), bc.Type.TypeDescription())
+		}
+		schema[i] = &col
+	}
+	return &PlanOpSetOperation{
+		top:       top,
+		bottom:    bottom,
+		operation: operation,
+		all:       all,
+		schema:    schema,
+		warnings:  make([]string, 0),
+		pos:       pos,
+	}, nil
+}
+
+func (p *PlanOpSetOperation) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["operation"] = p.operation.String()
+	result["all"] = p.all
+	result["top"] = p.top.Plan()
+	result["bottom"] = p.bottom.Plan()
+	return result
+}
+
+func (p *PlanOpSetOperation) String() string {
+	if p.all {
+		return fmt.Sprintf("SetOperation(%s all)", p.operation)
+	}
+	return fmt.Sprintf("SetOperation(%s)", p.operation)
+}
+
+func (p *PlanOpSetOperation) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpSetOperation) Warnings() []string {
+	var w []string
+	w = append(w, p.warnings...)
+	w = append(w, p.top.Warnings()...)
+	w = append(w, p.bottom.Warnings()...)
+	return w
+}
+
+func (p *PlanOpSetOperation) Schema() types.Schema {
+	return p.schema
+}
+
+func (p *PlanOpSetOperation) Children() []types.PlanOperator {
+	return []types.PlanOperator{
+		p.top,
+		p.bottom,
+	}
+}
+
+func (p *PlanOpSetOperation) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	topIter, err := p.top.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	bottomIter, err := p.bottom.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	return &setOperationIter{
+		op:     p,
+		top:    topIter,
+		bottom: bottomIter,
+		seen:   make(map[string]struct{}),
+	}, nil
+}
+
+func (p *PlanOpSetOperation) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 2 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op, err := NewPlanOpSetOperation(children[0], children[1], p.operation, p.all, p.pos)
+	if err != nil {
+		return nil, err
+	}
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+type setOperationIter struct {
+	op     *PlanOpSetOperation
+	top    types.RowIterator
+	bottom types.RowIterator
+
+	// the rows of the bottom input, for intersect and except
+	bottomRows map[string]struct{}
+	// the rows already returned
+	seen    map[string]struct{}
+	topDone bool
+}
+
+var _ types.RowIterator = (*setOperationIter)(nil)
+
+// coerceRow returns a row with its values converted from the types of one
+// schema to the types of another
+func coerceRow(row types.Row, from, to types.Schema) (types.Row, error) {
+	result := make(types.Row, len(row))
+	for idx, v := range row {
+		if v == nil {
+			continue
+		}
+		targetType := to[idx].Type
+		cv := v
+		if from[idx].Type.TypeDescription() != targetType.TypeDescription() {
+			var err error
+			cv, err = coerceValue(from[idx].Type, targetType, v, parser.Pos{Line: 0, Column: 0})
+			if err != nil {
+				return nil, err
+			}
+		}
+		// decimals are returned with the scale of the column, so that equal
+		// values are the same row
+		if dt, ok := targetType.(*parser.DataTypeDecimal); ok {
+			if d, ok := cv.(decimal.Decimal); ok && d.Scale < dt.Scale {
+				value := d.Value()
+				value.Mul(&value, big.NewInt(decimal.Pow10(dt.Scale-d.Scale)))
+				scaled := decimal.Decimal{Scale: dt.Scale}
+				scaled.SetBigIntValue(&value)
+				cv = scaled
+			}
+		}
+		result[idx] = cv
+	}
+	return result, nil
+}
+
+// readBottom puts the rows of the bottom input in a set
+func (i *setOperationIter) readBottom(ctx context.Context) error {
+	i.bottomRows = make(map[string]struct{})
+	schema := i.op.bottom.Schema()
+	for {
+		row, err := i.bottom.Next(ctx)
+		if err == types.ErrNoMoreRows {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		row, err = coerceRow(row, schema, i.op.schema)
+		if err != nil {
+			return err
+		}
+		i.bottomRows[string(generateRowKey(row))] = struct{}{}
+	}
+}
+
+func (i *setOperationIter) Next(ctx context.Context) (types.Row, error) {
+	if i.op.operation != setOperationUnion && i.bottomRows == nil {
+		if err := i.readBottom(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		input, schema := i.top, i.op.top.Schema()
+		if i.topDone {
+			if i.op.operation != setOperationUnion {
+				return nil, types.ErrNoMoreRows
+			}
+			input, schema = i.bottom, i.op.bottom.Schema()
+		}
+		row, err := input.Next(ctx)
+		if err == types.ErrNoMoreRows && !i.topDone {
+			i.topDone = true
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		row, err = coerceRow(row, schema, i.op.schema)
+		if err != nil {
+			return nil, err
+		}
+		if i.op.all {
+			return row, nil
+		}
+
+		key := string(generateRowKey(row))
+		if _, ok := i.seen[key]; ok {
+			continue
+		}
+		if i.op.operation != setOperationUnion {
+			_, inBottom := i.bottomRows[key]
+			if inBottom != (i.op.operation == setOperationIntersect) {
+				continue
+			}
+		}
+		i.seen[key] = struct{}{}
+		return row, nil
+	}
+}
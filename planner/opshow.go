@@ -0,0 +1,368 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpShowDatabases plan operator to list the databases.
+type PlanOpShowDatabases struct {
+	planner  *ExecutionPlanner
+	warnings []string
+}
+
+func NewPlanOpShowDatabases(p *ExecutionPlanner) *PlanOpShowDatabases {
+	return &PlanOpShowDatabases{
+		planner:  p,
+		warnings: make([]string, 0),
+	}
+}
+
+func (p *PlanOpShowDatabases) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	return result
+}
+
+func (p *PlanOpShowDatabases) String() string {
+	return "ShowDatabases"
+}
+
+func (p *PlanOpShowDatabases) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpShowDatabases) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpShowDatabases) Schema() types.Schema {
+	return showSchema(
+		"_id", parser.NewDataTypeString(),
+		"name", parser.NewDataTypeString(),
+		"owner", parser.NewDataTypeString(),
+		"updated_by", parser.NewDataTypeString(),
+		"created_at", parser.NewDataTypeTimestamp(),
+		"updated_at", parser.NewDataTypeTimestamp(),
+		"units", parser.NewDataTypeInt(),
+		"description", parser.NewDataTypeString(),
+	)
+}
+
+func (p *PlanOpShowDatabases) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpShowDatabases) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	dbs, err := p.planner.schemaAPI.Databases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]types.Row, len(dbs))
+	for i, db := range dbs {
+		rows[i] = types.Row{
+			string(db.ID),
+			string(db.Name),
+			db.Owner,
+			db.UpdatedBy,
+			unixTimestamp(db.CreatedAt),
+			unixTimestamp(db.UpdatedAt),
+			int64(db.Options.WorkersMin),
+			db.Description,
+		}
+	}
+	return &showRowIter{rows: rows}, nil
+}
+
+func (p *PlanOpShowDatabases) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+// PlanOpShowTables plan operator to list the tables.
+type PlanOpShowTables struct {
+	planner  *ExecutionPlanner
+	warnings []string
+}
+
+func NewPlanOpShowTables(p *ExecutionPlanner) *PlanOpShowTables {
+	return &PlanOpShowTables{
+		planner:  p,
+		warnings: make([]string, 0),
+	}
+}
+
+func (p *PlanOpShowTables) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	return result
+}
+
+func (p *PlanOpShowTables) String() string {
+	return "ShowTables"
+}
+
+func (p *PlanOpShowTables) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpShowTables) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpShowTables) Schema() types.Schema {
+	return showSchema(
+		"_id", parser.NewDataTypeString(),
+		"name", parser.NewDataTypeString(),
+		"owner", parser.NewDataTypeString(),
+		"updated_by", parser.NewDataTypeString(),
+		"created_at", parser.NewDataTypeTimestamp(),
+		"keys", parser.NewDataTypeBool(),
+		"key_partitions", parser.NewDataTypeInt(),
+		"description", parser.NewDataTypeString(),
+	)
+}
+
+func (p *PlanOpShowTables) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpShowTables) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	tbls, err := p.planner.schemaAPI.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]types.Row, len(tbls))
+	for i, tbl := range tbls {
+		// tables are created along with their primary key
+		var createdAt int64
+		for _, fld := range tbl.Fields {
+			if fld.IsPrimaryKey() {
+				createdAt = fld.CreatedAt
+				break
+			}
+		}
+		rows[i] = types.Row{
+			string(tbl.ID),
+			string(tbl.Name),
+			tbl.Owner,
+			tbl.UpdatedBy,
+			unixTimestamp(createdAt),
+			tbl.StringKeys(),
+			int64(tbl.PartitionN),
+			tbl.Description,
+		}
+	}
+	return &showRowIter{rows: rows}, nil
+}
+
+func (p *PlanOpShowTables) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+// PlanOpShowColumns plan operator to list the columns of a table.
+type PlanOpShowColumns struct {
+	planner   *ExecutionPlanner
+	tableName string
+	warnings  []string
+}
+
+func NewPlanOpShowColumns(p *ExecutionPlanner, tableName string) *PlanOpShowColumns {
+	return &PlanOpShowColumns{
+		planner:   p,
+		tableName: tableName,
+		warnings:  make([]string, 0),
+	}
+}
+
+func (p *PlanOpShowColumns) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	return result
+}
+
+func (p *PlanOpShowColumns) String() string {
+	return fmt.Sprintf("ShowColumns(%s)", p.tableName)
+}
+
+func (p *PlanOpShowColumns) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpShowColumns) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpShowColumns) Schema() types.Schema {
+	return showSchema(
+		"_id", parser.NewDataTypeString(),
+		"name", parser.NewDataTypeString(),
+		"type", parser.NewDataTypeString(),
+		"created_at", parser.NewDataTypeTimestamp(),
+		"keys", parser.NewDataTypeBool(),
+		"cache_type", parser.NewDataTypeString(),
+		"cache_size", parser.NewDataTypeInt(),
+		"scale", parser.NewDataTypeInt(),
+		"min", parser.NewDataTypeInt(),
+		"max", parser.NewDataTypeInt(),
+		"timeunit", parser.NewDataTypeString(),
+		"epoch", parser.NewDataTypeInt(),
+		"timequantum", parser.NewDataTypeString(),
+		"ttl", parser.NewDataTypeString(),
+	)
+}
+
+func (p *PlanOpShowColumns) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpShowColumns) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	tbl, err := showTable(ctx, p.planner, p.tableName)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]types.Row, len(tbl.Fields))
+	for i, fld := range tbl.Fields {
+		var epoch int64
+		if !fld.Options.Epoch.IsZero() {
+			epoch = fld.Options.Epoch.Unix()
+		}
+		rows[i] = types.Row{
+			string(fld.Name),
+			string(fld.Name),
+			fld.FullType(),
+			unixTimestamp(fld.CreatedAt),
+			fld.StringKeys(),
+			fld.Options.CacheType,
+			int64(fld.Options.CacheSize),
+			fld.Options.Scale,
+			fld.Options.Min,
+			fld.Options.Max,
+			fld.Options.TimeUnit,
+			epoch,
+			string(fld.Options.TimeQuantum),
+			fld.Options.TTL.String(),
+		}
+	}
+	return &showRowIter{rows: rows}, nil
+}
+
+func (p *PlanOpShowColumns) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+// PlanOpShowCreateTable plan operator to return the DDL for a table.
+type PlanOpShowCreateTable struct {
+	planner   *ExecutionPlanner
+	tableName string
+	warnings  []string
+}
+
+func NewPlanOpShowCreateTable(p *ExecutionPlanner, tableName string) *PlanOpShowCreateTable {
+	return &PlanOpShowCreateTable{
+		planner:   p,
+		tableName: tableName,
+		warnings:  make([]string, 0),
+	}
+}
+
+func (p *PlanOpShowCreateTable) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	return result
+}
+
+func (p *PlanOpShowCreateTable) String() string {
+	return fmt.Sprintf("ShowCreateTable(%s)", p.tableName)
+}
+
+func (p *PlanOpShowCreateTable) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpShowCreateTable) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpShowCreateTable) Schema() types.Schema {
+	return showSchema(
+		"ddl", parser.NewDataTypeString(),
+	)
+}
+
+func (p *PlanOpShowCreateTable) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpShowCreateTable) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	tbl, err := showTable(ctx, p.planner, p.tableName)
+	if err != nil {
+		return nil, err
+	}
+	return &showRowIter{rows: []types.Row{{tbl.CreateSQL()}}}, nil
+}
+
+func (p *PlanOpShowCreateTable) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	return p, nil
+}
+
+// showSchema returns a schema from pairs of column names and types
+func showSchema(columns ...interface{}) types.Schema {
+	schema := make(types.Schema, 0, len(columns)/2)
+	for i := 0; i < len(columns); i += 2 {
+		schema = append(schema, &types.PlannerColumn{
+			ColumnName:   columns[i].(string),
+			RelationName: "",
+			Type:         columns[i+1].(parser.ExprDataType),
+		})
+	}
+	return schema
+}
+
+func showTable(ctx context.Context, p *ExecutionPlanner, tableName string) (*dax.Table, error) {
+	tbl, err := p.schemaAPI.TableByName(ctx, dax.TableName(tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return nil, sql3.NewErrTableNotFound(0, 0, tableName)
+		}
+		return nil, err
+	}
+	return tbl, nil
+}
+
+// unixTimestamp returns a timestamp for seconds since the epoch, or nil if
+// the timestamp was never set
+func unixTimestamp(secs int64) interface{} {
+	if secs == 0 {
+		return nil
+	}
+	return time.Unix(secs, 0).UTC()
+}
+
+type showRowIter struct {
+	rows []types.Row
+}
+
+var _ types.RowIterator = (*showRowIter)(nil)
+
+func (i *showRowIter) Next(ctx context.Context) (types.Row, error) {
+	if len(i.rows) == 0 {
+		return nil, types.ErrNoMoreRows
+	}
+	row := i.rows[0]
+	i.rows = i.rows[1:]
+	return row, nil
+}
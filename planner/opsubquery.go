@@ -53,7 +53,7 @@ func (p *PlanOpSubquery) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpSubquery) String() string {
-	return ""
+	return "Subquery"
 }
 
 func (p *PlanOpSubquery) AddWarning(warning string) {
@@ -0,0 +1,500 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gernest/roaring"
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpTableScan is an operator that reads rows for a table from its shards.
+// The table is resolved when the query is compiled, so the schema of the scan
+// doesn't change if the table does.
+type PlanOpTableScan struct {
+	planner            *ExecutionPlanner
+	table              *dax.Table
+	tableName          string
+	columns            []string
+	filter             types.PlanExpression
+	timeQuantumFilters []types.PlanExpression
+	warnings           []string
+}
+
+func NewPlanOpTableScan(p *ExecutionPlanner, tbl *dax.Table, columns []string) *PlanOpTableScan {
+	return &PlanOpTableScan{
+		planner:   p,
+		table:     tbl,
+		tableName: string(tbl.Name),
+		columns:   columns,
+		warnings:  make([]string, 0),
+	}
+}
+
+// fields returns the table fields for the scan columns, in column order
+func (p *PlanOpTableScan) fields(tbl *dax.Table) ([]*dax.Field, error) {
+	result := make([]*dax.Field, len(p.columns))
+	for i, col := range p.columns {
+		found := false
+		for _, fld := range tbl.Fields {
+			if strings.EqualFold(string(fld.Name), col) {
+				result[i] = fld
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, sql3.NewErrColumnNotFound(0, 0, col)
+		}
+	}
+	return result, nil
+}
+
+func (p *PlanOpTableScan) Schema() types.Schema {
+	result := make(types.Schema, 0)
+	// the columns are fields of the table, since both come from the table
+	// the query was compiled against
+	fields, err := p.fields(p.table)
+	if err != nil {
+		return result
+	}
+	for _, fld := range fields {
+		result = append(result, &types.PlannerColumn{
+			ColumnName:   string(fld.Name),
+			RelationName: p.tableName,
+			AliasName:    "",
+			Type:         fieldSQLDataType(api.FieldToFieldInfo(fld)),
+		})
+	}
+	return result
+}
+
+func (p *PlanOpTableScan) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	tbl := p.table
+	fields, err := p.fields(tbl)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := p.planner.executor.Shards(ctx, tbl.ID)
+	if err != nil {
+		return nil, err
+	}
+	predicates, filter, err := p.bitmapFilters(ctx, tbl, fields)
+	if err != nil {
+		return nil, err
+	}
+	return &tableScanIterator{
+		planner:    p.planner,
+		table:      tbl,
+		fields:     fields,
+		predicates: predicates,
+		filter:     filter,
+		shards:     shards,
+	}, nil
+}
+
+// bitmapFilters returns predicates for the parts of the filters of the scan
+// that can be evaluated with bitmap operations, which are applied to each shard
+// before its rows are read, and the residual filter that has to be evaluated
+// for each row
+func (p *PlanOpTableScan) bitmapFilters(ctx context.Context, tbl *dax.Table, fields []*dax.Field) ([]bitmapPredicate, types.PlanExpression, error) {
+	builder := newBitmapFilterBuilder(p.planner, tbl, fields)
+	predicates, filter, err := builder.split(ctx, p.filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range p.timeQuantumFilters {
+		pred, err := builder.timeQuantumFilter(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		predicates = append(predicates, pred)
+	}
+	return predicates, filter, nil
+}
+
+func (p *PlanOpTableScan) Children() []types.PlanOperator {
+	return []types.PlanOperator{}
+}
+
+func (p *PlanOpTableScan) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 0 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	return p.copy(), nil
+}
+
+// copy returns a copy of the scan that can be changed without affecting p
+func (p *PlanOpTableScan) copy() *PlanOpTableScan {
+	op := NewPlanOpTableScan(p.planner, p.table, p.columns)
+	op.filter = p.filter
+	op.timeQuantumFilters = append(op.timeQuantumFilters, p.timeQuantumFilters...)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op
+}
+
+func (p *PlanOpTableScan) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	result["columns"] = p.columns
+	if p.filter != nil {
+		result["filter"] = p.filter.Plan()
+	}
+	if len(p.timeQuantumFilters) > 0 {
+		filters := make([]interface{}, len(p.timeQuantumFilters))
+		for i, f := range p.timeQuantumFilters {
+			filters[i] = f.Plan()
+		}
+		result["timeQuantumFilters"] = filters
+	}
+	return result
+}
+
+func (p *PlanOpTableScan) String() string {
+	desc := fmt.Sprintf("TableScan(%s; columns %s", p.tableName, strings.Join(p.columns, ", "))
+	if p.filter != nil {
+		desc += "; filter " + p.filter.String()
+	}
+	if len(p.timeQuantumFilters) > 0 {
+		desc += "; time quantum filters " + expressionListString(p.timeQuantumFilters)
+	}
+	return desc + ")"
+}
+
+func (p *PlanOpTableScan) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpTableScan) Warnings() []string {
+	return p.warnings
+}
+
+func (p *PlanOpTableScan) Name() string {
+	return p.tableName
+}
+
+func (p *PlanOpTableScan) IsFilterable() bool {
+	return true
+}
+
+// UpdateFilters returns a copy of the scan with filterCondition added to its
+// filter
+func (p *PlanOpTableScan) UpdateFilters(filterCondition types.PlanExpression) (types.PlanOperator, error) {
+	op := p.copy()
+	if op.filter != nil {
+		filterCondition = newBinOpPlanExpression(op.filter, parser.AND, filterCondition, parser.NewDataTypeBool())
+	}
+	op.filter = filterCondition
+	return op, nil
+}
+
+// UpdateTimeQuantumFilters returns a copy of the scan with filters added to
+// its time quantum filters
+func (p *PlanOpTableScan) UpdateTimeQuantumFilters(filters ...types.PlanExpression) (types.PlanOperator, error) {
+	op := p.copy()
+	op.timeQuantumFilters = append(op.timeQuantumFilters, filters...)
+	return op, nil
+}
+
+// scanChunkWidth is the number of columns of a shard read at a time by a
+// table scan, which is the width of a roaring container
+const scanChunkWidth = 1 << 16
+
+type tableScanIterator struct {
+	planner    *ExecutionPlanner
+	table      *dax.Table
+	fields     []*dax.Field
+	predicates []bitmapPredicate
+	filter     types.PlanExpression
+
+	shards []uint64
+
+	// the shard being read, the records in it that pass the bitmap filters
+	// and the first column of the next chunk of them to read
+	shard   uint64
+	records *roaring.Bitmap
+	start   uint64
+
+	// the rows read from the current chunk and their record ids, and the
+	// record id of the last row returned
+	rows   []types.Row
+	ids    []uint64
+	lastID uint64
+}
+
+var _ recordIterator = (*tableScanIterator)(nil)
+
+func (i *tableScanIterator) Next(ctx context.Context) (types.Row, error) {
+	for len(i.rows) == 0 {
+		if i.records == nil || i.start >= api.ShardWidth {
+			if len(i.shards) == 0 {
+				return nil, types.ErrNoMoreRows
+			}
+			if err := i.loadShard(ctx, i.shards[0]); err != nil {
+				return nil, err
+			}
+			i.shards = i.shards[1:]
+			continue
+		}
+		if err := i.loadChunk(ctx); err != nil {
+			return nil, err
+		}
+	}
+	row := i.rows[0]
+	i.rows = i.rows[1:]
+	i.lastID = i.ids[0]
+	i.ids = i.ids[1:]
+	return row, nil
+}
+
+func (i *tableScanIterator) lastRecordID() (uint64, bool) {
+	return i.lastID, true
+}
+
+// loadShard finds the records in a shard that pass the bitmap filters, which
+// are then read a chunk at a time
+func (i *tableScanIterator) loadShard(ctx context.Context, shard uint64) error {
+	return i.planner.executor.View(ctx, i.table.ID, shard, func(tx api.Tx) error {
+		records, err := shardRecords(tx, shard, i.predicates)
+		if err != nil {
+			return err
+		}
+		i.shard = shard
+		// the records may share containers with the transaction
+		i.records = records.Clone()
+		i.start = 0
+		return nil
+	})
+}
+
+// loadChunk reads the rows for the next chunk of records in the current shard
+// that pass the filters. Only the bits of the field bitmaps in the columns of
+// those records are decoded.
+func (i *tableScanIterator) loadChunk(ctx context.Context) error {
+	start, end := i.start, i.start+scanChunkWidth
+	i.start = end
+	cols := i.records.OffsetRange(start, start, end)
+	if !cols.Any() {
+		return nil
+	}
+
+	columns := make([]map[uint64]interface{}, len(i.fields))
+	bitmaps := make([]*roaring.Bitmap, len(i.fields))
+	err := i.planner.executor.View(ctx, i.table.ID, i.shard, func(tx api.Tx) error {
+		for j, fld := range i.fields {
+			if fld.IsPrimaryKey() {
+				continue
+			}
+			bm, err := tx.RoaringBitmap(api.BitmapName(fld.Name, api.FieldView(fld)))
+			if err != nil {
+				return err
+			}
+			bitmaps[j] = columnBits(bm, cols, start, end)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for j, bm := range bitmaps {
+		if bm == nil {
+			continue
+		}
+		columns[j], err = i.readField(ctx, i.fields[j], bm)
+		if err != nil {
+			return err
+		}
+	}
+
+	var keys map[uint64]string
+	if i.table.StringKeys() {
+		ids := make([]uint64, 0, cols.Count())
+		_ = cols.ForEach(func(col uint64) error {
+			ids = append(ids, i.shard*api.ShardWidth+col)
+			return nil
+		})
+		keys, err = i.planner.executor.TranslateTableIDs(ctx, i.table.ID, ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cols.ForEach(func(col uint64) error {
+		id := i.shard*api.ShardWidth + col
+		row := make(types.Row, len(i.fields))
+		for j, fld := range i.fields {
+			if fld.IsPrimaryKey() {
+				if keys != nil {
+					row[j] = keys[id]
+				} else {
+					row[j] = int64(id)
+				}
+				continue
+			}
+			if v, ok := columns[j][col]; ok {
+				row[j] = v
+			}
+		}
+		ok, err := conditionIsTrue(ctx, row, i.filter)
+		if err != nil {
+			return err
+		}
+		if ok {
+			i.rows = append(i.rows, row)
+			i.ids = append(i.ids, id)
+		}
+		return nil
+	})
+}
+
+// columnBits returns the bits of a shard bitmap in the columns of cols, which
+// are all in [start, end). start and end are multiples of scanChunkWidth.
+func columnBits(bm *roaring.Bitmap, cols *roaring.Bitmap, start, end uint64) *roaring.Bitmap {
+	result := roaring.NewSliceBitmap()
+	for _, row := range api.Rows(bm) {
+		base := row*api.ShardWidth + start
+		bits := bm.OffsetRange(base, base, base+end-start)
+		if !bits.Any() {
+			continue
+		}
+		result.UnionInPlace(bits.Intersect(cols.OffsetRange(base, start, end)))
+	}
+	return result
+}
+
+// shardRecords returns the shard-relative columns of the records in a shard for
+// which all the predicates are true
+func shardRecords(tx api.Tx, shard uint64, predicates []bitmapPredicate) (*roaring.Bitmap, error) {
+	bm, err := tx.RoaringBitmap(api.BitmapName(api.ExistenceFieldName, api.ViewStandard))
+	if err != nil {
+		return nil, err
+	}
+	records := api.Row(bm, 0)
+	for _, pred := range predicates {
+		cols, err := pred(tx, shard, records)
+		if err != nil {
+			return nil, err
+		}
+		records = records.Intersect(cols)
+	}
+	return records, nil
+}
+
+// readField decodes the values of a field for every record with a bit in bm,
+// returning a map of shard-relative column to value
+func (i *tableScanIterator) readField(ctx context.Context, fld *dax.Field, bm *roaring.Bitmap) (map[uint64]interface{}, error) {
+	result := make(map[uint64]interface{})
+
+	switch fld.Type {
+	case dax.BaseTypeInt, dax.BaseTypeDecimal, dax.BaseTypeTimestamp:
+		for col, v := range api.BSIValues(bm) {
+			switch fld.Type {
+			case dax.BaseTypeInt:
+				result[col] = v
+			case dax.BaseTypeDecimal:
+				result[col] = decimal.NewDecimal(v, fld.Options.Scale)
+			case dax.BaseTypeTimestamp:
+				ts, err := api.ValToTimestampField(fld, v)
+				if err != nil {
+					return nil, err
+				}
+				result[col] = ts
+			}
+		}
+		return result, nil
+
+	case dax.BaseTypeBool:
+		err := api.ForEachBit(bm, func(row, col uint64) error {
+			result[col] = row == api.TrueRowID
+			return nil
+		})
+		return result, err
+
+	case dax.BaseTypeID, dax.BaseTypeString:
+		rows := make(map[uint64]uint64)
+		err := api.ForEachBit(bm, func(row, col uint64) error {
+			rows[col] = row
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		keys, err := i.translateRows(ctx, fld, rows)
+		if err != nil {
+			return nil, err
+		}
+		for col, row := range rows {
+			if keys != nil {
+				result[col] = keys[row]
+			} else {
+				result[col] = int64(row)
+			}
+		}
+		return result, nil
+
+	case dax.BaseTypeIDSet, dax.BaseTypeIDSetQ, dax.BaseTypeStringSet, dax.BaseTypeStringSetQ:
+		sets := make(map[uint64][]uint64)
+		rows := make(map[uint64]uint64)
+		err := api.ForEachBit(bm, func(row, col uint64) error {
+			sets[col] = append(sets[col], row)
+			rows[row] = row
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		keys, err := i.translateRows(ctx, fld, rows)
+		if err != nil {
+			return nil, err
+		}
+		for col, set := range sets {
+			if keys != nil {
+				vals := make([]string, len(set))
+				for k, row := range set {
+					vals[k] = keys[row]
+				}
+				sort.Strings(vals)
+				result[col] = vals
+			} else {
+				vals := make([]int64, len(set))
+				for k, row := range set {
+					vals[k] = int64(row)
+				}
+				result[col] = vals
+			}
+		}
+		return result, nil
+
+	default:
+		return nil, sql3.NewErrInternalf("unexpected field type '%s'", fld.Type)
+	}
+}
+
+// translateRows returns keys for the row ids (the values of rows) of a field
+// with string keys, or nil if the field does not use string keys
+func (i *tableScanIterator) translateRows(ctx context.Context, fld *dax.Field, rows map[uint64]uint64) (map[uint64]string, error) {
+	if !fld.StringKeys() || len(rows) == 0 {
+		return nil, nil
+	}
+	seen := make(map[uint64]struct{})
+	ids := make([]uint64, 0, len(rows))
+	for _, row := range rows {
+		if _, ok := seen[row]; ok {
+			continue
+		}
+		seen[row] = struct{}{}
+		ids = append(ids, row)
+	}
+	return i.planner.executor.TranslateFieldIDs(ctx, i.table.ID, fld.Name, ids)
+}
@@ -62,7 +62,7 @@ func (p *PlanOpTableValuedFunction) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpTableValuedFunction) String() string {
-	return ""
+	return fmt.Sprintf("TableValuedFunction(%s)", p.callExpr.String())
 }
 
 func (p *PlanOpTableValuedFunction) AddWarning(warning string) {
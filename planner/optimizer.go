@@ -0,0 +1,797 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// OptimizerFunc is a function that rewrites a plan. Like a PlanOpTransformFunc,
+// it returns the rewritten plan and a bool that is true if the plan was left
+// unchanged.
+type OptimizerFunc func(ctx context.Context, p *ExecutionPlanner, op types.PlanOperator) (types.PlanOperator, bool, error)
+
+// optimizerRule is a named rewrite rule applied by the optimizer
+type optimizerRule struct {
+	name string
+	fn   OptimizerFunc
+}
+
+// optimizerRules is the list of rules applied to a plan by the optimizer. The
+// rules are applied once each, in this order.
+var optimizerRules = []optimizerRule{
+	// subqueries are only a boundary for the compiler, so remove them
+	{"removeSubqueries", removeSubqueries},
+	// evaluate constant expressions once, and remove filters they decide
+	{"foldConstants", foldConstants},
+	// evaluate filters with bitmap operations in the table scans they apply to
+	{"pushdownFilters", pushdownFilters},
+	// read only the columns that are used from each table
+	{"pruneColumns", pruneColumns},
+	// join on equalities with a hash table rather than nested loops
+	{"hashJoins", hashJoins},
+	// compute ungrouped aggregates over a table scan from its bitmaps
+	{"pushdownAggregates", pushdownAggregates},
+}
+
+// OptimizerRules returns the names of the optimizer rules, in the order they
+// are applied
+func OptimizerRules() []string {
+	names := make([]string, len(optimizerRules))
+	for i, rule := range optimizerRules {
+		names[i] = rule.name
+	}
+	return names
+}
+
+// DisableOptimizerRules stops the optimizer applying the named rules to the
+// plans compiled by this planner
+func (p *ExecutionPlanner) DisableOptimizerRules(names ...string) error {
+	for _, name := range names {
+		found := false
+		for _, rule := range optimizerRules {
+			if rule.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return sql3.NewErrInternalf("unknown optimizer rule '%s'", name)
+		}
+		if p.disabledRules == nil {
+			p.disabledRules = make(map[string]struct{})
+		}
+		p.disabledRules[name] = struct{}{}
+	}
+	return nil
+}
+
+// optimizerStep records the plan as it was after an optimizer rule changed it
+type optimizerStep struct {
+	rule string
+	plan string
+}
+
+// optimizePlan applies the enabled optimizer rules to the plan rooted at op.
+// If trace is true, a step is returned for each rule that changed the plan.
+func (p *ExecutionPlanner) optimizePlan(ctx context.Context, op types.PlanOperator, trace bool) (types.PlanOperator, []*optimizerStep, error) {
+	var steps []*optimizerStep
+	for _, rule := range optimizerRules {
+		if _, ok := p.disabledRules[rule.name]; ok {
+			continue
+		}
+		result, same, err := rule.fn(ctx, p, op)
+		if err != nil {
+			return nil, nil, err
+		}
+		if same {
+			continue
+		}
+		op = result
+		if trace {
+			steps = append(steps, &optimizerStep{
+				rule: rule.name,
+				plan: explainTree(op),
+			})
+		}
+	}
+	return op, steps, nil
+}
+
+// removeSubqueries removes PlanOpSubquery operators, which pass the rows of
+// their child through unchanged
+func removeSubqueries(ctx context.Context, p *ExecutionPlanner, op types.PlanOperator) (types.PlanOperator, bool, error) {
+	return TransformPlanOp(op, func(op types.PlanOperator) (types.PlanOperator, bool, error) {
+		if subquery, ok := op.(*PlanOpSubquery); ok {
+			return subquery.ChildOp, false, nil
+		}
+		return op, true, nil
+	})
+}
+
+// foldConstants replaces expressions that only depend on literals with the
+// literal they evaluate to, and simplifies boolean expressions with literal
+// operands. A filter that is always true is removed, and a filter that is
+// never true is replaced by an empty relation.
+func foldConstants(ctx context.Context, p *ExecutionPlanner, op types.PlanOperator) (types.PlanOperator, bool, error) {
+	return TransformPlanOp(op, func(op types.PlanOperator) (types.PlanOperator, bool, error) {
+		op, same, err := TransformSinglePlanOpExpressions(op, foldConstantExpression, func(parentExpr, childExpr types.PlanExpression) bool {
+			return true
+		})
+		if err != nil {
+			return nil, true, err
+		}
+		switch o := op.(type) {
+		case *PlanOpFilter:
+			predicate, empty := foldPredicate(o.Predicate)
+			switch {
+			case empty:
+				return NewPlanOpEmptyRelation(o.Schema()), false, nil
+			case predicate == nil:
+				return o.ChildOp, false, nil
+			case predicate != o.Predicate:
+				return NewPlanOpFilter(o.planner, predicate, o.ChildOp), false, nil
+			}
+		case *PlanOpHaving:
+			predicate, empty := foldPredicate(o.Predicate)
+			switch {
+			case empty:
+				return NewPlanOpEmptyRelation(o.Schema()), false, nil
+			case predicate == nil:
+				return o.ChildOp, false, nil
+			case predicate != o.Predicate:
+				return NewPlanOpHaving(o.planner, predicate, o.ChildOp), false, nil
+			}
+		}
+		return op, same, nil
+	})
+}
+
+// foldPredicate removes the terms of a conjunction that are always true. It
+// returns the remaining terms, which are nil if there are none, or true if a
+// term is never true, so the predicate never is either.
+func foldPredicate(predicate types.PlanExpression) (types.PlanExpression, bool) {
+	terms := splitConjunction(predicate)
+	remaining := make([]types.PlanExpression, 0, len(terms))
+	for _, term := range terms {
+		switch t := term.(type) {
+		case *boolLiteralPlanExpression:
+			if !t.value {
+				return nil, true
+			}
+			continue
+		case *nullLiteralPlanExpression:
+			return nil, true
+		}
+		remaining = append(remaining, term)
+	}
+	if len(remaining) == len(terms) {
+		return predicate, false
+	}
+	return joinConjunction(remaining), false
+}
+
+// foldConstantExpression is an ExprFunc that replaces a constant expression
+// with its value, removes literal operands that don't change the result of
+// AND, OR and NOT, and replaces AND and OR with a literal operand that decides
+// their result
+func foldConstantExpression(expr types.PlanExpression) (types.PlanExpression, bool, error) {
+	if isConstantExpression(expr) && !isLiteralExpression(expr) {
+		// an expression that can't be evaluated is left to fail if it is
+		// evaluated when the plan is executed
+		value, err := expr.Evaluate(nil)
+		if err != nil {
+			return expr, true, nil
+		}
+		if literal, ok := newLiteralPlanExpression(value, expr.Type()); ok {
+			return literal, false, nil
+		}
+		return expr, true, nil
+	}
+
+	switch e := expr.(type) {
+	case *binOpPlanExpression:
+		// x AND TRUE is x, and x OR FALSE is x. x AND FALSE is FALSE, and
+		// x OR TRUE is TRUE, even when x is null.
+		var identity bool
+		switch e.op {
+		case parser.AND:
+			identity = true
+		case parser.OR:
+			identity = false
+		default:
+			return expr, true, nil
+		}
+		if lit, ok := e.lhs.(*boolLiteralPlanExpression); ok && typeIsBool(e.rhs.Type()) {
+			if lit.value == identity {
+				return e.rhs, false, nil
+			}
+			return lit, false, nil
+		}
+		if lit, ok := e.rhs.(*boolLiteralPlanExpression); ok && typeIsBool(e.lhs.Type()) {
+			if lit.value == identity {
+				return e.lhs, false, nil
+			}
+			return lit, false, nil
+		}
+
+	case *unaryOpPlanExpression:
+		// NOT NOT x is x
+		if inner, ok := e.rhs.(*unaryOpPlanExpression); ok && e.op == parser.NOT && inner.op == parser.NOT {
+			return inner.rhs, false, nil
+		}
+	}
+	return expr, true, nil
+}
+
+// isLiteralExpression returns true if expr is a literal
+func isLiteralExpression(expr types.PlanExpression) bool {
+	switch expr.(type) {
+	case *nullLiteralPlanExpression, *intLiteralPlanExpression, *floatLiteralPlanExpression,
+		*boolLiteralPlanExpression, *timestampLiteralPlanExpression, *stringLiteralPlanExpression:
+		return true
+	}
+	return false
+}
+
+// isConstantExpression returns true if expr only depends on literals, so it
+// has the same value for every row
+func isConstantExpression(expr types.PlanExpression) bool {
+	switch e := expr.(type) {
+	case *nullLiteralPlanExpression, *intLiteralPlanExpression, *floatLiteralPlanExpression,
+		*boolLiteralPlanExpression, *timestampLiteralPlanExpression, *stringLiteralPlanExpression:
+		return true
+
+	case *unaryOpPlanExpression, *binOpPlanExpression, *castPlanExpression,
+		*betweenOpPlanExpression, *rangePlanExpression, *inOpPlanExpression,
+		*casePlanExpression, *caseBlockPlanExpression, *exprListPlanExpression,
+		*exprSetLiteralPlanExpression, *exprTupleLiteralPlanExpression:
+
+	case *callPlanExpression:
+		// user defined functions may not be deterministic, and rangeq() can
+		// only be evaluated by a table scan
+		if e.udfReference != nil || strings.EqualFold(e.name, "RANGEQ") {
+			return false
+		}
+
+	default:
+		return false
+	}
+	for _, child := range expr.Children() {
+		if !isConstantExpression(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// newLiteralPlanExpression returns a literal for value with the data type
+// dataType, or false if there is no such literal
+func newLiteralPlanExpression(value interface{}, dataType parser.ExprDataType) (types.PlanExpression, bool) {
+	switch dt := dataType.(type) {
+	case *parser.DataTypeInt:
+		if v, ok := value.(int64); ok {
+			return newIntLiteralPlanExpression(v), true
+		}
+	case *parser.DataTypeBool:
+		if v, ok := value.(bool); ok {
+			return newBoolLiteralPlanExpression(v), true
+		}
+	case *parser.DataTypeString:
+		if v, ok := value.(string); ok {
+			return newStringLiteralPlanExpression(v), true
+		}
+	case *parser.DataTypeTimestamp:
+		if v, ok := value.(time.Time); ok {
+			return newTimestampLiteralPlanExpression(v), true
+		}
+	case *parser.DataTypeDecimal:
+		// a decimal literal is parsed when it is evaluated, which drops
+		// trailing zeros, so only values that survive that keep their scale
+		if v, ok := value.(decimal.Decimal); ok && v.Scale == dt.Scale {
+			if parsed, err := decimal.ParseDecimal(v.String()); err == nil && parsed.Scale == v.Scale {
+				return newFloatLiteralPlanExpression(v.String()), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// pushdownFilters moves the predicate of a PlanOpFilter into the relation it
+// filters if the relation can filter its own rows. Calls to rangeq() are
+// pushed down as time quantum filters. The terms of a predicate on a join that
+// reference the columns of only one of its inputs are pushed down into that
+// input, unless the join returns rows of the other input that match nothing.
+func pushdownFilters(ctx context.Context, p *ExecutionPlanner, op types.PlanOperator) (types.PlanOperator, bool, error) {
+	return TransformPlanOp(op, func(op types.PlanOperator) (types.PlanOperator, bool, error) {
+		filter, ok := op.(*PlanOpFilter)
+		if !ok {
+			return op, true, nil
+		}
+		result, pushed, err := pushFilter(p, filter.Predicate, filter.ChildOp)
+		if err != nil {
+			return nil, true, err
+		}
+		if !pushed {
+			return op, true, nil
+		}
+		return result, false, nil
+	})
+}
+
+// pushFilter returns child with its rows filtered by predicate, moving as much
+// of predicate as it can into child, or false if none of it could be moved
+func pushFilter(p *ExecutionPlanner, predicate types.PlanExpression, child types.PlanOperator) (types.PlanOperator, bool, error) {
+	if rel, ok := child.(types.FilteredRelation); ok && rel.IsFilterable() {
+		conditions := make([]types.PlanExpression, 0)
+		timeQuantumFilters := make([]types.PlanExpression, 0)
+		for _, term := range splitConjunction(predicate) {
+			if isRangeQFilter(term) {
+				timeQuantumFilters = append(timeQuantumFilters, term)
+			} else {
+				conditions = append(conditions, term)
+			}
+		}
+		result := child
+		if len(timeQuantumFilters) > 0 {
+			updated, err := rel.UpdateTimeQuantumFilters(timeQuantumFilters...)
+			if err != nil {
+				return nil, true, err
+			}
+			result = updated
+		}
+		if condition := joinConjunction(conditions); condition != nil {
+			updated, err := result.(types.FilteredRelation).UpdateFilters(condition)
+			if err != nil {
+				return nil, true, err
+			}
+			result = updated
+		}
+		return result, true, nil
+	}
+
+	join, ok := child.(*PlanOpNestedLoops)
+	if !ok {
+		return NewPlanOpFilter(p, predicate, child), false, nil
+	}
+	topWidth := len(join.top.Schema())
+	var topTerms, bottomTerms, remaining []types.PlanExpression
+	for _, term := range splitConjunction(predicate) {
+		top, bottom := joinSides(term, topWidth)
+		switch {
+		case top && !bottom && !join.jType.preservesBottom():
+			topTerms = append(topTerms, term)
+		case bottom && !top && !join.jType.preservesTop():
+			bottomTerms = append(bottomTerms, term)
+		default:
+			remaining = append(remaining, term)
+		}
+	}
+	if len(topTerms) == 0 && len(bottomTerms) == 0 {
+		return NewPlanOpFilter(p, predicate, child), false, nil
+	}
+
+	top := join.top
+	if len(topTerms) > 0 {
+		var err error
+		if top, _, err = pushFilter(p, joinConjunction(topTerms), top); err != nil {
+			return nil, true, err
+		}
+	}
+	bottom := join.bottom
+	if len(bottomTerms) > 0 {
+		// the columns of the bottom input follow those of the top input in
+		// the rows of the join
+		mapping := make([]int, topWidth+len(join.bottom.Schema()))
+		for i := range mapping {
+			mapping[i] = i - topWidth
+		}
+		condition, err := remapColumnRefs(joinConjunction(bottomTerms), mapping)
+		if err != nil {
+			return nil, true, err
+		}
+		if bottom, _, err = pushFilter(p, condition, bottom); err != nil {
+			return nil, true, err
+		}
+	}
+	result := NewPlanOpNestedLoops(top, bottom, join.jType, join.cond)
+	result.warnings = append(result.warnings, join.warnings...)
+	if condition := joinConjunction(remaining); condition != nil {
+		return NewPlanOpFilter(p, condition, result), true, nil
+	}
+	return result, true, nil
+}
+
+// pushdownAggregates replaces a PlanOpGroupBy with no group by expressions over
+// a table scan with a PlanOpBitmapAggregate, if all its aggregates and the
+// filters of the scan can be computed from bitmaps
+func pushdownAggregates(ctx context.Context, p *ExecutionPlanner, op types.PlanOperator) (types.PlanOperator, bool, error) {
+	return TransformPlanOp(op, func(op types.PlanOperator) (types.PlanOperator, bool, error) {
+		groupBy, ok := op.(*PlanOpGroupBy)
+		if !ok || len(groupBy.GroupByExprs) > 0 {
+			return op, true, nil
+		}
+		scan, ok := groupBy.ChildOp.(*PlanOpTableScan)
+		if !ok {
+			return op, true, nil
+		}
+		// leave any errors in the columns to the scan
+		fields, err := scan.fields(scan.table)
+		if err != nil {
+			return op, true, nil
+		}
+		for _, agg := range groupBy.Aggregates {
+			if _, ok := newBitmapAggregate(agg, fields); !ok {
+				return op, true, nil
+			}
+		}
+		_, filter, err := scan.bitmapFilters(ctx, scan.table, fields)
+		if err != nil {
+			return nil, true, err
+		}
+		if filter != nil {
+			return op, true, nil
+		}
+		return NewPlanOpBitmapAggregate(p, scan, groupBy.Aggregates), false, nil
+	})
+}
+
+// hashJoins replaces a PlanOpNestedLoops with a PlanOpHashJoin
+// if its condition is a conjunction that includes equalities between the top
+// and bottom inputs. The other terms of the conjunction are kept as the
+// condition of the hash join.
+func hashJoins(ctx context.Context, p *ExecutionPlanner, op types.PlanOperator) (types.PlanOperator, bool, error) {
+	return TransformPlanOp(op, func(op types.PlanOperator) (types.PlanOperator, bool, error) {
+		join, ok := op.(*PlanOpNestedLoops)
+		if !ok || join.cond == nil {
+			return op, true, nil
+		}
+		topWidth := len(join.top.Schema())
+		var topKeys, bottomKeys, residual []types.PlanExpression
+		for _, term := range splitConjunction(join.cond) {
+			topKey, bottomKey, ok := hashJoinKeys(term, topWidth)
+			if !ok {
+				residual = append(residual, term)
+				continue
+			}
+			topKeys = append(topKeys, topKey)
+			bottomKeys = append(bottomKeys, bottomKey)
+		}
+		if len(topKeys) == 0 {
+			return op, true, nil
+		}
+		result := NewPlanOpHashJoin(join.top, join.bottom, join.jType, topKeys, bottomKeys, joinConjunction(residual))
+		result.warnings = append(result.warnings, join.warnings...)
+		return result, false, nil
+	})
+}
+
+// hashJoinKeys returns the top and bottom sides of an equality between an
+// expression of the columns of the top input and an expression of the columns
+// of the bottom input, or false if term is not such an equality. The sides
+// must have types whose values are equal only if they are the same.
+func hashJoinKeys(term types.PlanExpression, topWidth int) (types.PlanExpression, types.PlanExpression, bool) {
+	eq, ok := term.(*binOpPlanExpression)
+	if !ok || eq.op != parser.EQ {
+		return nil, nil, false
+	}
+	lhs, rhs := eq.lhs.Type(), eq.rhs.Type()
+	switch {
+	case typeIsInteger(lhs) && typeIsInteger(rhs):
+	case typeIsString(lhs) && typeIsString(rhs):
+	case typeIsBool(lhs) && typeIsBool(rhs):
+	default:
+		return nil, nil, false
+	}
+	lhsTop, lhsBottom := joinSides(eq.lhs, topWidth)
+	rhsTop, rhsBottom := joinSides(eq.rhs, topWidth)
+	switch {
+	case lhsTop && !lhsBottom && rhsBottom && !rhsTop:
+		return eq.lhs, eq.rhs, true
+	case rhsTop && !rhsBottom && lhsBottom && !lhsTop:
+		return eq.rhs, eq.lhs, true
+	}
+	return nil, nil, false
+}
+
+// joinSides returns whether expr references columns of the top input and
+// columns of the bottom input of a join. A subquery is treated as referencing
+// both.
+func joinSides(expr types.PlanExpression, topWidth int) (top, bottom bool) {
+	InspectExpression(expr, func(expr types.PlanExpression) bool {
+		switch e := expr.(type) {
+		case *qualifiedRefPlanExpression:
+			if e.columnIndex < topWidth {
+				top = true
+			} else {
+				bottom = true
+			}
+		case *subqueryPlanExpression:
+			top, bottom = true, true
+		}
+		return true
+	})
+	return top, bottom
+}
+
+// pruneColumns narrows the columns read by each table scan to those that are
+// referenced by the operators above it, and re-maps the column indexes of the
+// references in those operators to match
+func pruneColumns(ctx context.Context, p *ExecutionPlanner, op types.PlanOperator) (types.PlanOperator, bool, error) {
+	pruner := &columnPruner{planner: p}
+	result, _, err := pruner.prune(op, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	return result, !pruner.changed, nil
+}
+
+// columnPruner removes unused columns from the table scans in a plan
+type columnPruner struct {
+	planner *ExecutionPlanner
+	changed bool
+}
+
+// prune removes the columns of the rows produced by op that are not in
+// required, which is nil if all the columns are needed. It returns the
+// rewritten operator and, if the columns of its rows changed, a mapping from
+// the index of each column to its new index, which is -1 for a removed column.
+func (c *columnPruner) prune(op types.PlanOperator, required map[int]struct{}) (types.PlanOperator, []int, error) {
+	switch o := op.(type) {
+	case *PlanOpTableScan:
+		filters := append([]types.PlanExpression{o.filter}, o.timeQuantumFilters...)
+		required = columnRefs(required, filters...)
+		if required == nil {
+			return op, nil, nil
+		}
+		keep := make([]int, 0, len(required))
+		for idx := range required {
+			keep = append(keep, idx)
+		}
+		sort.Ints(keep)
+		// rows always need a column, even if none are used
+		if len(keep) == 0 {
+			keep = append(keep, 0)
+		}
+		if len(keep) == len(o.columns) {
+			return op, nil, nil
+		}
+		mapping := make([]int, len(o.columns))
+		for i := range mapping {
+			mapping[i] = -1
+		}
+		columns := make([]string, len(keep))
+		for i, idx := range keep {
+			mapping[idx] = i
+			columns[i] = o.columns[idx]
+		}
+		scan := NewPlanOpTableScan(c.planner, o.table, columns)
+		scan.warnings = append(scan.warnings, o.warnings...)
+		var err error
+		if scan.filter, err = remapColumnRefs(o.filter, mapping); err != nil {
+			return nil, nil, err
+		}
+		for _, f := range o.timeQuantumFilters {
+			f, err = remapColumnRefs(f, mapping)
+			if err != nil {
+				return nil, nil, err
+			}
+			scan.timeQuantumFilters = append(scan.timeQuantumFilters, f)
+		}
+		c.changed = true
+		return scan, mapping, nil
+
+	case *PlanOpFilter:
+		child, mapping, err := c.prune(o.ChildOp, columnRefs(required, o.Predicate))
+		if err != nil {
+			return nil, nil, err
+		}
+		predicate, err := remapColumnRefs(o.Predicate, mapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewPlanOpFilter(o.planner, predicate, child), mapping, nil
+
+	case *PlanOpOrderBy:
+		exprs := make([]types.PlanExpression, len(o.orderByFields))
+		for i, f := range o.orderByFields {
+			exprs[i] = f.Expr
+		}
+		child, mapping, err := c.prune(o.ChildOp, columnRefs(required, exprs...))
+		if err != nil {
+			return nil, nil, err
+		}
+		fields := make([]*OrderByExpression, len(o.orderByFields))
+		for i, f := range o.orderByFields {
+			expr, err := remapColumnRefs(f.Expr, mapping)
+			if err != nil {
+				return nil, nil, err
+			}
+			fields[i] = &OrderByExpression{
+				Expr:         expr,
+				Order:        f.Order,
+				NullOrdering: f.NullOrdering,
+			}
+		}
+		return NewPlanOpOrderBy(fields, child), mapping, nil
+
+	case *PlanOpTop, *PlanOpRelAlias, *PlanOpSubquery:
+		// these pass the rows of their child through unchanged
+		child, mapping, err := c.prune(op.Children()[0], required)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := op.WithChildren(child)
+		return result, mapping, err
+
+	case *PlanOpProjection:
+		child, mapping, err := c.prune(o.ChildOp, columnRefs(map[int]struct{}{}, o.Projections...))
+		if err != nil {
+			return nil, nil, err
+		}
+		projections, err := remapColumnRefList(o.Projections, mapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		result := NewPlanOpProjection(projections, child)
+		result.warnings = append(result.warnings, o.warnings...)
+		return result, nil, nil
+
+	case *PlanOpGroupBy:
+		refs := columnRefs(map[int]struct{}{}, o.Aggregates...)
+		child, mapping, err := c.prune(o.ChildOp, columnRefs(refs, o.GroupByExprs...))
+		if err != nil {
+			return nil, nil, err
+		}
+		aggregates, err := remapColumnRefList(o.Aggregates, mapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		groupByExprs, err := remapColumnRefList(o.GroupByExprs, mapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewPlanOpGroupBy(aggregates, groupByExprs, child), nil, nil
+
+	case *PlanOpNestedLoops:
+		// the rows of a join are the columns of the top row followed by the
+		// columns of the bottom row
+		topWidth := len(o.top.Schema())
+		var topRequired, bottomRequired map[int]struct{}
+		if refs := columnRefs(required, o.cond); refs != nil {
+			topRequired = make(map[int]struct{})
+			bottomRequired = make(map[int]struct{})
+			for idx := range refs {
+				if idx < topWidth {
+					topRequired[idx] = struct{}{}
+				} else {
+					bottomRequired[idx-topWidth] = struct{}{}
+				}
+			}
+		}
+		top, topMapping, err := c.prune(o.top, topRequired)
+		if err != nil {
+			return nil, nil, err
+		}
+		bottom, bottomMapping, err := c.prune(o.bottom, bottomRequired)
+		if err != nil {
+			return nil, nil, err
+		}
+		var mapping []int
+		if topMapping != nil || bottomMapping != nil {
+			newTopWidth := len(top.Schema())
+			mapping = make([]int, topWidth+len(o.bottom.Schema()))
+			for i := range mapping {
+				switch {
+				case i >= topWidth && bottomMapping != nil:
+					mapping[i] = -1
+					if m := bottomMapping[i-topWidth]; m >= 0 {
+						mapping[i] = newTopWidth + m
+					}
+				case i >= topWidth:
+					mapping[i] = newTopWidth + i - topWidth
+				case topMapping != nil:
+					mapping[i] = topMapping[i]
+				default:
+					mapping[i] = i
+				}
+			}
+		}
+		cond, err := remapColumnRefs(o.cond, mapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		result := NewPlanOpNestedLoops(top, bottom, o.jType, cond)
+		result.warnings = append(result.warnings, o.warnings...)
+		return result, mapping, nil
+
+	default:
+		// anything else may need every column of its children
+		children := op.Children()
+		if len(children) == 0 {
+			return op, nil, nil
+		}
+		newChildren := make([]types.PlanOperator, len(children))
+		for i, child := range children {
+			newChild, _, err := c.prune(child, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			newChildren[i] = newChild
+		}
+		result, err := op.WithChildren(newChildren...)
+		return result, nil, err
+	}
+}
+
+// columnRefs adds the indexes of the columns referenced by exprs to required,
+// and returns the result. It returns nil if required is nil, or if any of the
+// expressions is a subquery, which may use any column.
+func columnRefs(required map[int]struct{}, exprs ...types.PlanExpression) map[int]struct{} {
+	if required == nil {
+		return nil
+	}
+	result := make(map[int]struct{}, len(required))
+	for idx := range required {
+		result[idx] = struct{}{}
+	}
+	all := false
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		InspectExpression(expr, func(expr types.PlanExpression) bool {
+			switch e := expr.(type) {
+			case *qualifiedRefPlanExpression:
+				result[e.columnIndex] = struct{}{}
+			case *subqueryPlanExpression:
+				all = true
+			}
+			return !all
+		})
+	}
+	if all {
+		return nil
+	}
+	return result
+}
+
+// remapColumnRefs returns expr with the column indexes of its references
+// changed according to mapping, which is nil if they are unchanged
+func remapColumnRefs(expr types.PlanExpression, mapping []int) (types.PlanExpression, error) {
+	if expr == nil || mapping == nil {
+		return expr, nil
+	}
+	result, _, err := TransformExpr(expr, func(expr types.PlanExpression) (types.PlanExpression, bool, error) {
+		ref, ok := expr.(*qualifiedRefPlanExpression)
+		if !ok {
+			return expr, true, nil
+		}
+		if ref.columnIndex < 0 || ref.columnIndex >= len(mapping) || mapping[ref.columnIndex] < 0 {
+			return nil, true, sql3.NewErrInternalf("unable to to find column '%d' in currentColumns", ref.columnIndex)
+		}
+		return newQualifiedRefPlanExpression(ref.tableName, ref.columnName, mapping[ref.columnIndex], ref.dataType), false, nil
+	}, func(parentExpr, childExpr types.PlanExpression) bool {
+		return true
+	})
+	return result, err
+}
+
+func remapColumnRefList(exprs []types.PlanExpression, mapping []int) ([]types.PlanExpression, error) {
+	result := make([]types.PlanExpression, len(exprs))
+	for i, expr := range exprs {
+		var err error
+		if result[i], err = remapColumnRefs(expr, mapping); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -60,7 +60,7 @@ func (p *PlanOpTop) Plan() map[string]interface{} {
 }
 
 func (p *PlanOpTop) String() string {
-	return ""
+	return fmt.Sprintf("Top(%s)", p.expr.String())
 }
 
 func (p *PlanOpTop) AddWarning(warning string) {
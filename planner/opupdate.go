@@ -0,0 +1,248 @@
+// Copyright 2022 Molecula Corp. All rights reserved.
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner/types"
+)
+
+// PlanOpUpdate plan operator to update the records returned by its child. The
+// values assigned are evaluated against each row of the child. It returns a
+// single row containing the number of records updated.
+type PlanOpUpdate struct {
+	planner       *ExecutionPlanner
+	tableName     string
+	targetColumns []*qualifiedRefPlanExpression
+	updateValues  []types.PlanExpression
+	ChildOp       types.PlanOperator
+	warnings      []string
+}
+
+func NewPlanOpUpdate(p *ExecutionPlanner, tableName string, targetColumns []*qualifiedRefPlanExpression, updateValues []types.PlanExpression, child types.PlanOperator) *PlanOpUpdate {
+	return &PlanOpUpdate{
+		planner:       p,
+		tableName:     tableName,
+		targetColumns: targetColumns,
+		updateValues:  updateValues,
+		ChildOp:       child,
+		warnings:      make([]string, 0),
+	}
+}
+
+func (p *PlanOpUpdate) Plan() map[string]interface{} {
+	result := make(map[string]interface{})
+	result["_op"] = fmt.Sprintf("%T", p)
+	result["_schema"] = p.Schema().Plan()
+	result["tableName"] = p.tableName
+	ps := make([]interface{}, 0)
+	for _, e := range p.targetColumns {
+		ps = append(ps, e.Plan())
+	}
+	result["targetColumns"] = ps
+	ps = make([]interface{}, 0)
+	for _, e := range p.updateValues {
+		ps = append(ps, e.Plan())
+	}
+	result["updateValues"] = ps
+	result["child"] = p.ChildOp.Plan()
+	return result
+}
+
+func (p *PlanOpUpdate) String() string {
+	assignments := make([]string, len(p.targetColumns))
+	for i, c := range p.targetColumns {
+		assignments[i] = fmt.Sprintf("%s = %s", c.columnName, p.updateValues[i].String())
+	}
+	return fmt.Sprintf("Update(%s; set %s)", p.tableName, strings.Join(assignments, ", "))
+}
+
+func (p *PlanOpUpdate) AddWarning(warning string) {
+	p.warnings = append(p.warnings, warning)
+}
+
+func (p *PlanOpUpdate) Warnings() []string {
+	var w []string
+	w = append(w, p.warnings...)
+	w = append(w, p.ChildOp.Warnings()...)
+	return w
+}
+
+func (p *PlanOpUpdate) Schema() types.Schema {
+	return types.Schema{
+		&types.PlannerColumn{
+			ColumnName:   "count",
+			RelationName: "",
+			Type:         parser.NewDataTypeInt(),
+		},
+	}
+}
+
+func (p *PlanOpUpdate) Children() []types.PlanOperator {
+	return []types.PlanOperator{
+		p.ChildOp,
+	}
+}
+
+func (p *PlanOpUpdate) Expressions() []types.PlanExpression {
+	return p.updateValues
+}
+
+func (p *PlanOpUpdate) WithUpdatedExpressions(exprs ...types.PlanExpression) (types.PlanOperator, error) {
+	if len(exprs) != len(p.updateValues) {
+		return nil, sql3.NewErrInternalf("unexpected number of exprs '%d'", len(exprs))
+	}
+	op := NewPlanOpUpdate(p.planner, p.tableName, p.targetColumns, exprs, p.ChildOp)
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+func (p *PlanOpUpdate) Iterator(ctx context.Context, row types.Row) (types.RowIterator, error) {
+	iter, err := p.ChildOp.Iterator(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	return &updateRowIter{
+		planner:       p.planner,
+		tableName:     p.tableName,
+		targetColumns: p.targetColumns,
+		updateValues:  p.updateValues,
+		schema:        p.ChildOp.Schema(),
+		child:         iter,
+		batched:       readsTableOnce(p),
+	}, nil
+}
+
+// readsTableOnce returns true if op reads a single table scan and has no
+// subqueries. Each record is then read once, before it is written, so writing
+// the records read so far can't change the rows still to be read.
+func readsTableOnce(op types.PlanOperator) bool {
+	scans := 0
+	subqueries := false
+	isSubquery := func(expr types.PlanExpression) bool {
+		if _, ok := expr.(*subqueryPlanExpression); ok {
+			subqueries = true
+			return false
+		}
+		return true
+	}
+	InspectPlan(op, func(child types.PlanOperator) bool {
+		switch child := child.(type) {
+		case *PlanOpTableScan:
+			scans++
+			if child.filter != nil {
+				InspectExpression(child.filter, isSubquery)
+			}
+		case *PlanOpSubquery:
+			subqueries = true
+		}
+		return true
+	})
+	InspectOperatorExpressions(op, isSubquery)
+	return scans == 1 && !subqueries
+}
+
+func (p *PlanOpUpdate) WithChildren(children ...types.PlanOperator) (types.PlanOperator, error) {
+	if len(children) != 1 {
+		return nil, sql3.NewErrInternalf("unexpected number of children '%d'", len(children))
+	}
+	op := NewPlanOpUpdate(p.planner, p.tableName, p.targetColumns, p.updateValues, children[0])
+	op.warnings = append(op.warnings, p.warnings...)
+	return op, nil
+}
+
+type updateRowIter struct {
+	planner       *ExecutionPlanner
+	tableName     string
+	targetColumns []*qualifiedRefPlanExpression
+	updateValues  []types.PlanExpression
+	schema        types.Schema
+	child         types.RowIterator
+	// whether the updates can be written in batches while the rows are read
+	batched bool
+	done    bool
+}
+
+var _ types.RowIterator = (*updateRowIter)(nil)
+
+func (i *updateRowIter) Next(ctx context.Context) (types.Row, error) {
+	if i.done {
+		return nil, types.ErrNoMoreRows
+	}
+	i.done = true
+
+	tbl, err := i.planner.schemaAPI.TableByName(ctx, dax.TableName(i.tableName))
+	if err != nil {
+		if isTableNotFoundError(err) {
+			return nil, sql3.NewErrTableNotFound(0, 0, i.tableName)
+		}
+		return nil, err
+	}
+
+	idIndex := -1
+	for idx, col := range i.schema {
+		if strings.EqualFold(col.ColumnName, string(dax.PrimaryKeyFieldName)) {
+			idIndex = idx
+			break
+		}
+	}
+	if idIndex < 0 {
+		return nil, sql3.NewErrInternalf("unable to find '%s' in update source", dax.PrimaryKeyFieldName)
+	}
+
+	// records are written as an insert of _id and the assigned columns
+	idColumn := newQualifiedRefPlanExpression(i.tableName, string(dax.PrimaryKeyFieldName), 0, i.schema[idIndex].Type)
+	target, err := newInsertTarget(tbl, append([]*qualifiedRefPlanExpression{idColumn}, i.targetColumns...))
+	if err != nil {
+		return nil, err
+	}
+	rowTypes := []parser.ExprDataType{idColumn.dataType}
+	for _, v := range i.updateValues {
+		rowTypes = append(rowTypes, v.Type())
+	}
+
+	// the updates are written in batches as the rows are read when that
+	// can't affect the rows still to be read, otherwise they are all collected
+	// before writing
+	batch := newImportBatch(i.planner, tbl, target.fields, true)
+	count := int64(0)
+	for {
+		row, err := i.child.Next(ctx)
+		if err == types.ErrNoMoreRows {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		count++
+
+		updated := make([]interface{}, 1+len(i.updateValues))
+		updated[0] = row[idIndex]
+		for j, v := range i.updateValues {
+			updated[j+1], err = v.Evaluate(row)
+			if err != nil {
+				return nil, err
+			}
+		}
+		id, values, err := target.record(updated, rowTypes, int(count))
+		if err != nil {
+			return nil, err
+		}
+		batch.add(id, values)
+
+		if i.batched && batch.len() >= insertBatchSize {
+			if err := batch.flush(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
+	}
+	return types.Row{count}, nil
+}
@@ -0,0 +1,1321 @@
+package planner_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gernest/sql3"
+	"github.com/gernest/sql3/api"
+	"github.com/gernest/sql3/dax"
+	"github.com/gernest/sql3/decimal"
+	"github.com/gernest/sql3/memory"
+	"github.com/gernest/sql3/parser"
+	"github.com/gernest/sql3/planner"
+	"github.com/gernest/sql3/planner/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// execSQL compiles and runs a single statement against e, returning all the
+// rows it produced.
+func execSQL(t *testing.T, e *memory.Engine, sql string, disabledRules ...string) ([]types.Row, error) {
+	t.Helper()
+	ctx := context.Background()
+	stmt, err := parser.NewParser(strings.NewReader(sql)).ParseStatement()
+	if err != nil {
+		return nil, err
+	}
+	p := planner.NewExecutionPlanner(e, e, e, e, e, *slog.Default(), sql)
+	if err := p.DisableOptimizerRules(disabledRules...); err != nil {
+		return nil, err
+	}
+	op, err := p.CompilePlan(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := op.Iterator(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]types.Row, 0)
+	for {
+		row, err := iter.Next(ctx)
+		if err == types.ErrNoMoreRows {
+			return rows, nil
+		} else if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func mustExecSQL(t *testing.T, e *memory.Engine, sql string) []types.Row {
+	t.Helper()
+	rows, err := execSQL(t, e, sql)
+	require.NoError(t, err, sql)
+	return rows
+}
+
+func createTestTable(t *testing.T, e *memory.Engine, name string, stringKeys bool, fields ...*dax.Field) {
+	t.Helper()
+	tbl := dax.NewTable(dax.TableName(name))
+	var idType dax.BaseType = dax.BaseTypeID
+	if stringKeys {
+		idType = dax.BaseTypeString
+	}
+	tbl.Fields = append([]*dax.Field{{Name: dax.PrimaryKeyFieldName, Type: idType}}, fields...)
+	require.NoError(t, e.CreateTable(context.Background(), tbl))
+}
+
+func TestInsert(t *testing.T) {
+	e := memory.New()
+	createTestTable(t, e, "t", false,
+		&dax.Field{Name: "i", Type: dax.BaseTypeInt, Options: dax.FieldOptions{Min: -10, Max: 100}},
+		&dax.Field{Name: "s", Type: dax.BaseTypeString},
+		&dax.Field{Name: "ss", Type: dax.BaseTypeStringSet},
+		&dax.Field{Name: "b", Type: dax.BaseTypeBool},
+	)
+
+	mustExecSQL(t, e, `insert into t (_id, i, s, ss, b) values (1, 10, 'a', ['x', 'y'], true), (2, -3, 'b', ['z'], false)`)
+	rows := mustExecSQL(t, e, `select _id, i, s, ss, b from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), int64(10), "a", []string{"x", "y"}, true},
+		{int64(2), int64(-3), "b", []string{"z"}, false},
+	}, rows)
+
+	// insert adds to sets, replace overwrites them
+	mustExecSQL(t, e, `insert into t (_id, s, ss) values (1, 'c', ['w'])`)
+	mustExecSQL(t, e, `replace into t (_id, ss) values (2, ['v'])`)
+	rows = mustExecSQL(t, e, `select _id, s, ss from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), "c", []string{"w", "x", "y"}},
+		{int64(2), "b", []string{"v"}},
+	}, rows)
+
+	// the last row for a record wins when it is replaced more than once
+	mustExecSQL(t, e, `replace into t (_id, s, ss) values (2, 'd', ['p']), (2, 'e', ['q']), (1, null, ['r']), (1, 'f', null)`)
+	rows = mustExecSQL(t, e, `select _id, s, ss from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), "f", nil},
+		{int64(2), "e", []string{"q"}},
+	}, rows)
+
+	// null clears a value
+	mustExecSQL(t, e, `insert into t (_id, i) values (2, null)`)
+	rows = mustExecSQL(t, e, `select _id, i from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), int64(10)},
+		{int64(2), nil},
+	}, rows)
+
+	// the cost of replacing a value doesn't depend on how large the values are
+	mustExecSQL(t, e, `create table ids (_id id, v id)`)
+	mustExecSQL(t, e, `insert into ids (_id, v) values (1, 20000000), (2, 3)`)
+	mustExecSQL(t, e, `insert into ids (_id, v) values (1, 4)`)
+	rows = mustExecSQL(t, e, `select _id, v from ids`)
+	assert.Equal(t, []types.Row{
+		{int64(1), int64(4)},
+		{int64(2), int64(3)},
+	}, rows)
+
+	// a shard is read in chunks of columns
+	mustExecSQL(t, e, `insert into ids (_id, v) values (70000, 5), (200000, 6), (200001, null)`)
+	rows = mustExecSQL(t, e, `select _id, v from ids where _id > 1`)
+	assert.Equal(t, []types.Row{
+		{int64(2), int64(3)},
+		{int64(70000), int64(5)},
+		{int64(200000), int64(6)},
+		{int64(200001), nil},
+	}, rows)
+
+	for _, sql := range []string{
+		`insert into nope (_id, i) values (1, 1)`,
+		`insert into t (i) values (1)`,
+		`insert into t (_id) values (1)`,
+		`insert into t (_id, i) values (1)`,
+		`insert into t (_id, i) values (1, 'a')`,
+		`insert into t (_id, nope) values (1, 1)`,
+		`insert into t (_id, i) values (1, 1000)`,
+	} {
+		_, err := execSQL(t, e, sql)
+		assert.Error(t, err, sql)
+	}
+}
+
+func TestInsertStringKeys(t *testing.T) {
+	e := memory.New()
+	createTestTable(t, e, "t", true,
+		&dax.Field{Name: "s", Type: dax.BaseTypeString},
+	)
+	mustExecSQL(t, e, `insert into t values ('a', 'x'), ('b', 'y'), ('a', 'z')`)
+	rows := mustExecSQL(t, e, `select _id, s from t`)
+	assert.ElementsMatch(t, []types.Row{
+		{"a", "z"},
+		{"b", "y"},
+	}, rows)
+}
+
+func TestScalarFunctions(t *testing.T) {
+	e := memory.New()
+	createTestTable(t, e, "t", false,
+		&dax.Field{Name: "s", Type: dax.BaseTypeString},
+	)
+	mustExecSQL(t, e, `insert into t (_id, s) values (1, 'Ab'), (2, null)`)
+
+	rows := mustExecSQL(t, e, `select _id, upper(s), lower(s), reverse(s) from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), "AB", "ab", "bA"},
+		{int64(2), nil, nil, nil},
+	}, rows)
+
+	_, err := execSQL(t, e, `select nope(s) from t`)
+	assert.ErrorIs(t, err, sql3.ErrCallUnknownFunction)
+	assert.Contains(t, err.Error(), "[1:8]")
+}
+
+func TestBulkInsertCSV(t *testing.T) {
+	e := memory.New()
+	createTestTable(t, e, "t", false,
+		&dax.Field{Name: "i", Type: dax.BaseTypeInt},
+		&dax.Field{Name: "s", Type: dax.BaseTypeString},
+		&dax.Field{Name: "ids", Type: dax.BaseTypeIDSet},
+	)
+
+	mustExecSQL(t, e, `bulk insert into t (_id, i, s, ids)
+		map (0 id, 1 int, 2 string, 3 idset)
+		from x'_id,i,s,ids
+1,10,a,"1,2"
+2,20,b,3'
+		with format 'CSV' input 'STREAM' header_row batchsize 1`)
+	rows := mustExecSQL(t, e, `select _id, i, s, ids from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), int64(10), "a", []int64{1, 2}},
+		{int64(2), int64(20), "b", []int64{3}},
+	}, rows)
+
+	// transforms are evaluated over the mapped values
+	path := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(path, []byte("x,3,5\ny,4\nz,5,6\n"), 0o600))
+	mustExecSQL(t, e, `bulk replace into t (_id, s, i)
+		map (0 string, 1 id, 2 int)
+		transform (@1, upper(@0), @2)
+		from '`+path+`'
+		with format 'CSV' input 'FILE' allow_missing_values rowslimit 2`)
+	rows = mustExecSQL(t, e, `select _id, i, s from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), int64(10), "a"},
+		{int64(2), int64(20), "b"},
+		{int64(3), int64(5), "X"},
+		{int64(4), nil, "Y"},
+	}, rows)
+
+	// errors in the data are found while reading it, and have no position
+	for _, tc := range []struct {
+		sql string
+		err error
+		pos string
+	}{
+		{`bulk insert into t (_id, i) map (0 id, 1 int) from 'x' with format 'XML' input 'STREAM'`, sql3.ErrInvalidFormatSpecifier, "[1:68]"},
+		{`bulk insert into t (_id, i) map (0 id, 1 int) from 'x' with format 'CSV' input 'CARRIER PIGEON'`, sql3.ErrInvalidFormatSpecifier, "[1:80]"},
+		{`bulk insert into t (_id, i) map (0 id, 1 int) from 'x' with format 'CSV' input 'STREAM' batchsize 0`, sql3.ErrInvalidBatchSize, "[1:99]"},
+		{`bulk insert into t (_id, i) map (0 id, 1 string) from 'x' with format 'CSV' input 'STREAM'`, sql3.ErrTypeAssignmentIncompatible, "[1:40]"},
+		{`bulk insert into t (_id, i) map (0 id) from 'x' with format 'CSV' input 'STREAM'`, sql3.ErrInsertExprTargetCountMismatch, "[1:33]"},
+		{`bulk insert into t (_id, i) map (0 id, 1 int) from '1,a' with format 'CSV' input 'STREAM'`, sql3.ErrTypeConversionOnMap, ""},
+		{`bulk insert into t (_id, i) map (0 id, 1 int) from '1' with format 'CSV' input 'STREAM'`, sql3.ErrMappingFromDatasource, ""},
+		{`bulk insert into t (_id, i) map (0 id, 1 int) from '/does/not/exist' with format 'CSV' input 'FILE'`, sql3.ErrReadingDatasource, ""},
+	} {
+		_, err := execSQL(t, e, tc.sql)
+		if assert.ErrorIs(t, err, tc.err, tc.sql) && tc.pos != "" {
+			assert.Contains(t, err.Error(), tc.pos, tc.sql)
+		}
+	}
+}
+
+func TestBulkInsertNDJSON(t *testing.T) {
+	e := memory.New()
+	createTestTable(t, e, "t", true,
+		&dax.Field{Name: "i", Type: dax.BaseTypeInt, Options: dax.FieldOptions{Min: 0, Max: 100}},
+		&dax.Field{Name: "d", Type: dax.BaseTypeDecimal, Options: dax.FieldOptions{Scale: 2}},
+		&dax.Field{Name: "ids", Type: dax.BaseTypeIDSet},
+		&dax.Field{Name: "tags", Type: dax.BaseTypeStringSet},
+	)
+
+	mustExecSQL(t, e, `bulk insert into t (_id, i, d, ids, tags)
+		map ('$.id' string, '$.stats.count' int, '$.stats.price' decimal(2), '$.ids' idset, '$.items[*].tag' stringset)
+		from x'{"id": "a", "stats": {"count": 3, "price": 1.25}, "ids": [1, 2], "items": [{"tag": "x"}, {"tag": "y"}]}
+
+{"id": "b", "stats": {"count": 4, "price": 2}, "ids": [3], "items": []}'
+		with format 'NDJSON' input 'STREAM'`)
+	rows := mustExecSQL(t, e, `select _id, i, d, ids, tags from t`)
+	assert.ElementsMatch(t, []types.Row{
+		{"a", int64(3), decimal.NewDecimal(125, 2), []int64{1, 2}, []string{"x", "y"}},
+		{"b", int64(4), decimal.NewDecimal(200, 2), []int64{3}, nil},
+	}, rows)
+
+	// missing values are nulls when allowed
+	mustExecSQL(t, e, `bulk insert into t (_id, i) map ('$.id' string, '$.count' int)
+		from '{"id": "c"}' with format 'NDJSON' input 'STREAM' allow_missing_values`)
+
+	// errors in the data are found while reading it, and have no position
+	for _, tc := range []struct {
+		sql string
+		err error
+		pos string
+	}{
+		{`bulk insert into t (_id, i) map ('$.id' string, '$.count' int) from '{"id": "c"}' with format 'NDJSON' input 'STREAM'`, sql3.ErrEvaluatingJSONPathExpr, ""},
+		{`bulk insert into t (_id, i) map ('$.id' string, '$.count' int) from '{"id": "c", "count": 1000}' with format 'NDJSON' input 'STREAM'`, sql3.ErrInsertValueOutOfRange, ""},
+		{`bulk insert into t (_id, i) map ('$.id' string, '$.count' int) from '{"id": "c", "count": 1.5}' with format 'NDJSON' input 'STREAM'`, sql3.ErrTypeConversionOnMap, ""},
+		{`bulk insert into t (_id, i) map ('$.id' string, '$.count' int) from '{"id": ' with format 'NDJSON' input 'STREAM'`, sql3.ErrParsingJSON, ""},
+		{`bulk insert into t (_id, i) map ('$.id' string, '$[' int) from '{}' with format 'NDJSON' input 'STREAM'`, sql3.ErrEvaluatingJSONPathExpr, "[1:49]"},
+		{`bulk insert into t (_id, i) map (0 string, 1 int) from '{}' with format 'NDJSON' input 'STREAM'`, sql3.ErrStringLiteral, "[1:34]"},
+		{`bulk insert into t (_id, i) map ('$.id' string, '$.count' int) from '{}' with format 'NDJSON' input 'STREAM' header_row`, sql3.ErrUnknownIdentifier, "[1:110]"},
+	} {
+		_, err := execSQL(t, e, tc.sql)
+		if assert.ErrorIs(t, err, tc.err, tc.sql) && tc.pos != "" {
+			assert.Contains(t, err.Error(), tc.pos, tc.sql)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	e := memory.New()
+	createTestTable(t, e, "t", true,
+		&dax.Field{Name: "i", Type: dax.BaseTypeInt},
+		&dax.Field{Name: "ss", Type: dax.BaseTypeStringSetQ, Options: dax.FieldOptions{TimeQuantum: "YMD"}},
+	)
+	mustExecSQL(t, e, `insert into t (_id, i, ss) values ('a', 1, {'2022-01-02T00:00:00Z', ['x']}), ('b', 2, ['y']), ('c', 3, ['z'])`)
+
+	rows := mustExecSQL(t, e, `delete from t where i < 3`)
+	assert.Equal(t, []types.Row{{int64(2)}}, rows)
+	rows = mustExecSQL(t, e, `select _id, i, ss from t`)
+	assert.Equal(t, []types.Row{{"c", int64(3), []string{"z"}}}, rows)
+
+	// deleted records are gone from time views too
+	tbl, err := e.TableByName(context.Background(), "t")
+	require.NoError(t, err)
+	err = e.View(context.Background(), tbl.ID, 0, func(tx api.Tx) error {
+		views, err := api.FieldViews(tx, "ss")
+		require.NoError(t, err)
+		assert.Contains(t, views, "standard_20220102")
+		for _, view := range views {
+			bm, err := tx.RoaringBitmap(api.BitmapName("ss", view))
+			require.NoError(t, err)
+			assert.Equal(t, view == "standard", bm.Any(), view)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the records are found through filters and aliases over the scan
+	mustExecSQL(t, e, `insert into t (_id, i) values ('d', 4), ('e', 5)`)
+	rows, err = execSQL(t, e, `delete from t where _id = 'd'`, planner.OptimizerRules()...)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Row{{int64(1)}}, rows)
+	rows = mustExecSQL(t, e, `explain analyze delete from t as q where q.i = 5`)
+	assert.Contains(t, rows[0][0], "Delete(t)")
+	rows = mustExecSQL(t, e, `select _id from t`)
+	assert.Equal(t, []types.Row{{"c"}}, rows)
+
+	rows = mustExecSQL(t, e, `delete from t`)
+	assert.Equal(t, []types.Row{{int64(1)}}, rows)
+	rows = mustExecSQL(t, e, `select _id from t`)
+	assert.Empty(t, rows)
+
+	_, err = execSQL(t, e, `delete from t where i`)
+	assert.Error(t, err)
+	_, err = execSQL(t, e, `delete from nope`)
+	assert.Error(t, err)
+}
+
+func TestUpdate(t *testing.T) {
+	e := memory.New()
+	createTestTable(t, e, "t", false,
+		&dax.Field{Name: "i", Type: dax.BaseTypeInt},
+		&dax.Field{Name: "s", Type: dax.BaseTypeString},
+		&dax.Field{Name: "ss", Type: dax.BaseTypeStringSet},
+	)
+	mustExecSQL(t, e, `insert into t (_id, i, s, ss) values (1, 1, 'a', ['x', 'y']), (2, 2, 'b', ['y']), (3, 3, 'c', ['z'])`)
+
+	rows := mustExecSQL(t, e, `update t set i = i + 10, s = upper(s), ss = ['w'] where i < 3`)
+	assert.Equal(t, []types.Row{{int64(2)}}, rows)
+	rows = mustExecSQL(t, e, `select _id, i, s, ss from t`)
+	assert.Equal(t, []types.Row{
+		{int64(1), int64(11), "A", []string{"w"}},
+		{int64(2), int64(12), "B", []string{"w"}},
+		{int64(3), int64(3), "c", []string{"z"}},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `update t set s = null`)
+	assert.Equal(t, []types.Row{{int64(3)}}, rows)
+	rows = mustExecSQL(t, e, `select _id, s from t where _id = 3`)
+	assert.Equal(t, []types.Row{{int64(3), nil}}, rows)
+
+	// large updates are written in batches
+	mustExecSQL(t, e, `create table u (_id id, v int)`)
+	values := make([]string, 0)
+	for id := 1; id <= 2500; id++ {
+		values = append(values, fmt.Sprintf("(%d, %d)", id, id))
+	}
+	mustExecSQL(t, e, `insert into u (_id, v) values `+strings.Join(values, ", "))
+	rows = mustExecSQL(t, e, `update u set v = v * 2`)
+	assert.Equal(t, []types.Row{{int64(2500)}}, rows)
+	rows = mustExecSQL(t, e, `select count(*), sum(v) from u where v % 2 = 0`)
+	assert.Equal(t, []types.Row{{int64(2500), int64(2500 * 2501)}}, rows)
+
+	_, err := execSQL(t, e, `update t set _id = 4`)
+	assert.ErrorIs(t, err, sql3.ErrUnknownIdentifier)
+	assert.Contains(t, err.Error(), "[1:14] updating the '_id' column is not supported")
+	_, err = execSQL(t, e, `update t set nope = 1`)
+	assert.ErrorIs(t, err, sql3.ErrColumnNotFound)
+	assert.Contains(t, err.Error(), "[1:14]")
+	_, err = execSQL(t, e, `update t set i = 'a'`)
+	assert.ErrorIs(t, err, sql3.ErrTypeAssignmentIncompatible)
+	assert.Contains(t, err.Error(), "[1:18]")
+	_, err = execSQL(t, e, `update t set i = 1, i = 2`)
+	assert.ErrorIs(t, err, sql3.ErrDuplicateColumn)
+	assert.Contains(t, err.Error(), "[1:21]")
+}
+
+func TestCreateTable(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table T (
+		_id string,
+		i int min -10 max 100,
+		d decimal(2) min 1.5 max 20,
+		s string cachetype ranked size 1000,
+		ids idset cachetype lru,
+		ssq stringsetq timequantum 'YMD' ttl '24h',
+		ts timestamp timeunit 'ms',
+		b bool
+	) keypartitions 12 comment 'test table'`)
+
+	tbl, err := e.TableByName(context.Background(), "t")
+	require.NoError(t, err)
+	assert.True(t, tbl.StringKeys())
+	assert.Equal(t, 12, tbl.PartitionN)
+	assert.Equal(t, "test table", tbl.Description)
+	assert.Equal(t, []dax.FieldName{"_id", "i", "d", "s", "ids", "ssq", "ts", "b"}, tbl.FieldNames())
+
+	fld, _ := tbl.Field("i")
+	assert.Equal(t, int64(-10), fld.Options.Min)
+	assert.Equal(t, int64(100), fld.Options.Max)
+	fld, _ = tbl.Field("d")
+	assert.Equal(t, int64(2), fld.Options.Scale)
+	assert.Equal(t, int64(150), fld.Options.Min)
+	assert.Equal(t, int64(2000), fld.Options.Max)
+	fld, _ = tbl.Field("s")
+	assert.Equal(t, "ranked", fld.Options.CacheType)
+	assert.Equal(t, uint32(1000), fld.Options.CacheSize)
+	fld, _ = tbl.Field("ids")
+	assert.Equal(t, "lru", fld.Options.CacheType)
+	assert.Equal(t, uint32(50000), fld.Options.CacheSize)
+	fld, _ = tbl.Field("ssq")
+	assert.Equal(t, dax.BaseType(dax.BaseTypeStringSetQ), fld.Type)
+	assert.Equal(t, dax.TimeQuantum("YMD"), fld.Options.TimeQuantum)
+	assert.Equal(t, 24*time.Hour, fld.Options.TTL)
+	fld, _ = tbl.Field("ts")
+	assert.Equal(t, "ms", fld.Options.TimeUnit)
+
+	mustExecSQL(t, e, `insert into t (_id, i) values ('a', 1)`)
+	_, err = execSQL(t, e, `insert into t (_id, i) values ('b', 101)`)
+	assert.Error(t, err)
+
+	// existing tables
+	_, err = execSQL(t, e, `create table t (_id id, i int)`)
+	assert.ErrorContains(t, err, "table or view 't' already exists")
+	mustExecSQL(t, e, `create table if not exists t (_id id, i int)`)
+
+	for _, sql := range []string{
+		`create table e (i int)`,
+		`create table e (_id int, i int)`,
+		`create table e (_id id min 0, i int)`,
+		`create table e (_id id, i int, i string)`,
+		`create table e (_id id, s string min 0)`,
+		`create table e (_id id, ss stringset timequantum 'YMD')`,
+		`create table e (_id id, ssq stringsetq timequantum 'YQ')`,
+		`create table e (_id id, ts timestamp timeunit 'h')`,
+		`create table e (_id id, i int cachetype ranked)`,
+		`create table e (_id id, i int min 10 max 1)`,
+		`create table e (_id id, i int min 1 min 2)`,
+		`create table e (_id id, i int) keypartitions 0`,
+	} {
+		_, err := execSQL(t, e, sql)
+		assert.Error(t, err, sql)
+	}
+	_, err = e.TableByName(context.Background(), "e")
+	assert.Error(t, err)
+}
+
+func TestAlterTable(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, i int, s string)`)
+	mustExecSQL(t, e, `insert into t (_id, i, s) values (1, 10, 'a')`)
+
+	mustExecSQL(t, e, `alter table t add column d decimal(2) min 0 max 100`)
+	mustExecSQL(t, e, `insert into t (_id, d) values (1, 1.25)`)
+	rows := mustExecSQL(t, e, `select _id, i, s, d from t`)
+	assert.Equal(t, []types.Row{{int64(1), int64(10), "a", decimal.NewDecimal(125, 2)}}, rows)
+
+	mustExecSQL(t, e, `alter table t drop column s`)
+	tbl, err := e.TableByName(context.Background(), "t")
+	require.NoError(t, err)
+	assert.Equal(t, []dax.FieldName{"_id", "i", "d"}, tbl.FieldNames())
+	_, err = execSQL(t, e, `select s from t`)
+	assert.Error(t, err)
+
+	// a dropped column can be added back, without its old values
+	mustExecSQL(t, e, `alter table t add s string`)
+	rows = mustExecSQL(t, e, `select _id, s from t`)
+	assert.Equal(t, []types.Row{{int64(1), nil}}, rows)
+
+	// a compiled plan keeps the schema of the table it was compiled against
+	sql := `select d, i from t`
+	stmt, err := parser.NewParser(strings.NewReader(sql)).ParseStatement()
+	require.NoError(t, err)
+	op, err := planner.NewExecutionPlanner(e, e, e, e, e, *slog.Default(), sql).CompilePlan(context.Background(), stmt)
+	require.NoError(t, err)
+	mustExecSQL(t, e, `alter table t drop column d`)
+	require.Len(t, op.Schema(), 2)
+	assert.Equal(t, "d", op.Schema()[0].ColumnName)
+
+	for _, sql := range []string{
+		`alter table t drop column _id`,
+		`alter table t add column _id id`,
+		`alter table t add column i int`,
+		`alter table t add column x date`,
+		`alter table t add column x string min 0`,
+		`alter table t drop column nope`,
+		`alter table nope add column x int`,
+		`alter table t rename column i to j`,
+	} {
+		_, err := execSQL(t, e, sql)
+		assert.Error(t, err, sql)
+	}
+}
+
+func TestDropTable(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, i int)`)
+	mustExecSQL(t, e, `drop table t`)
+	_, err := e.TableByName(context.Background(), "t")
+	assert.Error(t, err)
+
+	_, err = execSQL(t, e, `drop table t`)
+	assert.ErrorIs(t, err, sql3.ErrTableNotFound)
+	mustExecSQL(t, e, `drop table if exists t`)
+}
+
+func TestDatabases(t *testing.T) {
+	ctx := context.Background()
+	e := memory.New()
+	mustExecSQL(t, e, `create database db with units 2 comment 'test database'`)
+	db, err := e.DatabaseByName(ctx, "db")
+	require.NoError(t, err)
+	assert.Equal(t, 2, db.Options.WorkersMin)
+	assert.Equal(t, 2, db.Options.WorkersMax)
+	assert.Equal(t, "test database", db.Description)
+
+	_, err = execSQL(t, e, `create database db`)
+	assert.ErrorIs(t, err, sql3.ErrDatabaseExists)
+	mustExecSQL(t, e, `create database if not exists db`)
+	_, err = execSQL(t, e, `create database db2 with units 10001`)
+	assert.ErrorIs(t, err, sql3.ErrInvalidUnitsValue)
+
+	mustExecSQL(t, e, `alter database db with units 4`)
+	db, err = e.DatabaseByName(ctx, "db")
+	require.NoError(t, err)
+	assert.Equal(t, 4, db.Options.WorkersMin)
+	_, err = execSQL(t, e, `alter database nope with units 4`)
+	assert.ErrorIs(t, err, sql3.ErrDatabaseNotFound)
+
+	mustExecSQL(t, e, `drop database db`)
+	_, err = e.DatabaseByName(ctx, "db")
+	assert.Error(t, err)
+	_, err = execSQL(t, e, `drop database db`)
+	assert.ErrorIs(t, err, sql3.ErrDatabaseNotFound)
+	mustExecSQL(t, e, `drop database if exists db`)
+}
+
+func TestShow(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create database db with units 2`)
+	mustExecSQL(t, e, `create table t2 (_id id, i int)`)
+	ddl := `CREATE TABLE t1 (_id string, i int MIN -10 MAX 100, d decimal(2) MIN 0.00 MAX 12.50, s string CACHETYPE ranked SIZE 1000, ssq stringsetq TIMEQUANTUM 'YMD' TTL '24h0m0s', ts timestamp TIMEUNIT 'ms') KEYPARTITIONS 12 COMMENT 'it''s a table'`
+	mustExecSQL(t, e, ddl)
+
+	rows := mustExecSQL(t, e, `show databases`)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "db", rows[0][1])
+	assert.Equal(t, int64(2), rows[0][6])
+
+	rows = mustExecSQL(t, e, `show tables`)
+	require.Len(t, rows, 2)
+	assert.Equal(t, []interface{}{"t1", true, int64(12), "it's a table"}, []interface{}{rows[0][1], rows[0][5], rows[0][6], rows[0][7]})
+	assert.Equal(t, []interface{}{"t2", false, int64(256), ""}, []interface{}{rows[1][1], rows[1][5], rows[1][6], rows[1][7]})
+	mustExecSQL(t, e, `show tables with system`)
+	_, err := execSQL(t, e, `show tables with nope`)
+	assert.Error(t, err)
+
+	rows = mustExecSQL(t, e, `show columns from t1`)
+	require.Len(t, rows, 6)
+	names := make([]interface{}, len(rows))
+	for i, row := range rows {
+		names[i] = row[1]
+	}
+	assert.Equal(t, []interface{}{"_id", "i", "d", "s", "ssq", "ts"}, names)
+	assert.Equal(t, types.Row{"d", "d", "decimal(2)"}, rows[2][:3])
+	assert.Equal(t, []interface{}{"ranked", int64(1000)}, []interface{}{rows[3][5], rows[3][6]})
+	assert.Equal(t, []interface{}{"YMD", "24h0m0s"}, []interface{}{rows[4][12], rows[4][13]})
+	assert.Equal(t, "ms", rows[5][10])
+
+	// the ddl recreates the same table
+	rows = mustExecSQL(t, e, `show create table t1`)
+	assert.Equal(t, []types.Row{{ddl}}, rows)
+	mustExecSQL(t, e, `drop table t1`)
+	mustExecSQL(t, e, ddl)
+	rows = mustExecSQL(t, e, `show create table t1`)
+	assert.Equal(t, []types.Row{{ddl}}, rows)
+
+	_, err = execSQL(t, e, `show columns from nope`)
+	assert.ErrorIs(t, err, sql3.ErrTableNotFound)
+	_, err = execSQL(t, e, `show create table nope`)
+	assert.ErrorIs(t, err, sql3.ErrTableNotFound)
+}
+
+func TestExplain(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, a int, b int)`)
+	mustExecSQL(t, e, `insert into t (_id, a, b) values (1, 10, 100), (2, 20, 200)`)
+
+	rows := mustExecSQL(t, e, `explain select a from t where b > 100 order by a desc`)
+	require.Len(t, rows, 1)
+	assert.Equal(t, `Query
+  Projection(t.a)
+    OrderBy(t.a desc)
+      TableScan(t; columns a, b; filter t.b>100)`, rows[0][0])
+	var plan map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(rows[0][1].(string)), &plan))
+	assert.Equal(t, "*planner.PlanOpQuery", plan["_op"])
+
+	// word operators are separated from their operands
+	rows, err := execSQL(t, e, `explain select a from t where b is not null or (a not between 1 and 5) and a in (1, 2)`, "pushdownFilters")
+	require.NoError(t, err)
+	assert.Contains(t, rows[0][0], "Filter(t.b IS NOT null OR t.a not between 1 and 5 AND t.a in (1, 2))")
+
+	// the explained statement is not executed
+	rows = mustExecSQL(t, e, `explain delete from t where a = 10`)
+	assert.Equal(t, "Query\n  Delete(t)\n    TableScan(t; columns _id, a, b; filter t.a=10)", rows[0][0])
+	rows = mustExecSQL(t, e, `select _id from t`)
+	assert.Len(t, rows, 2)
+
+	_, err = execSQL(t, e, `explain select * from nope`)
+	assert.ErrorIs(t, err, sql3.ErrTableOrViewNotFound)
+}
+
+func TestExplainAnalyze(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, a int, b int)`)
+	mustExecSQL(t, e, `insert into t (_id, a, b) values (1, 10, 100), (2, 20, 200), (3, 30, 300)`)
+
+	rows := mustExecSQL(t, e, `explain analyze select a from t where b > 100`)
+	require.Len(t, rows, 1)
+	lines := strings.Split(rows[0][0].(string), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^    TableScan\(t; columns a, b; filter t.b>100\) \(rows=2 loops=1 time=\S+ approx_heap_delta=\d+B\)$`, lines[2])
+
+	var plan map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(rows[0][1].(string)), &plan))
+	stats := plan["_analyze"].(map[string]interface{})
+	assert.Equal(t, float64(2), stats["rows"])
+	assert.Contains(t, stats, "approxHeapDelta")
+
+	// the statistics are recorded with the request
+	req, err := e.ExecutionRequests().GetRequest("requestId")
+	require.NoError(t, err)
+	assert.Contains(t, req.Plan, `"_analyze"`)
+
+	// the explained statement is executed
+	mustExecSQL(t, e, `explain analyze delete from t where a = 10`)
+	rows = mustExecSQL(t, e, `select _id from t`)
+	assert.Len(t, rows, 2)
+}
+
+func TestOptimizer(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, a int)`)
+	mustExecSQL(t, e, `insert into t (_id, a) values (1, 10), (2, 20)`)
+
+	sql := `select s.a from (select a from t) as s`
+	rows := mustExecSQL(t, e, `explain `+sql)
+	assert.NotContains(t, rows[0][0], "Subquery")
+	assert.True(t, strings.HasPrefix(rows[0][2].(string), "removeSubqueries\n  Query\n"), rows[0][2])
+
+	// each rule can be turned off
+	rows, err := execSQL(t, e, `explain `+sql, "removeSubqueries", "pruneColumns")
+	require.NoError(t, err)
+	assert.Contains(t, rows[0][0], "Subquery")
+	assert.Equal(t, "", rows[0][2])
+
+	// the rules don't change the result
+	expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+	require.NoError(t, err)
+	assert.Equal(t, expected, mustExecSQL(t, e, sql))
+
+	_, err = execSQL(t, e, sql, "nope")
+	assert.Error(t, err)
+}
+
+func TestPushdownFilters(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id string, i int, d decimal(2), s string, b bool, ss stringset)`)
+	mustExecSQL(t, e, `insert into t (_id, i, d, s, b, ss) values
+		('r1', 10, 1.25, 'a', true, ['x', 'y']),
+		('r2', -3, -0.5, 'b', false, ['z']),
+		('r3', 0, 12.00, 'a', null, null),
+		('r4', null, null, null, true, ['y']),
+		('r5', -40, 3.75, 'c', false, ['x'])`)
+
+	rows := mustExecSQL(t, e, `explain select _id from t where i > 0 and s = 'a'`)
+	assert.NotContains(t, rows[0][0], "Filter(")
+	assert.Contains(t, rows[0][0], "TableScan(t; columns _id, i, s; filter t.i>0 AND t.s='a')")
+	assert.True(t, strings.HasPrefix(rows[0][2].(string), "pushdownFilters\n"), rows[0][2])
+
+	// filters evaluated with bitmaps return the same rows as filters evaluated
+	// row by row
+	for _, where := range []string{
+		`_id = 'r2'`,
+		`_id != 'r2'`,
+		`_id = 'nope'`,
+		`i = 0`,
+		`i != 10`,
+		`i < 0`,
+		`i <= -3`,
+		`-3 < i`,
+		`i >= -40`,
+		`i > 1000`,
+		`i < -1000`,
+		`d = 1.25`,
+		`d > 1`,
+		`d <= -0.5`,
+		`d = 1.251`,
+		`s = 'a'`,
+		`s != 'a'`,
+		`s = 'nope'`,
+		`b = true`,
+		`b != true`,
+		`i in (10, -40, 7)`,
+		`i not in (10, -40)`,
+		`s in ('b', 'c')`,
+		`i between -5 and 10`,
+		`i not between -5 and 10`,
+		`i is null`,
+		`d is not null`,
+		`b is null`,
+		`setcontains(ss, 'y')`,
+		`setcontainsany(ss, ['x', 'z'])`,
+		`setcontainsall(ss, ['x', 'y'])`,
+		`i > 0 or s = 'c'`,
+		`i < 5 and s = 'a' and _id != 'r3'`,
+		`i + 1 > 0 and b = false`,
+	} {
+		sql := `select _id from t where ` + where
+		expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+		require.NoError(t, err, where)
+		assert.Equal(t, expected, mustExecSQL(t, e, sql), where)
+	}
+
+	// the terms of a filter on a join that reference one input are pushed
+	// down into that input
+	mustExecSQL(t, e, `create table a (_id id, x int, s string)`)
+	mustExecSQL(t, e, `create table b (_id id, k int, v int)`)
+	mustExecSQL(t, e, `insert into a (_id, x, s) values (1, 1, 'a'), (2, 2, 'b'), (3, 3, 'a')`)
+	mustExecSQL(t, e, `insert into b (_id, k, v) values (1, 1, 10), (2, 2, 20), (3, 4, 40)`)
+	rows = mustExecSQL(t, e, `explain select b.v from a join b on a.x = b.k where a.s = 'a' and b.v > 5 and a.x + b.v > 0`)
+	assert.Contains(t, rows[0][0], "TableScan(a; columns x, s; filter a.s='a')")
+	assert.Contains(t, rows[0][0], "TableScan(b; columns k, v; filter b.v>5)")
+	assert.Contains(t, rows[0][0], "Filter(a.x+b.v>0)")
+
+	// but not into an input whose rows that match nothing are padded with
+	// nulls
+	rows = mustExecSQL(t, e, `explain select b.v from a left join b on a.x = b.k where a.s = 'a' and b.v is null`)
+	assert.Contains(t, rows[0][0], "TableScan(a; columns x, s; filter a.s='a')")
+	assert.Contains(t, rows[0][0], "Filter(b.v IS null)")
+
+	for _, join := range []string{"inner", "left", "right", "full"} {
+		for _, where := range []string{
+			`a.s = 'a'`,
+			`b.v > 15`,
+			`b.v is null`,
+			`a.s = 'a' and b.v < 30 and a._id + b._id > 2`,
+			`a.s = 'b' or b.v = 40`,
+		} {
+			sql := `select a._id, b._id from a ` + join + ` join b on a.x = b.k where ` + where
+			expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+			require.NoError(t, err, sql)
+			assert.ElementsMatch(t, expected, mustExecSQL(t, e, sql), sql)
+		}
+	}
+}
+
+func TestRangeQ(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, ssq stringsetq timequantum 'YMDH')`)
+	mustExecSQL(t, e, `insert into t (_id, ssq) values
+		(1, {'2021-06-30T23:00:00Z', ['a']}),
+		(2, {'2022-01-15T10:00:00Z', ['b']}),
+		(3, {'2022-03-01T00:00:00Z', ['c']}),
+		(4, ['d'])`)
+
+	rows := mustExecSQL(t, e, `explain select _id from t where rangeq(ssq, '2022-01-01T00:00:00Z', null)`)
+	assert.NotContains(t, rows[0][0], "Filter(")
+	assert.Contains(t, rows[0][0], "time quantum filters RANGEQ(t.ssq, ")
+
+	for _, test := range []struct {
+		where string
+		ids   []types.Row
+	}{
+		{`rangeq(ssq, '2022-01-01T00:00:00Z', '2022-02-01T00:00:00Z')`, []types.Row{{int64(2)}}},
+		{`rangeq(ssq, '2022-01-15T10:00:00Z', '2022-01-15T11:00:00Z')`, []types.Row{{int64(2)}}},
+		{`rangeq(ssq, '2022-01-15T11:00:00Z', '2022-03-01T00:00:00Z')`, []types.Row{}},
+		{`rangeq(ssq, '2022-01-01T00:00:00Z', null)`, []types.Row{{int64(2)}, {int64(3)}}},
+		{`rangeq(ssq, null, '2022-01-01T00:00:00Z')`, []types.Row{{int64(1)}}},
+		{`rangeq(ssq, null, '2023-01-01T00:00:00Z') and _id > 1`, []types.Row{{int64(2)}, {int64(3)}}},
+	} {
+		assert.Equal(t, test.ids, mustExecSQL(t, e, `select _id from t where `+test.where), test.where)
+	}
+
+	// without the pushdown rangeq() can't be evaluated
+	_, err := execSQL(t, e, `select _id from t where rangeq(ssq, null, '2023-01-01T00:00:00Z')`, "pushdownFilters")
+	assert.ErrorIs(t, err, sql3.ErrQRangeInvalidUse)
+
+	// replacing a value removes it from the time views
+	mustExecSQL(t, e, `replace into t (_id, ssq) values (2, {'2021-06-30T22:00:00Z', ['e']}), (3, null)`)
+	rows = mustExecSQL(t, e, `select _id, ssq from t where rangeq(ssq, '2021-01-01T00:00:00Z', null)`)
+	assert.Equal(t, []types.Row{{int64(1), []string{"a"}}, {int64(2), []string{"e"}}}, rows)
+	rows = mustExecSQL(t, e, `select _id from t where rangeq(ssq, '2022-01-01T00:00:00Z', null)`)
+	assert.Equal(t, []types.Row{}, rows)
+}
+
+func TestAggregates(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, i int, ts timestamp, s string)`)
+	mustExecSQL(t, e, `insert into t (_id, i, ts, s) values (1, 10, '2023-01-02T00:00:00Z', 'a'), (2, -3, '2023-01-01T00:00:00Z', 'b'), (3, 4, '2023-01-03T00:00:00Z', 'a')`)
+
+	// the projections and the having are evaluated against the rows of the
+	// group by
+	rows := mustExecSQL(t, e, `select count(*), sum(i) + 1 as x from t`)
+	assert.Equal(t, []types.Row{{int64(3), int64(12)}}, rows)
+	rows = mustExecSQL(t, e, `select s, sum(i) + 1 from t group by s having count(*) > 1`)
+	assert.Equal(t, []types.Row{{"a", int64(15)}}, rows)
+
+	// group by expressions are matched as a whole
+	rows = mustExecSQL(t, e, `select i % 2 + 1, count(*) from t group by i % 2 having i % 2 = 0`)
+	assert.Equal(t, []types.Row{{int64(1), int64(2)}}, rows)
+	_, err := execSQL(t, e, `select i, count(*) from t group by i % 2`)
+	assert.ErrorIs(t, err, sql3.ErrInvalidUngroupedColumnReference)
+
+	rows = mustExecSQL(t, e, `select sum(i) from t where i > 100`)
+	assert.Equal(t, []types.Row{{nil}}, rows)
+
+	rows = mustExecSQL(t, e, `select min(ts), max(ts) from t`)
+	assert.Equal(t, []types.Row{{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+	}}, rows)
+
+	// a sum that doesn't fit in an int is an error, with or without bitmaps
+	mustExecSQL(t, e, `insert into t (_id, i) values (4, 9223372036854775807)`)
+	_, err = execSQL(t, e, `select sum(i) from t`)
+	assert.ErrorIs(t, err, sql3.ErrOutputValueOutOfRange)
+	_, err = execSQL(t, e, `select sum(i) from t`, planner.OptimizerRules()...)
+	assert.ErrorIs(t, err, sql3.ErrOutputValueOutOfRange)
+}
+
+func TestPushdownAggregates(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, i int, d decimal(2), ts timestamp, s string)`)
+	// the last record is in another shard
+	mustExecSQL(t, e, `insert into t (_id, i, d, ts, s) values
+		(1, 10, 1.25, '2022-01-01T00:00:00Z', 'a'),
+		(2, -3, -0.5, null, 'b'),
+		(3, null, 12.00, '2021-06-30T12:00:00Z', null),
+		(4, -40, null, '2023-03-01T00:00:00Z', 'a'),
+		(`+fmt.Sprint(api.ShardWidth+7)+`, 1000, 3.75, null, 'c')`)
+
+	rows := mustExecSQL(t, e, `explain select count(*), sum(i) from t where i > 0`)
+	assert.NotContains(t, rows[0][0], "GroupBy(")
+	assert.Contains(t, rows[0][0], "BitmapAggregate(count(*), sum(t.i); TableScan(")
+
+	// a filter that can't be evaluated with bitmaps has to be applied to rows
+	rows = mustExecSQL(t, e, `explain select count(*) from t where i + 1 > 0`)
+	assert.Contains(t, rows[0][0], "GroupBy(count(*))")
+
+	for _, sql := range []string{
+		`select count(*), count(i), count(d), count(s), count(_id) from t`,
+		`select sum(i), sum(d), min(i), max(i), min(d), max(d) from t`,
+		`select min(ts), max(ts) from t`,
+		`select count(*), sum(i) + 1 as x from t where s = 'a'`,
+		`select max(i), min(d) from t where i < 0`,
+		`select count(*), sum(i), min(i) from t where i > 5000`,
+		`select count(*) from t where i + 1 > 0`,
+	} {
+		expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+		require.NoError(t, err, sql)
+		assert.Equal(t, expected, mustExecSQL(t, e, sql), sql)
+	}
+
+	rows = mustExecSQL(t, e, `select count(*), sum(i), min(d), max(ts) from t`)
+	assert.Equal(t, []types.Row{{
+		int64(5),
+		int64(967),
+		decimal.NewDecimal(-50, 2),
+		time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+	}}, rows)
+}
+
+func TestPercentile(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, i int, d decimal(2), ts timestamp, s string)`)
+	mustExecSQL(t, e, `insert into t (_id, i, d, ts, s) values
+		(1, 10, 1.25, '2022-01-01T00:00:00Z', 'a'),
+		(2, -3, -0.5, null, 'b'),
+		(3, null, 12.00, '2021-06-30T12:00:00Z', 'a'),
+		(4, -40, null, '2023-03-01T00:00:00Z', 'a'),
+		(5, 7, 0.01, '2020-01-01T00:00:00Z', 'b'),
+		(`+fmt.Sprint(api.ShardWidth+7)+`, 1000, 3.75, null, 'c')`)
+
+	rows := mustExecSQL(t, e, `explain select percentile(i, 50) from t`)
+	assert.Contains(t, rows[0][0], "BitmapAggregate(percentile(t.i, 50); TableScan(")
+
+	rows = mustExecSQL(t, e, `select percentile(i, 0), percentile(i, 20), percentile(i, 50), percentile(i, 60.5), percentile(i, 100) from t`)
+	assert.Equal(t, []types.Row{{int64(-40), int64(-40), int64(7), int64(10), int64(1000)}}, rows)
+
+	for _, sql := range []string{
+		`select percentile(i, 0), percentile(i, 37.5), percentile(i, 60), percentile(i, 99.99), percentile(i, 100) from t`,
+		`select percentile(d, 25), percentile(d, 75), percentile(ts, 50) from t`,
+		`select percentile(i, 50) from t where s = 'a'`,
+		`select percentile(i, 50) from t where i > 5000`,
+	} {
+		expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+		require.NoError(t, err, sql)
+		assert.Equal(t, expected, mustExecSQL(t, e, sql), sql)
+	}
+
+	// grouped percentiles are computed from the values of each group
+	rows = mustExecSQL(t, e, `select s, percentile(i, 50) from t group by s`)
+	assert.ElementsMatch(t, []types.Row{{"a", int64(-40)}, {"b", int64(-3)}, {"c", int64(1000)}}, rows)
+
+	_, err := execSQL(t, e, `select percentile(i, 101) from t`)
+	assert.ErrorIs(t, err, sql3.ErrValueOutOfRange)
+}
+
+func TestPruneColumns(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, a int, b int, c string, d decimal(2))`)
+	mustExecSQL(t, e, `create table u (_id id, t_id int, x string, y int)`)
+	mustExecSQL(t, e, `insert into t (_id, a, b, c, d) values
+		(1, 10, 100, 'p', 1.25),
+		(2, 20, 200, 'q', null),
+		(3, 30, null, 'p', 3.00)`)
+	mustExecSQL(t, e, `insert into u (_id, t_id, x, y) values
+		(1, 1, 'one', 7),
+		(2, 3, 'three', 8),
+		(3, 3, 'also three', 9)`)
+
+	rows := mustExecSQL(t, e, `explain select c from t where a + 1 > 15 order by b`)
+	assert.Contains(t, rows[0][0], "TableScan(t; columns a, b, c; filter t.a+1>15)")
+
+	rows = mustExecSQL(t, e, `explain select count(*) from t where a + 1 > 15`)
+	assert.Contains(t, rows[0][0], "TableScan(t; columns a; filter t.a+1>15)")
+
+	rows = mustExecSQL(t, e, `explain select t.c, u.x from t inner join u on t._id = u.t_id`)
+	assert.Contains(t, rows[0][0], "TableScan(t; columns _id, c)")
+	assert.Contains(t, rows[0][0], "TableScan(u; columns t_id, x)")
+
+	rows = mustExecSQL(t, e, `select t.c, u.x from t inner join u on t._id = u.t_id where u.y > 7`)
+	assert.ElementsMatch(t, []types.Row{{"p", "three"}, {"p", "also three"}}, rows)
+
+	// pruned plans return the same rows as unpruned ones
+	for _, sql := range []string{
+		`select c from t where a + 1 > 15 order by b`,
+		`select c, sum(d) from t where b > 0 group by c`,
+		`select count(*) from t where a + 1 > 15`,
+		`select t.c, u.x from t inner join u on t._id = u.t_id where u.y > 7`,
+		`select t.d, u.x, u.y from t left join u on t._id = u.t_id order by y`,
+		`select s.b from (select a, b from t where c = 'p') as s`,
+		`select * from t`,
+	} {
+		expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+		require.NoError(t, err, sql)
+		assert.Equal(t, expected, mustExecSQL(t, e, sql), sql)
+	}
+}
+
+func TestFoldConstants(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table t (_id id, i int, d decimal(2), s string, b bool)`)
+	mustExecSQL(t, e, `insert into t (_id, i, d, s, b) values
+		(1, 10, 1.25, 'a', true),
+		(2, -3, -0.5, 'b', false),
+		(3, null, 12.00, null, null)`)
+
+	rows := mustExecSQL(t, e, `explain select i * (2 + 3), upper('abc'), 1.00 + 0.25 from t where i > 1 + 2`)
+	assert.Contains(t, rows[0][0], "Projection(t.i*5, 'ABC', 1.25)")
+	assert.Contains(t, rows[0][0], "filter t.i>3")
+
+	rows = mustExecSQL(t, e, `explain select _id from t where 1 = 1 and i + 1 > 0 and not not b = true`)
+	assert.Contains(t, rows[0][0], "filter t.i+1>0 AND t.b=true)")
+
+	// filters that are always true are removed
+	rows = mustExecSQL(t, e, `explain select _id from t where 1 < 2 and true`)
+	assert.Equal(t, "Query\n  Projection(t._id)\n    TableScan(t; columns _id)", rows[0][0])
+
+	// filters that are never true don't read the table
+	rows = mustExecSQL(t, e, `explain select _id from t where i > 0 and 1 > 2`)
+	assert.Equal(t, "Query\n  Projection(t._id)\n    EmptyRelation", rows[0][0])
+
+	// a literal operand can decide the result of AND and OR
+	rows = mustExecSQL(t, e, `explain select _id from t where i = 1 or true`)
+	assert.Equal(t, "Query\n  Projection(t._id)\n    TableScan(t; columns _id)", rows[0][0])
+	rows = mustExecSQL(t, e, `explain select _id from t where s = 'a' and (i = 1 and false)`)
+	assert.Equal(t, "Query\n  Projection(t._id)\n    EmptyRelation", rows[0][0])
+
+	for _, sql := range []string{
+		`select i * (2 + 3), upper('abc'), cast(1 as decimal(2)), 1.50 + 0.25 from t`,
+		`select _id from t where i > 1 + 2`,
+		`select _id from t where not i > 0`,
+		`select _id, not not b from t`,
+		`select _id, b and true, b or false from t`,
+		`select _id, b and false, b or true, false and b, true or b from t`,
+		`select _id from t where i = 10 or true`,
+		`select _id from t where b and false`,
+		`select _id from t where 1 < 2 and true`,
+		`select _id from t where i > 0 and 1 > 2`,
+		`select count(*), sum(i) from t where null`,
+		`select s, count(*) from t group by s having 1 = 2`,
+		`select datetimeadd('d', 1, '2022-01-01T00:00:00Z') from t where s = lower('A')`,
+	} {
+		expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+		require.NoError(t, err, sql)
+		assert.Equal(t, expected, mustExecSQL(t, e, sql), sql)
+	}
+
+	rows = mustExecSQL(t, e, `select count(*) from t where 1 > 2`)
+	assert.Equal(t, []types.Row{{int64(0)}}, rows)
+}
+
+func TestHashJoin(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table f (_id id, d_id int, k string, v int)`)
+	mustExecSQL(t, e, `create table d (_id id, name string, k string)`)
+	mustExecSQL(t, e, `insert into f (_id, d_id, k, v) values
+		(1, 1, 'x', 10),
+		(2, 1, 'y', 20),
+		(3, 2, 'x', 30),
+		(4, 3, 'x', 40),
+		(5, null, 'x', 50),
+		(6, 2, null, 60)`)
+	mustExecSQL(t, e, `insert into d (_id, name, k) values
+		(1, 'one', 'x'),
+		(2, 'two', 'x'),
+		(4, 'four', 'y')`)
+
+	rows := mustExecSQL(t, e, `explain select f.v, d.name from f inner join d on d._id = f.d_id and f.v > 10`)
+	assert.Contains(t, rows[0][0], "HashJoin(inner join on f.d_id=d._id; filter f.v>10)")
+	assert.NotContains(t, rows[0][0], "NestedLoops")
+
+	// a join without an equality between its inputs uses nested loops
+	rows = mustExecSQL(t, e, `explain select f.v, d.name from f inner join d on f.d_id > d._id`)
+	assert.Contains(t, rows[0][0], "NestedLoops(inner join on f.d_id>d._id)")
+
+	rows = mustExecSQL(t, e, `select f._id, d.name from f left join d on f.d_id = d._id and f.k = d.k`)
+	assert.ElementsMatch(t, []types.Row{
+		{int64(1), "one"},
+		{int64(2), nil},
+		{int64(3), "two"},
+		{int64(4), nil},
+		{int64(5), nil},
+		{int64(6), nil},
+	}, rows)
+
+	// each input is the smaller one in one direction
+	for _, sql := range []string{
+		`select f._id, d.name from f inner join d on f.d_id = d._id`,
+		`select f._id, d.name from d inner join f on f.d_id = d._id`,
+		`select f._id, d.name from f left join d on f.d_id = d._id`,
+		`select f._id, d.name from d left join f on f.d_id = d._id`,
+		`select f._id, d.name from f inner join d on f.d_id = d._id and f.k = d.k and f.v < 40`,
+		`select f._id, d.name from d left join f on d._id = f.d_id and f.v > 10`,
+		`select f._id, d._id from f inner join d on f.k = d.k`,
+		`select f._id, d._id from f inner join d on upper(f.k) = upper(d.k) and f.d_id + 1 = d._id + 1`,
+	} {
+		expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+		require.NoError(t, err, sql)
+		assert.ElementsMatch(t, expected, mustExecSQL(t, e, sql), sql)
+	}
+
+	// the values of a key with several columns don't run together
+	mustExecSQL(t, e, `create table a (_id id, x int, y int)`)
+	mustExecSQL(t, e, `create table b (_id id, x int, y int)`)
+	mustExecSQL(t, e, `insert into a (_id, x, y) values (1, 1, 12), (2, 3, 4)`)
+	mustExecSQL(t, e, `insert into b (_id, x, y) values (1, 11, 2), (2, 3, 4)`)
+	sql := `select a._id, b._id from a inner join b on a.x = b.x and a.y = b.y`
+	expected, err := execSQL(t, e, sql, planner.OptimizerRules()...)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Row{{int64(2), int64(2)}}, expected)
+	assert.Equal(t, expected, mustExecSQL(t, e, sql))
+}
+
+func TestOuterJoins(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table a (_id id, k int, x string)`)
+	mustExecSQL(t, e, `create table b (_id id, k int, y string)`)
+	mustExecSQL(t, e, `insert into a (_id, k, x) values (1, 1, 'a1'), (2, 2, 'a2'), (3, null, 'a3')`)
+	mustExecSQL(t, e, `insert into b (_id, k, y) values (1, 2, 'b2'), (2, 3, 'b3'), (3, 2, 'b2 again')`)
+
+	rows := mustExecSQL(t, e, `select a.x, b.y from a right join b on a.k = b.k`)
+	assert.ElementsMatch(t, []types.Row{
+		{"a2", "b2"},
+		{"a2", "b2 again"},
+		{nil, "b3"},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select a.x, b.y from a full outer join b on a.k = b.k`)
+	assert.ElementsMatch(t, []types.Row{
+		{"a1", nil},
+		{"a2", "b2"},
+		{"a2", "b2 again"},
+		{"a3", nil},
+		{nil, "b3"},
+	}, rows)
+
+	// hash joins and nested loops return the same rows, whichever input is
+	// in the hash table
+	for _, sql := range []string{
+		`select a.x, b.y from a right join b on a.k = b.k`,
+		`select a.x, b.y from b right join a on a.k = b.k`,
+		`select a.x, b.y from a full join b on a.k = b.k`,
+		`select a.x, b.y from a full join b on a.k = b.k and b.y != 'b2'`,
+		`select a.x, b.y from a full join b on a.k < b.k`,
+		`select a.x, b.y from a right join b on a.k > b.k`,
+		`select a.x, b.y from a right join b on a.k = b.k where a.x is null`,
+	} {
+		expected, err := execSQL(t, e, sql, "hashJoins")
+		require.NoError(t, err, sql)
+		assert.ElementsMatch(t, expected, mustExecSQL(t, e, sql), sql)
+	}
+
+	// the unmatched rows of a hash join are returned whichever input is
+	// smaller
+	mustExecSQL(t, e, `insert into b (_id, k, y) values (4, 7, 'b7'), (5, 8, 'b8')`)
+	for _, sql := range []string{
+		`select a.x, b.y from a full join b on a.k = b.k`,
+		`select a.x, b.y from b full join a on a.k = b.k`,
+		`select a.x, b.y from a right join b on a.k = b.k`,
+		`select a.x, b.y from b right join a on a.k = b.k`,
+	} {
+		expected, err := execSQL(t, e, sql, "hashJoins")
+		require.NoError(t, err, sql)
+		assert.ElementsMatch(t, expected, mustExecSQL(t, e, sql), sql)
+	}
+}
+
+func TestJoinUsing(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table a (_id id, k int, x string)`)
+	mustExecSQL(t, e, `create table b (_id id, k int, y string)`)
+	mustExecSQL(t, e, `insert into a (_id, k, x) values (1, 1, 'a1'), (2, 2, 'a2')`)
+	mustExecSQL(t, e, `insert into b (_id, k, y) values (1, 2, 'b2'), (2, 3, 'b3')`)
+
+	// the merged column comes first and replaces the columns it was merged
+	// from
+	rows := mustExecSQL(t, e, `select * from a join b using (k)`)
+	assert.ElementsMatch(t, []types.Row{
+		{int64(2), int64(2), "a2", int64(1), "b2"},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select k, a.k, b.k from a full join b using (k)`)
+	assert.ElementsMatch(t, []types.Row{
+		{int64(1), int64(1), nil},
+		{int64(2), int64(2), int64(2)},
+		{int64(3), nil, int64(3)},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select k, y from a right join b using (k) order by k`)
+	assert.Equal(t, []types.Row{
+		{int64(2), "b2"},
+		{int64(3), "b3"},
+	}, rows)
+
+	// a natural join merges _id and k
+	rows = mustExecSQL(t, e, `select * from a natural left join b`)
+	assert.ElementsMatch(t, []types.Row{
+		{int64(1), int64(1), "a1", nil},
+		{int64(2), int64(2), "a2", nil},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select a.x, c.k from a join b using (k) join a c using (k)`)
+	assert.ElementsMatch(t, []types.Row{
+		{"a2", int64(2)},
+	}, rows)
+
+	// explicit cross joins are cartesian products without a warning
+	ctx := context.Background()
+	for sql, warned := range map[string]bool{
+		`select a.x, b.y from a, b`:                      true,
+		`select a.x, b.y from a cross join b`:            false,
+		`select x, y from a natural join b`:              false,
+		`select x, y from a join b using (_id)`:          false,
+		`select a.x, b.y from a join b on a._id = b._id`: false,
+	} {
+		stmt, err := parser.NewParser(strings.NewReader(sql)).ParseStatement()
+		require.NoError(t, err, sql)
+		op, err := planner.NewExecutionPlanner(e, e, e, e, e, *slog.Default(), sql).CompilePlan(ctx, stmt)
+		require.NoError(t, err, sql)
+		found := false
+		for _, w := range op.Warnings() {
+			if strings.Contains(w, "cartesian") {
+				found = true
+			}
+		}
+		assert.Equal(t, warned, found, sql)
+	}
+	rows = mustExecSQL(t, e, `select a.x, b.y from a cross join b`)
+	assert.Len(t, rows, 4)
+
+	_, err := execSQL(t, e, `select * from a join b using (y)`)
+	assert.ErrorIs(t, err, sql3.ErrColumnNotFound)
+	_, err = execSQL(t, e, `select * from a join b using (k, k)`)
+	assert.ErrorIs(t, err, sql3.ErrAmbiguousColumn)
+	_, err = execSQL(t, e, `select * from a join b on a.k = b.k join b c using (k)`)
+	assert.ErrorIs(t, err, sql3.ErrAmbiguousColumn)
+}
+
+func TestSetOperations(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table a (_id id, i int, s string)`)
+	mustExecSQL(t, e, `create table b (_id id, d decimal(2), s string)`)
+	mustExecSQL(t, e, `insert into a (_id, i, s) values (1, 1, 'x'), (2, 2, 'y'), (3, 2, 'y'), (4, 3, 'z')`)
+	mustExecSQL(t, e, `insert into b (_id, d, s) values (1, 2.00, 'y'), (2, 4.50, 'w'), (3, 4.50, 'w')`)
+
+	dec := func(v int64) decimal.Decimal { return decimal.NewDecimal(v, 2) }
+
+	rows := mustExecSQL(t, e, `select i, s from a union all select d, s from b`)
+	assert.ElementsMatch(t, []types.Row{
+		{dec(100), "x"},
+		{dec(200), "y"},
+		{dec(200), "y"},
+		{dec(300), "z"},
+		{dec(200), "y"},
+		{dec(450), "w"},
+		{dec(450), "w"},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select i, s from a union select d, s from b order by 1 desc`)
+	assert.Equal(t, []types.Row{
+		{dec(450), "w"},
+		{dec(300), "z"},
+		{dec(200), "y"},
+		{dec(100), "x"},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select i, s from a intersect select d, s from b`)
+	assert.Equal(t, []types.Row{
+		{dec(200), "y"},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select s from a except select s from b order by s`)
+	assert.Equal(t, []types.Row{
+		{"x"},
+		{"z"},
+	}, rows)
+
+	// intersect binds more tightly than union and except
+	rows = mustExecSQL(t, e, `select s from a except select s from a intersect select s from b order by s`)
+	assert.Equal(t, []types.Row{
+		{"x"},
+		{"z"},
+	}, rows)
+	rows = mustExecSQL(t, e, `select s from b union select s from a except select s from a order by s`)
+	assert.Equal(t, []types.Row{
+		{"w"},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select s from a union all select s from b order by s limit 3`)
+	assert.Equal(t, []types.Row{
+		{"w"},
+		{"w"},
+		{"x"},
+	}, rows)
+
+	rows = mustExecSQL(t, e, `select count(*) from (select s from a union select s from b)`)
+	assert.Equal(t, []types.Row{{int64(4)}}, rows)
+
+	_, err := execSQL(t, e, `select i, s from a union select s from b`)
+	assert.ErrorIs(t, err, sql3.ErrSetOperationColumnCountMismatch)
+	assert.Contains(t, err.Error(), "[1:26]")
+	_, err = execSQL(t, e, `select i from a union select s from b`)
+	assert.ErrorIs(t, err, sql3.ErrTypeMismatch)
+	assert.Contains(t, err.Error(), "[1:23]")
+	_, err = execSQL(t, e, `select s from a union select s from b order by 2`)
+	assert.ErrorIs(t, err, sql3.ErrExpectedSortExpressionReference)
+	assert.Contains(t, err.Error(), "[1:48]")
+}
+
+func TestCTEs(t *testing.T) {
+	e := memory.New()
+	mustExecSQL(t, e, `create table emp (_id id, name string, manager int)`)
+	mustExecSQL(t, e, `insert into emp (_id, name, manager) values
+		(1, 'ceo', null),
+		(2, 'vp', 1),
+		(3, 'eng', 2),
+		(4, 'eng2', 2),
+		(5, 'sales', 1),
+		(6, 'intern', 3)`)
+
+	// an expression referenced twice is computed once
+	sql := `with m (id) as (select distinct manager from emp where manager is not null)
+		select count(*) from m a join m b on a.id < b.id`
+	rows := mustExecSQL(t, e, `explain `+sql)
+	assert.Equal(t, 1, strings.Count(rows[0][0].(string), "With(m)"), rows[0][0])
+	assert.Equal(t, 2, strings.Count(rows[0][0].(string), "CTEScan(m)"), rows[0][0])
+	rows = mustExecSQL(t, e, sql)
+	assert.Equal(t, []types.Row{{int64(3)}}, rows)
+
+	rows = mustExecSQL(t, e, `with recursive reports (id, name, depth) as (
+			select _id, name, 0 from emp where _id = 2
+			union all
+			select e._id, e.name, r.depth + 1 from emp e join reports r on e.manager = r.id
+		)
+		select name, depth from reports order by name`)
+	assert.Equal(t, []types.Row{
+		{"eng", int64(1)},
+		{"eng2", int64(1)},
+		{"intern", int64(2)},
+		{"vp", int64(0)},
+	}, rows)
+
+	// union stops when no new rows are found
+	rows = mustExecSQL(t, e, `with recursive n (i) as (select 1 union select (i % 3) + 1 from n) select i from n order by i`)
+	assert.Equal(t, []types.Row{{int64(1)}, {int64(2)}, {int64(3)}}, rows)
+
+	_, err := execSQL(t, e, `with recursive n (i) as (select i from n) select i from n`)
+	assert.ErrorIs(t, err, sql3.ErrInvalidRecursiveCTE)
+	_, err = execSQL(t, e, `with recursive n (i) as (select 1 union all select 'a' from n) select i from n`)
+	assert.ErrorIs(t, err, sql3.ErrTypeMismatch)
+	assert.Contains(t, err.Error(), "[1:45]")
+	_, err = execSQL(t, e, `with m (a, b) as (select _id from emp) select a from m`)
+	assert.ErrorIs(t, err, sql3.ErrCTEColumnCountMismatch)
+
+	// a recursion that doesn't end fails
+	_, err = execSQL(t, e, `with recursive n (i) as (select 1 union all select i + 1 from n) select count(*) from n`)
+	assert.ErrorIs(t, err, sql3.ErrRecursiveCTELimitExceeded)
+	assert.Contains(t, err.Error(), "[1:16]")
+}